@@ -0,0 +1,391 @@
+package Tea
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var configVariableReg = regexp.MustCompile(`\$?\$\{([\w.]+)(:-([^}]*))?\}`)
+var configSecretKeyReg = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|accesskey)`)
+var configDSNPasswordReg = regexp.MustCompile(`^([^:@/]+):([^@]*)@`)
+var configURLPasswordReg = regexp.MustCompile(`(://[^:@/]+):([^@/]*)@`)
+var configIntReg = regexp.MustCompile(`^-?(0|[1-9]\d*)$`)
+var configFloatReg = regexp.MustCompile(`^-?(0|[1-9]\d*)\.\d+$`)
+var configUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// LoadConfig 读取配置目录中的配置文件，返回合并后的数据和使用的文件
+// names 为候选的文件名，使用第一个存在的文件，比如 []string{"server.yaml", "server.conf"}
+// 读取时依次：
+//  1. 展开字符串中的 ${VAR} 和 ${VAR:-default} 环境变量，$${VAR} 表示不展开
+//  2. 合并当前环境对应的文件，比如 server.prod.yaml，字典会深度合并，其他的值直接替换
+//  3. 使用以 envPrefix 开头的环境变量覆盖单个选项，多级选项之间用两个下划线分隔，不区分大小写，
+//     比如 TEAGO_SERVER_HTTPS__CERT=/path/to/cert.pem
+//
+// 来自环境变量的值都作为字符串保存在返回的数据中，DecodeConfig() 会在字段不是字符串时转换为对应的类型
+//
+// env 为空时使用配置中的 env 选项，如果也没有设置，则使用 Env
+func LoadConfig(names []string, envPrefix string, env string) (data map[string]interface{}, configFile string, err error) {
+	for _, name := range names {
+		configFile = ConfigFile(name)
+		_, err = os.Stat(configFile)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err = readConfigFile(configFile)
+	if err != nil {
+		return nil, configFile, err
+	}
+	applyConfigEnvVars(data, envPrefix)
+
+	// 当前环境
+	if len(env) == 0 {
+		envValue, ok := data["env"].(string)
+		if ok && len(envValue) > 0 {
+			env = envValue
+		} else {
+			env = Env
+		}
+	}
+
+	// 合并当前环境对应的文件
+	var ext = filepath.Ext(configFile)
+	var baseName = strings.TrimSuffix(configFile, ext)
+	for _, overlayExt := range []string{ext, ".yaml", ".conf"} {
+		var overlayFile = baseName + "." + env + overlayExt
+		_, statErr := os.Stat(overlayFile)
+		if statErr != nil {
+			continue
+		}
+		overlayData, err := readConfigFile(overlayFile)
+		if err != nil {
+			return nil, configFile, err
+		}
+		data = MergeConfig(data, overlayData)
+		applyConfigEnvVars(data, envPrefix)
+		break
+	}
+
+	return data, configFile, nil
+}
+
+// DecodeConfig 将 LoadConfig() 返回的数据解析到结构体中，键名和结构体中的yaml标签不区分大小写
+func DecodeConfig(data map[string]interface{}, ptr interface{}) error {
+	yamlBytes, err := yaml.Marshal(alignConfigKeys(data, reflect.TypeOf(ptr)))
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(yamlBytes, ptr)
+}
+
+// 将键名修改为和结构体中yaml标签一致的大小写
+func alignConfigKeys(value interface{}, valueType reflect.Type) interface{} {
+	for valueType != nil && valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	if valueType == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case string:
+		// 环境变量展开后的值都是字符串，字段为布尔值、数字或者列表时才转换
+		if isConfigScalarTarget(valueType) {
+			return parseConfigScalar(v)
+		}
+	case map[string]interface{}:
+		var result = map[string]interface{}{}
+		switch valueType.Kind() {
+		case reflect.Struct:
+			var fields = map[string]reflect.StructField{} // lower(name) => field
+			for i := 0; i < valueType.NumField(); i++ {
+				var field = valueType.Field(i)
+				if len(field.PkgPath) > 0 {
+					continue
+				}
+				var name = strings.Split(field.Tag.Get("yaml"), ",")[0]
+				if name == "-" {
+					continue
+				}
+				if len(name) == 0 {
+					name = strings.ToLower(field.Name)
+				}
+				fields[strings.ToLower(name)] = field
+			}
+			for key, childValue := range v {
+				field, ok := fields[strings.ToLower(key)]
+				if !ok {
+					result[key] = childValue
+					continue
+				}
+				var name = strings.Split(field.Tag.Get("yaml"), ",")[0]
+				if len(name) == 0 {
+					name = strings.ToLower(field.Name)
+				}
+				result[name] = alignConfigKeys(childValue, field.Type)
+			}
+			return result
+		case reflect.Map:
+			for key, childValue := range v {
+				result[key] = alignConfigKeys(childValue, valueType.Elem())
+			}
+			return result
+		}
+	case []interface{}:
+		if valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array {
+			var result = []interface{}{}
+			for _, item := range v {
+				result = append(result, alignConfigKeys(item, valueType.Elem()))
+			}
+			return result
+		}
+	}
+	return value
+}
+
+// 判断字符串是否需要按照字段的类型转换
+func isConfigScalarTarget(valueType reflect.Type) bool {
+	if reflect.PtrTo(valueType).Implements(configUnmarshalerType) {
+		return false
+	}
+	switch valueType.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// MergeConfig 将 overlay 深度合并到 base 中，并返回 base
+func MergeConfig(base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for key, value := range overlay {
+		overlayMap, ok := value.(map[string]interface{})
+		if ok {
+			baseMap, ok := base[key].(map[string]interface{})
+			if ok {
+				base[key] = MergeConfig(baseMap, overlayMap)
+				continue
+			}
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// MaskConfig 复制配置数据，并隐藏其中的密码等敏感信息
+func MaskConfig(data map[string]interface{}) map[string]interface{} {
+	return maskConfigValue("", data).(map[string]interface{})
+}
+
+func maskConfigValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var result = map[string]interface{}{}
+		for childKey, childValue := range v {
+			result[childKey] = maskConfigValue(childKey, childValue)
+		}
+		return result
+	case []interface{}:
+		var result = []interface{}{}
+		for _, item := range v {
+			result = append(result, maskConfigValue(key, item))
+		}
+		return result
+	case string:
+		if len(v) == 0 {
+			return v
+		}
+		if configSecretKeyReg.MatchString(key) {
+			return "******"
+		}
+		if strings.EqualFold(key, "dsn") {
+			v = configDSNPasswordReg.ReplaceAllString(v, "${1}:******@")
+		}
+		return configURLPasswordReg.ReplaceAllString(v, "${1}:******@")
+	}
+	return value
+}
+
+// 读取单个配置文件
+func readConfigFile(path string) (map[string]interface{}, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = yaml.Unmarshal(fileBytes, &value)
+	if err != nil {
+		return nil, errors.New("decode '" + path + "' failed: " + err.Error())
+	}
+	if value == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	value = expandConfigValue(normalizeConfigValue(value))
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("decode '" + path + "' failed: root should be a map")
+	}
+	return data, nil
+}
+
+// 将 map[interface{}]interface{} 转换为 map[string]interface{}
+func normalizeConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		var result = map[string]interface{}{}
+		for key, childValue := range v {
+			result[fmt.Sprint(key)] = normalizeConfigValue(childValue)
+		}
+		return result
+	case map[string]interface{}:
+		for key, childValue := range v {
+			v[key] = normalizeConfigValue(childValue)
+		}
+		return v
+	case []interface{}:
+		for index, item := range v {
+			v[index] = normalizeConfigValue(item)
+		}
+		return v
+	}
+	return value
+}
+
+// 展开字符串中的环境变量
+func expandConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, childValue := range v {
+			v[key] = expandConfigValue(childValue)
+		}
+		return v
+	case []interface{}:
+		for index, item := range v {
+			v[index] = expandConfigValue(item)
+		}
+		return v
+	case string:
+		if !strings.Contains(v, "${") {
+			return v
+		}
+
+		// 展开后仍然作为字符串，由 DecodeConfig() 根据字段的类型转换，以免 1.50、007 这样的字符串被改变
+		return ExpandConfigString(v)
+	}
+	return value
+}
+
+// ExpandConfigString 展开字符串中的 ${VAR} 和 ${VAR:-default}，$${VAR} 会被替换为 ${VAR}
+func ExpandConfigString(s string) string {
+	return configVariableReg.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		var pieces = configVariableReg.FindStringSubmatch(match)
+		value, ok := os.LookupEnv(pieces[1])
+		if (!ok || len(value) == 0) && len(pieces[2]) > 0 {
+			return pieces[3]
+		}
+		return value
+	})
+}
+
+// 使用环境变量覆盖选项
+func applyConfigEnvVars(data map[string]interface{}, envPrefix string) {
+	if len(envPrefix) == 0 {
+		return
+	}
+
+	var envVars = os.Environ()
+	sort.Strings(envVars)
+	for _, envVar := range envVars {
+		index := strings.Index(envVar, "=")
+		if index <= 0 {
+			continue
+		}
+		var name = envVar[:index]
+		if len(name) <= len(envPrefix) || !strings.EqualFold(name[:len(envPrefix)], envPrefix) {
+			continue
+		}
+		var keys = strings.Split(name[len(envPrefix):], "__")
+		setConfigValue(data, keys, envVar[index+1:])
+	}
+}
+
+// 设置某个选项的值，键名不区分大小写
+func setConfigValue(data map[string]interface{}, keys []string, value interface{}) {
+	for index, key := range keys {
+		if len(key) == 0 {
+			return
+		}
+
+		// 查找已有的键
+		var realKey = strings.ToLower(key)
+		for existKey := range data {
+			if strings.EqualFold(existKey, key) {
+				realKey = existKey
+				break
+			}
+		}
+
+		if index == len(keys)-1 {
+			data[realKey] = value
+			return
+		}
+
+		child, ok := data[realKey].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			data[realKey] = child
+		}
+		data = child
+	}
+}
+
+// 解析单个值，支持布尔值、数字和 [a, b] 形式的列表，其他的值作为字符串
+func parseConfigScalar(s string) interface{} {
+	var lower = strings.ToLower(s)
+	if lower == "true" || lower == "false" {
+		return lower == "true"
+	}
+	if configIntReg.MatchString(s) {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return int(i)
+		}
+	}
+	if configFloatReg.MatchString(s) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil {
+			return f
+		}
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		var list = []interface{}{}
+		err := yaml.Unmarshal([]byte(s), &list)
+		if err == nil {
+			return normalizeConfigValue(list)
+		}
+	}
+	return s
+}
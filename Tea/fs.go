@@ -0,0 +1,101 @@
+package Tea
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var viewsFS fs.FS
+var publicFS fs.FS
+
+// SetViewsFS 设置视图文件系统，比如 embed.FS
+// 文件系统的根目录对应 ViewsDir()，可以使用 fs.Sub() 取得子目录
+// 开发环境（dev）下如果 ViewsDir() 目录存在，仍然从磁盘读取以便修改后立即生效
+func SetViewsFS(fsys fs.FS) {
+	viewsFS = fsys
+}
+
+// SetPublicFS 设置公共资源文件系统，用法同 SetViewsFS()
+func SetPublicFS(fsys fs.FS) {
+	publicFS = fsys
+}
+
+// ViewsFS 取得当前使用的视图文件系统
+func ViewsFS() fs.FS {
+	if useFS(viewsFS, ViewsDir()) {
+		return viewsFS
+	}
+	return os.DirFS(ViewsDir())
+}
+
+// PublicFS 取得当前使用的公共资源文件系统
+func PublicFS() fs.FS {
+	if useFS(publicFS, PublicDir()) {
+		return publicFS
+	}
+	return os.DirFS(PublicDir())
+}
+
+// StatFile 读取文件信息
+// 如果文件在 ViewsDir() 或 PublicDir() 中，并且设置了对应的文件系统，则从文件系统中读取
+func StatFile(path string) (fs.FileInfo, error) {
+	fsys, name, ok := lookupFS(path)
+	if ok {
+		return fs.Stat(fsys, name)
+	}
+	return os.Stat(path)
+}
+
+// ReadFile 读取文件内容，查找方式同 StatFile()
+func ReadFile(path string) ([]byte, error) {
+	fsys, name, ok := lookupFS(path)
+	if ok {
+		return fs.ReadFile(fsys, name)
+	}
+	return os.ReadFile(path)
+}
+
+// 是否使用设置的文件系统
+func useFS(fsys fs.FS, dir string) bool {
+	if fsys == nil {
+		return false
+	}
+	if Env == EnvDev {
+		stat, err := os.Stat(dir)
+		if err == nil && stat.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// 查找文件所在的文件系统，返回文件系统和相对路径
+func lookupFS(path string) (fsys fs.FS, name string, ok bool) {
+	path = filepath.ToSlash(path)
+	for _, item := range []struct {
+		fsys fs.FS
+		dir  string
+	}{
+		{viewsFS, ViewsDir()},
+		{publicFS, PublicDir()},
+	} {
+		if item.fsys == nil {
+			continue
+		}
+		var prefix = strings.TrimSuffix(filepath.ToSlash(item.dir), "/") + "/"
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if !useFS(item.fsys, item.dir) {
+			return nil, "", false
+		}
+		name = filepath.ToSlash(filepath.Clean(strings.TrimPrefix(path, prefix)))
+		if !fs.ValidPath(name) {
+			return nil, "", false
+		}
+		return item.fsys, name, true
+	}
+	return nil, "", false
+}
@@ -0,0 +1,61 @@
+package Tea
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestViewsFS(t *testing.T) {
+	var oldViewsDir = ViewsDir()
+	var oldEnv = Env
+	defer func() {
+		SetViewsDir(oldViewsDir)
+		SetViewsFS(nil)
+		Env = oldEnv
+	}()
+
+	var dir = t.TempDir()
+	err := os.WriteFile(dir+"/index.html", []byte("disk"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetViewsDir(dir)
+	SetViewsFS(fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("embed")},
+	})
+
+	// 开发环境下优先读取磁盘
+	Env = EnvDev
+	data, err := ReadFile(dir + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "disk" {
+		t.Fatal("should read from disk in dev, but got '" + string(data) + "'")
+	}
+
+	Env = EnvProd
+	data, err = ReadFile(dir + "/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "embed" {
+		t.Fatal("should read from fs in prod, but got '" + string(data) + "'")
+	}
+	_, err = StatFile(dir + "/../index.html")
+	if err == nil {
+		t.Fatal("file outside views dir should not be found in fs")
+	}
+
+	// 开发环境下目录不存在时也从文件系统读取
+	Env = EnvDev
+	SetViewsDir(dir + "/not-found")
+	data, err = ReadFile(dir + "/not-found/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "embed" {
+		t.Fatal("should read from fs, but got '" + string(data) + "'")
+	}
+}
@@ -0,0 +1,229 @@
+package TeaGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iwind/TeaGo/logs"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLog 访问日志
+type AccessLog struct {
+	Time       time.Time     // 请求开始时间
+	Request    *http.Request // 请求
+	Status     int           // 状态码
+	Bytes      int           // 输出的内容长度
+	Cost       time.Duration // 耗时
+	RequestId  string        // 请求ID，从X-Request-Id中读取
+	RemoteAddr string        // 连接的客户端地址
+	UpstreamIP string        // 经过代理时的原始客户端IP，从X-Real-IP或X-Forwarded-For中读取
+}
+
+// 构造访问日志
+func newAccessLog(t time.Time, response *responseWriter, request *http.Request) *AccessLog {
+	var accessLog = &AccessLog{
+		Time:       t,
+		Request:    request,
+		Status:     response.status,
+		Bytes:      response.bytes,
+		Cost:       time.Since(t),
+		RequestId:  request.Header.Get("X-Request-Id"),
+		RemoteAddr: request.RemoteAddr,
+	}
+
+	var upstreamIP = strings.TrimSpace(request.Header.Get("X-Real-IP"))
+	if len(upstreamIP) == 0 {
+		var forwardedFor = request.Header.Get("X-Forwarded-For")
+		index := strings.Index(forwardedFor, ",")
+		if index > -1 {
+			forwardedFor = forwardedFor[:index]
+		}
+		upstreamIP = strings.TrimSpace(forwardedFor)
+	}
+	accessLog.UpstreamIP = upstreamIP
+
+	return accessLog
+}
+
+// RemoteIP 取得客户端IP，不包含端口
+func (this *AccessLog) RemoteIP() string {
+	host, _, err := net.SplitHostPort(this.RemoteAddr)
+	if err != nil {
+		return this.RemoteAddr
+	}
+	return host
+}
+
+// CostMs 取得以毫秒为单位的耗时
+func (this *AccessLog) CostMs() float64 {
+	return float64(this.Cost.Nanoseconds()) / 1000000
+}
+
+// AccessLogFormatter 访问日志格式
+type AccessLogFormatter interface {
+	// Format 将访问日志格式化为一行或多行文本，不需要包含末尾的换行符
+	Format(accessLog *AccessLog) string
+}
+
+// DefaultAccessLogFormatter 默认的多行格式
+type DefaultAccessLogFormatter struct {
+	Colored bool // 是否使用颜色标签
+}
+
+func (this *DefaultAccessLogFormatter) Format(accessLog *AccessLog) string {
+	var request = accessLog.Request
+	var requestFormat = "Request:\"%s %s %s\""
+	if this.Colored {
+		var tag = "ok"
+		if accessLog.Status >= 400 {
+			tag = "error"
+		}
+		requestFormat = "<" + tag + ">" + requestFormat + "</" + tag + ">"
+	}
+
+	return logs.Sprintf("\n  "+requestFormat+"\n  RemoteAddr:%s\n  Status:%d\n  Bytes:%d\n  Referer:\"%s\"\n  UserAgent:\"%s\"\n  Cost:%.3fms",
+		request.Method, request.RequestURI, request.Proto, accessLog.RemoteAddr, accessLog.Status, accessLog.Bytes,
+		request.Referer(), request.UserAgent(), accessLog.CostMs())
+}
+
+// CommonAccessLogFormatter Common Log Format
+// 比如：127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+type CommonAccessLogFormatter struct {
+}
+
+func (this *CommonAccessLogFormatter) Format(accessLog *AccessLog) string {
+	var request = accessLog.Request
+	var user = "-"
+	if request.URL != nil && request.URL.User != nil && len(request.URL.User.Username()) > 0 {
+		user = request.URL.User.Username()
+	} else if username, _, ok := request.BasicAuth(); ok && len(username) > 0 {
+		user = username
+	}
+
+	var bytes = "-"
+	if accessLog.Bytes > 0 {
+		bytes = strconv.Itoa(accessLog.Bytes)
+	}
+
+	return accessLog.RemoteIP() + " - " + user + " [" + accessLog.Time.Format("02/Jan/2006:15:04:05 -0700") + "] " +
+		strconv.Quote(request.Method+" "+request.RequestURI+" "+request.Proto) + " " +
+		strconv.Itoa(accessLog.Status) + " " + bytes
+}
+
+// CombinedAccessLogFormatter Combined Log Format，在Common Log Format基础上加入Referer和User-Agent
+type CombinedAccessLogFormatter struct {
+	CommonAccessLogFormatter
+}
+
+func (this *CombinedAccessLogFormatter) Format(accessLog *AccessLog) string {
+	return this.CommonAccessLogFormatter.Format(accessLog) + " " +
+		strconv.Quote(accessLog.Request.Referer()) + " " +
+		strconv.Quote(accessLog.Request.UserAgent())
+}
+
+// JSONAccessLogFormatter 每条日志为一行JSON
+type JSONAccessLogFormatter struct {
+}
+
+func (this *JSONAccessLogFormatter) Format(accessLog *AccessLog) string {
+	var request = accessLog.Request
+	data, err := json.Marshal(map[string]interface{}{
+		"time":       accessLog.Time.Format(time.RFC3339Nano),
+		"requestId":  accessLog.RequestId,
+		"remoteAddr": accessLog.RemoteAddr,
+		"upstreamIP": accessLog.UpstreamIP,
+		"method":     request.Method,
+		"uri":        request.RequestURI,
+		"proto":      request.Proto,
+		"host":       request.Host,
+		"status":     accessLog.Status,
+		"bytes":      accessLog.Bytes,
+		"referer":    request.Referer(),
+		"userAgent":  request.UserAgent(),
+		"cost":       accessLog.CostMs(),
+	})
+	if err != nil {
+		return "{\"error\":" + strconv.Quote(err.Error()) + "}"
+	}
+	return string(data)
+}
+
+var accessLogVariableReg = regexp.MustCompile(`\$\{([\w.-]+)\}`)
+
+// TemplateAccessLogFormatter 自定义模板格式
+// 模板中可以使用 ${变量名}，支持的变量有：
+// time, timeISO8601, timeLocal, requestId, remoteAddr, remoteIP, upstreamIP,
+// method, uri, path, proto, host, status, bytes, referer, userAgent, cost（毫秒）, costSeconds,
+// 以及 header.名称，比如 ${header.X-Trace-Id}
+type TemplateAccessLogFormatter struct {
+	Template string
+}
+
+func (this *TemplateAccessLogFormatter) Format(accessLog *AccessLog) string {
+	var request = accessLog.Request
+	return accessLogVariableReg.ReplaceAllStringFunc(this.Template, func(s string) string {
+		var name = s[2 : len(s)-1]
+		switch name {
+		case "time":
+			return accessLog.Time.Format("2006-01-02 15:04:05")
+		case "timeISO8601":
+			return accessLog.Time.Format(time.RFC3339)
+		case "timeLocal":
+			return accessLog.Time.Format("02/Jan/2006:15:04:05 -0700")
+		case "requestId":
+			return accessLog.RequestId
+		case "remoteAddr":
+			return accessLog.RemoteAddr
+		case "remoteIP":
+			return accessLog.RemoteIP()
+		case "upstreamIP":
+			return accessLog.UpstreamIP
+		case "method":
+			return request.Method
+		case "uri":
+			return request.RequestURI
+		case "path":
+			return request.URL.Path
+		case "proto":
+			return request.Proto
+		case "host":
+			return request.Host
+		case "status":
+			return strconv.Itoa(accessLog.Status)
+		case "bytes":
+			return strconv.Itoa(accessLog.Bytes)
+		case "referer":
+			return request.Referer()
+		case "userAgent":
+			return request.UserAgent()
+		case "cost":
+			return fmt.Sprintf("%.3f", accessLog.CostMs())
+		case "costSeconds":
+			return fmt.Sprintf("%.6f", accessLog.Cost.Seconds())
+		}
+		if strings.HasPrefix(name, "header.") {
+			return request.Header.Get(name[len("header."):])
+		}
+		return s
+	})
+}
+
+// NewAccessLogFormatter 根据名称构造访问日志格式：default, common, combined, json，其他的值作为模板
+func NewAccessLogFormatter(format string) AccessLogFormatter {
+	switch format {
+	case "", "default":
+		return &DefaultAccessLogFormatter{Colored: true}
+	case "common":
+		return &CommonAccessLogFormatter{}
+	case "combined":
+		return &CombinedAccessLogFormatter{}
+	case "json":
+		return &JSONAccessLogFormatter{}
+	}
+	return &TemplateAccessLogFormatter{Template: format}
+}
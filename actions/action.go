@@ -1,7 +1,7 @@
 package actions
 
 import (
-	"github.com/iwind/TeaGo/lists"
+	"fmt"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/types"
 	"net/http"
@@ -11,7 +11,6 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
-	"unicode"
 )
 
 type Params map[string][]string
@@ -67,6 +66,28 @@ func runActionCopy(spec *ActionSpec,
 
 	actionObject.Spec = spec
 
+	// 关闭事件流
+	defer func() {
+		if actionObject.eventStream != nil {
+			actionObject.eventStream.Close()
+		}
+	}()
+
+	// 释放通过 Ctx() 创建的上下文
+	defer actionObject.cancelCtx()
+
+	// 删除流式上传的临时文件
+	defer func() {
+		removeUploadTmpFiles(actionObject.Files)
+	}()
+
+	// 关闭请求范围内注入的值
+	var scope *containerScope
+	if spec.Container != nil {
+		scope = spec.Container.newScope(actionObject)
+		defer scope.close()
+	}
+
 	// 执行helper.AfterAction()
 	defer func() {
 		if len(afterFuncs) > 0 {
@@ -103,10 +124,21 @@ func runActionCopy(spec *ActionSpec,
 		if ok {
 			actionObject.Message = message
 			actionObject.failWithoutPanic()
-		} else {
-			errObject, ok := err.(error)
-			if ok {
-				logs.Errorf("%s\n~~~\n%s", errObject.Error(), string(debug.Stack()))
+			return
+		}
+
+		// 其他错误
+		errObject, ok := err.(error)
+		if !ok {
+			errObject = fmt.Errorf("%v", err)
+		}
+		logs.Errorf("%s\n~~~\n%s", errObject.Error(), string(debug.Stack()))
+
+		if responseWriter != nil {
+			if spec.ErrorHandler != nil {
+				spec.ErrorHandler(responseWriter, request, http.StatusInternalServerError, errObject)
+			} else {
+				http.Error(responseWriter, "500 Internal Server Error", http.StatusInternalServerError)
 			}
 		}
 	}()
@@ -128,8 +160,29 @@ func runActionCopy(spec *ActionSpec,
 	actionObject.ViewDir("@" + actionPkg[:separatorIndex])
 	actionObject.View(actionPkg[separatorIndex+1:] + "/" + strings.ToLower(actionClass[0:1]) + actionClass[1:])
 
+	// 解析JSON、XML和YAML格式的请求体
+	if params == nil {
+		params = Params{}
+	}
+	bodyErr := parseRequestBody(request, params, maxSize)
+	if bodyErr != nil {
+		var code = http.StatusBadRequest
+		if e, ok := bodyErr.(*bodyError); ok {
+			code = e.code
+		}
+		if responseWriter != nil {
+			if spec.ErrorHandler != nil {
+				spec.ErrorHandler(responseWriter, request, code, bodyErr)
+			} else {
+				http.Error(responseWriter, strconv.Itoa(code)+" "+http.StatusText(code), code)
+			}
+		}
+		return
+	}
+
 	// 设置变量
 	actionObject.Module = spec.Module
+	actionObject.Host = spec.Host
 	actionObject.Request = request
 	if responseWriter != nil {
 		actionObject.ResponseWriter = responseWriter
@@ -146,8 +199,30 @@ func runActionCopy(spec *ActionSpec,
 
 	// 读取上传的文件
 	actionObject.Files = []*File{}
-	if request.MultipartForm != nil {
+	var uploadErrors = []ActionParamError{}
+	if spec.StreamUpload && request.MultipartForm == nil && isMultipartRequest(request) {
+		var uploadErr error
+		uploadErrors, uploadErr = streamRequestFiles(actionObject, params, parseUploadRules(spec, request.Method), spec.UploadProgress)
+		if uploadErr != nil {
+			var code = http.StatusBadRequest
+			if e, ok := uploadErr.(*bodyError); ok {
+				code = e.code
+			} else {
+				code = http.StatusInternalServerError
+				logs.Error(uploadErr)
+			}
+			if responseWriter != nil {
+				if spec.ErrorHandler != nil {
+					spec.ErrorHandler(responseWriter, request, code, uploadErr)
+				} else {
+					http.Error(responseWriter, strconv.Itoa(code)+" "+http.StatusText(code), code)
+				}
+			}
+			return
+		}
+	} else if request.MultipartForm != nil {
 		parseRequestFiles(actionObject)
+		uploadErrors = checkUploadFiles(actionObject.Files, parseUploadRules(spec, request.Method))
 	}
 
 	// 初始化
@@ -159,6 +234,11 @@ func runActionCopy(spec *ActionSpec,
 		}
 	}
 
+	// 注入Action结构体字段
+	if scope != nil {
+		scope.injectStruct(actionPtrValue.Elem())
+	}
+
 	// 执行Helpers
 	for _, helper := range helpers {
 		helperValue := reflect.ValueOf(helper)
@@ -235,6 +315,12 @@ func runActionCopy(spec *ActionSpec,
 	}
 	var argValue = reflect.Indirect(reflect.New(argType))
 	var countFields = argValue.NumField()
+	var paramTree *paramNode
+	var binder = &paramBinder{}
+	var injectedFields map[int]bool
+	if scope != nil {
+		injectedFields = scope.injectStruct(argValue)
+	}
 	for i := 0; i < countFields; i++ {
 		var field = argType.Field(i)
 		var fieldName = field.Name
@@ -248,6 +334,11 @@ func runActionCopy(spec *ActionSpec,
 			continue
 		}
 
+		// 已经注入的字段
+		if injectedFields[i] {
+			continue
+		}
+
 		// 初始化特殊类型的参数
 		switch fieldValue.Interface().(type) {
 		case *File: // 支持文件指针
@@ -263,6 +354,19 @@ func runActionCopy(spec *ActionSpec,
 				fieldValue.Set(reflect.ValueOf(filePtr))
 			}
 			continue
+		case []*File: // 支持多个文件
+			bindName, ok := field.Tag.Lookup("field")
+			if ok {
+				fieldValue.Set(reflect.ValueOf(actionObject.FieldFiles(bindName)))
+			} else {
+				fileList := actionObject.FieldFiles(fieldName)
+				if len(fileList) == 0 {
+					var lowerFirstName = strings.ToLower(string(fieldName[0])) + fieldName[1:]
+					fileList = actionObject.FieldFiles(lowerFirstName)
+				}
+				fieldValue.Set(reflect.ValueOf(fileList))
+			}
+			continue
 		case File: // 支持文件
 			bindName, ok := field.Tag.Lookup("field")
 			if ok {
@@ -284,7 +388,7 @@ func runActionCopy(spec *ActionSpec,
 		}
 
 		// 执行方法 Helper 方法
-		if fieldValue.NumMethod() > 0 {
+		if isActionHelperType(field.Type) {
 			// 初始化
 			if fieldValue.IsNil() {
 				fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
@@ -316,6 +420,11 @@ func runActionCopy(spec *ActionSpec,
 					}
 				}
 			}
+
+			// Helper不需要绑定参数
+			if helperMethod.IsValid() || fieldValue.MethodByName("AfterAction").IsValid() {
+				continue
+			}
 		}
 
 		// alias:"别名"
@@ -325,19 +434,18 @@ func runActionCopy(spec *ActionSpec,
 		}
 
 		// cookie:"Cookie参数名"
-		var fieldParamValue []string
-		var hasValue = false
+		var fieldNode *paramNode
+		var hasTagValue = false
 
 		cookieName, ok := field.Tag.Lookup("cookie")
-		var hasTagValue = false
 		if ok && len(cookieName) > 0 {
 			hasTagValue = true
 			cookieValue, err := request.Cookie(cookieName)
-			if err != nil {
-				hasValue = false
-			} else {
-				fieldParamValue = []string{strings.TrimSpace(cookieValue.Value)}
-				hasValue = len(fieldParamValue) > 0
+			if err == nil {
+				fieldNode = &paramNode{
+					path:   fieldName,
+					values: []string{strings.TrimSpace(cookieValue.Value)},
+				}
 			}
 		}
 
@@ -349,79 +457,54 @@ func runActionCopy(spec *ActionSpec,
 			session := actionPtrValue.Interface().(ActionWrapper).Object().Session()
 			if session != nil {
 				sessionValue := session.GetString(sessionName)
-				fieldParamValue = []string{strings.TrimSpace(sessionValue)}
-				hasValue = len(fieldParamValue) > 0
+				fieldNode = &paramNode{
+					path:   fieldName,
+					values: []string{strings.TrimSpace(sessionValue)},
+				}
 			}
 		}
 
-		// 从request参数中读取
+		// 从request参数中读取，支持 user[name]、items[0][id] 和 filter.status 形式的嵌套参数
 		if !hasTagValue {
-			fieldParamValue, hasValue = getActionParamFuzzy(&params, fieldName)
+			if paramTree == nil {
+				paramTree = newParamTree(params)
+			}
+			fieldNode = paramTree.lookup(fieldName)
 		}
 
 		// default:"默认值"
-		// 注意：DefaultValue不对字符串进行Trim()处理
-		if !hasValue || (len(fieldParamValue) == 0 || len(fieldParamValue[0]) == 0) {
+		if fieldNode == nil || fieldNode.isEmpty() {
 			defaultValue, ok := field.Tag.Lookup("default")
 			if ok {
-				hasValue = true
-				fieldParamValue = []string{defaultValue}
+				fieldNode = &paramNode{
+					path:   fieldName,
+					values: []string{defaultValue},
+				}
 			}
 		}
 
-		if hasValue {
-			firstParamValue := strings.TrimFunc(fieldParamValue[0], unicode.IsSpace)
-			switch field.Type.Kind() {
-			case reflect.Int:
-				fieldValue.Set(reflect.ValueOf(types.Int(firstParamValue)))
-			case reflect.Int8:
-				fieldValue.Set(reflect.ValueOf(types.Int8(firstParamValue)))
-			case reflect.Int16:
-				fieldValue.Set(reflect.ValueOf(types.Int16(firstParamValue)))
-			case reflect.Int32:
-				fieldValue.Set(reflect.ValueOf(types.Int32(firstParamValue)))
-			case reflect.Int64:
-				fieldValue.Set(reflect.ValueOf(types.Int64(firstParamValue)))
-			case reflect.Uint:
-				fieldValue.Set(reflect.ValueOf(types.Uint(firstParamValue)))
-			case reflect.Uint8:
-				fieldValue.Set(reflect.ValueOf(types.Uint8(firstParamValue)))
-			case reflect.Uint16:
-				fieldValue.Set(reflect.ValueOf(types.Uint16(firstParamValue)))
-			case reflect.Uint32:
-				fieldValue.Set(reflect.ValueOf(types.Uint32(firstParamValue)))
-			case reflect.Uint64:
-				fieldValue.Set(reflect.ValueOf(types.Uint64(firstParamValue)))
-			case reflect.Bool:
-				if lists.Contains([]string{"on", "true", "yes", "enabled"}, firstParamValue) {
-					fieldValue.SetBool(true)
-				} else {
-					fieldValue.SetBool(types.Bool(firstParamValue))
-				}
-			case reflect.String:
-				fieldValue.Set(reflect.ValueOf(firstParamValue))
-			case reflect.Float32:
-				fieldValue.Set(reflect.ValueOf(types.Float32(firstParamValue)))
-			case reflect.Float64:
-				fieldValue.Set(reflect.ValueOf(types.Float64(firstParamValue)))
-			case reflect.Slice:
-				elemKind := field.Type.Elem().Kind()
-				if elemKind == reflect.Uint8 { // 字节
-					fieldValue.SetBytes([]byte(firstParamValue))
-				} else { // slice
-					sliceValue, err := types.Slice(fieldParamValue, field.Type)
-					if err == nil {
-						// trim right spaces in string value
-						if stringSlice, ok := sliceValue.([]string); ok {
-							for k, v := range stringSlice {
-								stringSlice[k] = strings.TrimFunc(v, unicode.IsSpace)
-							}
-						}
-						fieldValue.Set(reflect.ValueOf(sliceValue))
-					}
-				}
-			}
+		var paramPath = fieldName
+		if fieldNode != nil && len(fieldNode.path) > 0 {
+			paramPath = fieldNode.path
+		}
+		binder.bind(fieldValue, fieldNode, paramPath, field.Tag)
+	}
+
+	// 参数格式错误，或者没有通过 validate 标签中的校验
+	var paramErrors = uploadErrors
+	if len(paramErrors) == 0 {
+		paramErrors = binder.errors
+	}
+	if len(paramErrors) == 0 {
+		paramErrors = spec.validateParams(argValue)
+	}
+	if len(paramErrors) > 0 {
+		actionObject.errors = paramErrors
+		if len(actionObject.Message) == 0 {
+			actionObject.Message = paramErrors[0].Messages[0]
 		}
+		actionObject.failWithoutPanic()
+		return
 	}
 
 	// Before
@@ -638,27 +721,6 @@ func runHelperMethodAfter(method reflect.Value, actionPtr reflect.Value, field r
 	return true
 }
 
-func getActionParam(params *Params, name string) (value []string, has bool) {
-	values, ok := (*params)[name]
-	if !ok {
-		return nil, false
-	}
-	if len(values) == 0 {
-		return nil, false
-	}
-	return values, true
-}
-
-func getActionParamFuzzy(params *Params, name string) (value []string, has bool) {
-	value, hasValue := getActionParam(params, name)
-	if hasValue {
-		return value, true
-	}
-
-	var lowerFirstName = strings.ToLower(string(name[0])) + name[1:]
-	return getActionParam(params, lowerFirstName)
-}
-
 func parseRequestFiles(action *ActionObject) {
 	err := action.Request.ParseMultipartForm(128 << 20)
 	if err != nil {
@@ -679,6 +741,7 @@ func parseRequestFiles(action *ActionObject) {
 			file.Ext = strings.ToLower(filepath.Ext(header.Filename))
 			file.ContentType = header.Header.Get("Content-Type")
 			file.OriginFile = header
+			file.MimeType = sniffUploadFile(header)
 			action.Files = append(action.Files, file)
 		}
 	}
@@ -0,0 +1,460 @@
+package actions
+
+import (
+	"encoding"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// 没有设置 layout 标签时支持的时间格式
+var paramTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// 切片参数中允许的最大稀疏索引，索引小于参数数量时不受此限制
+const paramMaxSliceIndex = 1000
+
+// 参数树中的节点
+// user[name]、items[0][id] 和 filter.status 形式的参数名会被拆分为多级节点
+type paramNode struct {
+	path     string // 使用 . 连接的完整参数名
+	values   []string
+	children map[string]*paramNode
+}
+
+// 根据参数构造参数树
+func newParamTree(params Params) *paramNode {
+	var root = &paramNode{}
+
+	// 按照参数名排序，以便同一个节点的值顺序固定
+	var keys = []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var node = root
+		for _, segment := range splitParamKey(key) {
+			node = node.addChild(segment)
+		}
+		if node != root {
+			node.values = append(node.values, params[key]...)
+		}
+	}
+	return root
+}
+
+// 拆分参数名，比如 items[0][id] 和 items.0.id 都会拆分为 items、0、id，tags[] 会拆分为 tags
+func splitParamKey(key string) []string {
+	var segments = []string{}
+	var segment = strings.Builder{}
+	var flush = func() {
+		if segment.Len() > 0 {
+			segments = append(segments, segment.String())
+			segment.Reset()
+		}
+	}
+	for _, c := range key {
+		switch c {
+		case '.', '[', ']':
+			flush()
+		default:
+			segment.WriteRune(c)
+		}
+	}
+	flush()
+	return segments
+}
+
+func (this *paramNode) addChild(name string) *paramNode {
+	if this.children == nil {
+		this.children = map[string]*paramNode{}
+	}
+	child, ok := this.children[name]
+	if !ok {
+		var path = name
+		if len(this.path) > 0 {
+			path = this.path + "." + name
+		}
+		child = &paramNode{
+			path: path,
+		}
+		this.children[name] = child
+	}
+	return child
+}
+
+// 查找子节点，名称可以包含多级，每一级都会尝试首字母小写的形式
+func (this *paramNode) lookup(name string) *paramNode {
+	var node = this
+	for _, segment := range splitParamKey(name) {
+		if node.children == nil {
+			return nil
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			child, ok = node.children[strings.ToLower(segment[:1])+segment[1:]]
+			if !ok {
+				return nil
+			}
+		}
+		node = child
+	}
+	if node == this {
+		return nil
+	}
+	return node
+}
+
+// 是否没有任何值
+func (this *paramNode) isEmpty() bool {
+	return len(this.children) == 0 && (len(this.values) == 0 || len(this.values[0]) == 0)
+}
+
+// 子节点是否全部为数字下标，返回最大的下标
+func (this *paramNode) maxIndex() (maxIndex int, ok bool) {
+	if len(this.children) == 0 {
+		return -1, false
+	}
+	maxIndex = -1
+	for name := range this.children {
+		index, err := strconv.Atoi(name)
+		if err != nil || index < 0 {
+			return -1, false
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+	return maxIndex, true
+}
+
+// 参数绑定
+type paramBinder struct {
+	errors []ActionParamError
+}
+
+// 将参数节点的值绑定到变量
+func (this *paramBinder) bind(value reflect.Value, node *paramNode, path string, tag reflect.StructTag) {
+	if node == nil || !value.CanSet() {
+		return
+	}
+	var valueType = value.Type()
+
+	// 指针，没有值时保持为nil，以便区分是否传入了参数
+	if valueType.Kind() == reflect.Ptr {
+		if len(node.children) == 0 && (len(node.values) == 0 || (len(strings.TrimSpace(node.values[0])) == 0 && valueType.Elem().Kind() != reflect.String)) {
+			return
+		}
+		if value.IsNil() {
+			var elemValue = reflect.New(valueType.Elem())
+			this.bind(elemValue.Elem(), node, path, tag)
+			value.Set(elemValue)
+		} else {
+			this.bind(value.Elem(), node, path, tag)
+		}
+		return
+	}
+
+	if isParamScalarType(valueType) {
+		if len(node.values) == 0 {
+			return
+		}
+		this.bindScalar(value, node.values[0], path, tag)
+		return
+	}
+
+	switch valueType.Kind() {
+	case reflect.Slice:
+		// 字节
+		if valueType.Elem().Kind() == reflect.Uint8 {
+			if len(node.values) > 0 {
+				value.SetBytes([]byte(strings.TrimFunc(node.values[0], unicode.IsSpace)))
+			}
+			return
+		}
+
+		// items[0]、items[1] 形式
+		maxIndex, ok := node.maxIndex()
+		if ok {
+			// 防止 items[999999999] 之类的参数分配过多内存
+			if maxIndex >= paramMaxSliceIndex && maxIndex >= len(node.children) {
+				this.addError(path, "index "+strconv.Itoa(maxIndex)+" is out of range")
+				return
+			}
+			var sliceValue = reflect.MakeSlice(valueType, maxIndex+1, maxIndex+1)
+			for name, child := range node.children {
+				index, _ := strconv.Atoi(name)
+				this.bind(sliceValue.Index(index), child, child.path, tag)
+			}
+			value.Set(sliceValue)
+			return
+		}
+
+		// items=a&items=b 形式
+		if len(node.values) > 0 {
+			var sliceValue = reflect.MakeSlice(valueType, len(node.values), len(node.values))
+			for index, s := range node.values {
+				var elemValue = sliceValue.Index(index)
+				var elemType = valueType.Elem()
+				if elemType.Kind() == reflect.Ptr {
+					elemValue.Set(reflect.New(elemType.Elem()))
+					elemValue = elemValue.Elem()
+					elemType = elemType.Elem()
+				}
+				if isParamScalarType(elemType) {
+					this.bindScalar(elemValue, s, path, tag)
+				}
+			}
+			value.Set(sliceValue)
+		}
+	case reflect.Array:
+		for name, child := range node.children {
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 || index >= value.Len() {
+				continue
+			}
+			this.bind(value.Index(index), child, child.path, tag)
+		}
+		if len(node.children) == 0 && isParamScalarType(valueType.Elem()) {
+			for index, s := range node.values {
+				if index >= value.Len() {
+					break
+				}
+				this.bindScalar(value.Index(index), s, path, tag)
+			}
+		}
+	case reflect.Map:
+		if len(node.children) == 0 {
+			return
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMapWithSize(valueType, len(node.children)))
+		}
+		var keyType = valueType.Key()
+		if !isParamScalarType(keyType) {
+			return
+		}
+		for name, child := range node.children {
+			var keyValue = reflect.New(keyType).Elem()
+			if !this.bindScalar(keyValue, name, child.path, "") {
+				continue
+			}
+			var elemValue = reflect.New(valueType.Elem()).Elem()
+			this.bind(elemValue, child, child.path, tag)
+			value.SetMapIndex(keyValue, elemValue)
+		}
+	case reflect.Struct:
+		this.bindStruct(value, node)
+	}
+}
+
+// 绑定结构体中的字段
+func (this *paramBinder) bindStruct(value reflect.Value, node *paramNode) {
+	var valueType = value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		var field = valueType.Field(i)
+		var fieldValue = value.Field(i)
+		if !fieldValue.CanSet() {
+			continue
+		}
+
+		var name = field.Name
+		alias, ok := field.Tag.Lookup("alias")
+		if ok && len(alias) > 0 {
+			name = alias
+		} else if field.Anonymous && reflect.Indirect(fieldValue).Kind() == reflect.Struct {
+			// 匿名结构体中的字段和当前结构体中的字段处于同一级
+			this.bind(fieldValue, node, node.path, field.Tag)
+			continue
+		}
+
+		var child = node.lookup(name)
+		var path = name
+		if len(node.path) > 0 {
+			path = node.path + "." + name
+		}
+		if child != nil {
+			path = child.path
+		}
+		if child == nil || child.isEmpty() {
+			defaultValue, ok := field.Tag.Lookup("default")
+			if ok {
+				child = &paramNode{
+					path:   path,
+					values: []string{defaultValue},
+				}
+			}
+		}
+		this.bind(fieldValue, child, path, field.Tag)
+	}
+}
+
+// 转换单个值，失败时记录错误
+func (this *paramBinder) bindScalar(value reflect.Value, s string, path string, tag reflect.StructTag) bool {
+	s = strings.TrimFunc(s, unicode.IsSpace)
+	var valueType = value.Type()
+
+	// 空值保持为零值
+	if len(s) == 0 && valueType.Kind() != reflect.String {
+		return true
+	}
+
+	var fail = func(typeName string) bool {
+		this.addError(path, "invalid "+typeName+" value '"+s+"'")
+		return false
+	}
+
+	// 时间
+	if valueType == timeType {
+		t, ok := parseParamTime(s, tag.Get("layout"))
+		if !ok {
+			return fail("time")
+		}
+		value.Set(reflect.ValueOf(t))
+		return true
+	}
+	if valueType == durationType {
+		// 纯数字表示秒数
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err == nil {
+			value.SetInt(int64(seconds * float64(time.Second)))
+			return true
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fail("duration")
+		}
+		value.SetInt(int64(d))
+		return true
+	}
+
+	// 实现了 encoding.TextUnmarshaler 的类型
+	if reflect.PtrTo(valueType).Implements(textUnmarshalerType) {
+		err := value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		if err != nil {
+			this.addError(path, err.Error())
+			return false
+		}
+		return true
+	}
+
+	switch valueType.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "1", "t", "true", "on", "yes", "y", "enabled":
+			value.SetBool(true)
+		case "0", "f", "false", "off", "no", "n", "disabled":
+			value.SetBool(false)
+		default:
+			return fail("bool")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, valueType.Bits())
+		if err != nil {
+			// 支持 1.0 和 1e3 这样的整数
+			f, floatErr := strconv.ParseFloat(s, 64)
+			if floatErr != nil || f != float64(int64(f)) || value.OverflowInt(int64(f)) {
+				return fail("integer")
+			}
+			i = int64(f)
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, valueType.Bits())
+		if err != nil {
+			f, floatErr := strconv.ParseFloat(s, 64)
+			if floatErr != nil || f < 0 || f != float64(uint64(f)) || value.OverflowUint(uint64(f)) {
+				return fail("unsigned integer")
+			}
+			u = uint64(f)
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, valueType.Bits())
+		if err != nil {
+			return fail("number")
+		}
+		value.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+func (this *paramBinder) addError(path string, message string) {
+	for index, paramError := range this.errors {
+		if paramError.Param == path {
+			this.errors[index].Messages = append(paramError.Messages, message)
+			return
+		}
+	}
+	this.errors = append(this.errors, ActionParamError{
+		Param:    path,
+		Messages: []string{message},
+	})
+}
+
+// 是否为可以从单个字符串转换的类型
+func isParamScalarType(valueType reflect.Type) bool {
+	if valueType == timeType || valueType == durationType {
+		return true
+	}
+	if reflect.PtrTo(valueType).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch valueType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// 是否为Helper类型，比如 *Must、*Gzip，Helper需要实现 BeforeAction() 或 AfterAction() 方法
+// 其他的结构体指针作为普通参数，没有对应的参数时保持为nil
+func isActionHelperType(valueType reflect.Type) bool {
+	return valueType.Kind() == reflect.Ptr &&
+		valueType.Elem().Kind() == reflect.Struct &&
+		(hasMethod(valueType, "BeforeAction") || hasMethod(valueType, "AfterAction"))
+}
+
+// 解析时间
+func parseParamTime(s string, layout string) (time.Time, bool) {
+	if len(layout) > 0 {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		return t, err == nil
+	}
+
+	// 时间戳
+	timestamp, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return time.Unix(timestamp, 0), true
+	}
+
+	for _, layout := range paramTimeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,240 @@
+package actions
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBindAddress struct {
+	City string
+	Zip  int `default:"100000"`
+}
+
+type testBindItem struct {
+	Id    int64
+	Count *int
+}
+
+type testBindAction Action
+
+func (this *testBindAction) RunPost(params struct {
+	User struct {
+		Name    string
+		Address testBindAddress
+	}
+	Items   []testBindItem
+	Filter  map[string]string
+	Scores  map[string]int
+	Page    *int
+	Size    *int
+	Since   time.Time
+	Day     time.Time `layout:"20060102"`
+	Timeout time.Duration
+	IP      net.IP `alias:"ip"`
+	Tags    []string
+}) {
+	this.Data["params"] = params
+	this.Success()
+}
+
+func TestParamTree(t *testing.T) {
+	var tree = newParamTree(Params{
+		"user[name]":        {"Lu"},
+		"items[0][id]":      {"1"},
+		"items.1.id":        {"2"},
+		"tags[]":            {"a", "b"},
+		"filter.status":     {"on"},
+		"Filter[type]":      {"x"},
+		"":                  {"empty"},
+		"user[address]city": {"Beijing"},
+	})
+	for _, key := range []string{"user.name", "items.0.id", "items.1.id", "tags", "filter.status", "user.address.city"} {
+		var node = tree.lookup(key)
+		if node == nil || len(node.values) == 0 {
+			t.Fatal("'" + key + "' should be found")
+		}
+		t.Log(key, node.path, node.values)
+	}
+	if !reflect.DeepEqual(splitParamKey("items[0][id]"), []string{"items", "0", "id"}) {
+		t.Fatal("invalid key segments")
+	}
+}
+
+func TestParamBind(t *testing.T) {
+	resp := NewTesting(new(testBindAction)).
+		Method("POST").
+		URL("/bind").
+		Params(Params{
+			"user[name]":          {"Lu"},
+			"user[address][city]": {"Beijing"},
+			"items[0][id]":        {"1"},
+			"items[1][id]":        {"2"},
+			"items[1][count]":     {"5"},
+			"filter[status]":      {"on"},
+			"filter.type":         {"x"},
+			"scores[a]":           {"90"},
+			"page":                {"0"},
+			"since":               {"2024-05-01 10:20:30"},
+			"day":                 {"20240502"},
+			"timeout":             {"1m30s"},
+			"ip":                  {"127.0.0.1"},
+			"tags[]":              {"a", "b"},
+		}).
+		Run(t)
+	t.Log(string(resp.Data))
+
+	var result = struct {
+		Code int `json:"code"`
+		Data struct {
+			Params struct {
+				User struct {
+					Name    string
+					Address testBindAddress
+				}
+				Items   []testBindItem
+				Filter  map[string]string
+				Scores  map[string]int
+				Page    *int
+				Size    *int
+				Since   time.Time
+				Day     time.Time
+				Timeout time.Duration
+				IP      net.IP
+				Tags    []string
+			} `json:"params"`
+		} `json:"data"`
+	}{}
+	err := json.Unmarshal(resp.Data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var params = result.Data.Params
+	if params.User.Name != "Lu" || params.User.Address.City != "Beijing" || params.User.Address.Zip != 100000 {
+		t.Fatal("invalid nested struct:", params.User)
+	}
+	if len(params.Items) != 2 || params.Items[0].Id != 1 || params.Items[0].Count != nil || params.Items[1].Count == nil || *params.Items[1].Count != 5 {
+		t.Fatal("invalid items:", params.Items)
+	}
+	if params.Filter["status"] != "on" || params.Filter["type"] != "x" || params.Scores["a"] != 90 {
+		t.Fatal("invalid maps:", params.Filter, params.Scores)
+	}
+	if params.Page == nil || *params.Page != 0 || params.Size != nil {
+		t.Fatal("invalid pointers")
+	}
+	if params.Since.Format("2006-01-02 15:04:05") != "2024-05-01 10:20:30" || params.Day.Format("2006-01-02") != "2024-05-02" {
+		t.Fatal("invalid time:", params.Since, params.Day)
+	}
+	if params.Timeout != 90*time.Second {
+		t.Fatal("invalid duration:", params.Timeout)
+	}
+	if params.IP.String() != "127.0.0.1" {
+		t.Fatal("invalid ip:", params.IP)
+	}
+	if !reflect.DeepEqual(params.Tags, []string{"a", "b"}) {
+		t.Fatal("invalid tags:", params.Tags)
+	}
+}
+
+func TestParamBind_Errors(t *testing.T) {
+	resp := NewTesting(new(testBindAction)).
+		Method("POST").
+		URL("/bind").
+		Params(Params{
+			"items[0][id]": {"abc"},
+			"page":         {"1.5"},
+			"ip":           {"127.0.0"},
+		}).
+		Run(t)
+	t.Log(string(resp.Data))
+
+	var result = struct {
+		Code   int                `json:"code"`
+		Errors []ActionParamError `json:"errors"`
+	}{}
+	err := json.Unmarshal(resp.Data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != 400 || len(result.Errors) != 3 {
+		t.Fatal("conversion errors should be reported")
+	}
+	var params = []string{}
+	for _, paramError := range result.Errors {
+		params = append(params, paramError.Param)
+	}
+	if !reflect.DeepEqual(params, []string{"items.0.id", "page", "ip"}) {
+		t.Fatal("invalid error params:", params)
+	}
+}
+
+func TestParamBind_HugeIndex(t *testing.T) {
+	resp := NewTesting(new(testBindAction)).
+		Method("POST").
+		URL("/bind").
+		Params(Params{
+			"items[999999999][id]": {"1"},
+		}).
+		Run(t)
+	t.Log(string(resp.Data))
+
+	var result = struct {
+		Code   int                `json:"code"`
+		Errors []ActionParamError `json:"errors"`
+	}{}
+	err := json.Unmarshal(resp.Data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != 400 || len(result.Errors) != 1 || result.Errors[0].Param != "items" {
+		t.Fatal("huge index should be rejected")
+	}
+}
+
+type testBindRange struct {
+	From int
+	To   int
+}
+
+func (this *testBindRange) Len() int {
+	return this.To - this.From
+}
+
+type testBindPointerAction Action
+
+func (this *testBindPointerAction) RunGet(params struct {
+	Range *testBindRange
+	Must  *Must
+}) {
+	if params.Must == nil {
+		this.Fail("helper should be initialized")
+	}
+	if params.Range == nil {
+		this.Data["range"] = "nil"
+	} else {
+		this.Data["range"] = params.Range.Len()
+	}
+	this.Success()
+}
+
+func TestParamBind_Pointer(t *testing.T) {
+	for _, testCase := range []struct {
+		params   Params
+		expected string
+	}{
+		{Params{}, `"range":"nil"`},
+		{Params{"range[from]": {"1"}, "range[to]": {"3"}}, `"range":2`},
+	} {
+		resp := NewTesting(new(testBindPointerAction)).
+			URL("/bind").
+			Params(testCase.params).
+			Run(t)
+		t.Log(string(resp.Data))
+		if !strings.Contains(string(resp.Data), testCase.expected) {
+			t.Fatal("struct pointers with methods should be bound as params, expected " + testCase.expected)
+		}
+	}
+}
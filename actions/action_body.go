@@ -0,0 +1,255 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 请求体默认的最大尺寸
+const defaultBodyMaxSize = 32 << 20
+
+// 请求体中嵌套的最大层级，防止过深的递归导致栈溢出
+const bodyMaxDepth = 256
+
+var errBodyTooDeep = errors.New("nesting depth exceeds " + strconv.Itoa(bodyMaxDepth))
+
+// 请求体的格式
+const (
+	bodyFormatJSON = "json"
+	bodyFormatXML  = "xml"
+	bodyFormatYAML = "yaml"
+)
+
+// 请求体错误
+type bodyError struct {
+	code int
+	err  error
+}
+
+func (this *bodyError) Error() string {
+	return this.err.Error()
+}
+
+// 根据Content-Type判断请求体的格式，不支持的返回空
+func detectBodyFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/json", "text/json":
+		return bodyFormatJSON
+	case "application/xml", "text/xml":
+		return bodyFormatXML
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return bodyFormatYAML
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		return bodyFormatJSON
+	}
+	if strings.HasSuffix(mediaType, "+xml") {
+		return bodyFormatXML
+	}
+	if strings.HasSuffix(mediaType, "+yaml") {
+		return bodyFormatYAML
+	}
+	return ""
+}
+
+// 解析JSON、XML和YAML格式的请求体，并将其中的值放入参数中
+// 嵌套的值使用 . 连接键名，比如 user.name、items.0.id
+// 解析后会恢复请求体，以便动作中可以再次读取
+func parseRequestBody(request *http.Request, params Params, maxSize float64) error {
+	if request.Body == nil || request.Body == http.NoBody || request.ContentLength == 0 {
+		return nil
+	}
+	var format = detectBodyFormat(request.Header.Get("Content-Type"))
+	if len(format) == 0 {
+		return nil
+	}
+
+	var limit = int64(maxSize)
+	if limit <= 0 {
+		limit = defaultBodyMaxSize
+	}
+	if request.ContentLength > limit {
+		return &bodyError{code: http.StatusRequestEntityTooLarge, err: errors.New("request body too large")}
+	}
+	data, err := io.ReadAll(io.LimitReader(request.Body, limit+1))
+	_ = request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return &bodyError{code: http.StatusBadRequest, err: errors.New("read request body failed: " + err.Error())}
+	}
+	if int64(len(data)) > limit {
+		return &bodyError{code: http.StatusRequestEntityTooLarge, err: errors.New("request body too large")}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	switch format {
+	case bodyFormatJSON:
+		var decoder = json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&value)
+	case bodyFormatXML:
+		value, err = decodeXMLBody(data)
+	case bodyFormatYAML:
+		err = yaml.Unmarshal(data, &value)
+	}
+	if err != nil {
+		return &bodyError{code: http.StatusBadRequest, err: errors.New("decode " + format + " body failed: " + err.Error())}
+	}
+
+	err = flattenBodyValue(params, "", value, 0)
+	if err != nil {
+		return &bodyError{code: http.StatusBadRequest, err: errors.New("decode " + format + " body failed: " + err.Error())}
+	}
+	return nil
+}
+
+// 将解析后的值展开到参数中，depth 为当前的嵌套层级
+func flattenBodyValue(params Params, prefix string, value interface{}, depth int) error {
+	if depth > bodyMaxDepth {
+		return errBodyTooDeep
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, childValue := range v {
+			err := flattenBodyValue(params, joinBodyKey(prefix, key), childValue, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		for key, childValue := range v {
+			err := flattenBodyValue(params, joinBodyKey(prefix, fmt.Sprint(key)), childValue, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(prefix) == 0 {
+			return nil
+		}
+		for index, item := range v {
+			switch item.(type) {
+			case nil:
+			case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+				err := flattenBodyValue(params, prefix+"."+strconv.Itoa(index), item, depth+1)
+				if err != nil {
+					return err
+				}
+			default:
+				params[prefix] = append(params[prefix], formatBodyScalar(item))
+			}
+		}
+	default:
+		if len(prefix) == 0 {
+			return nil
+		}
+		params[prefix] = []string{formatBodyScalar(v)}
+	}
+	return nil
+}
+
+func joinBodyKey(prefix string, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// 将单个值转换为字符串
+func formatBodyScalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// 将XML解析为字典，根元素会被忽略，属性和子元素都作为键，同名的子元素作为列表
+func decodeXMLBody(data []byte) (interface{}, error) {
+	var decoder = xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if ok {
+			return decodeXMLElement(decoder, start, 0)
+		}
+	}
+}
+
+// 解析单个元素，depth 为当前的嵌套层级
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > bodyMaxDepth {
+		return nil, errBodyTooDeep
+	}
+	var children = map[string]interface{}{}
+	for _, attr := range start.Attr {
+		children[attr.Name.Local] = attr.Value
+	}
+	var text = strings.Builder{}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			childValue, err := decodeXMLElement(decoder, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			var name = t.Name.Local
+			existValue, ok := children[name]
+			if !ok {
+				children[name] = childValue
+			} else if list, ok := existValue.([]interface{}); ok {
+				children[name] = append(list, childValue)
+			} else {
+				children[name] = []interface{}{existValue, childValue}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(children) == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			return children, nil
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package actions
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testBodyAction Action
+
+func (this *testBodyAction) RunPost(params struct {
+	Name    string `alias:"user_name"`
+	Age     int
+	Tags    []string
+	Enabled bool
+	Role    string `default:"guest"`
+	City    string
+}) {
+	body, _ := io.ReadAll(this.Request.Body)
+	this.WriteFormat("%s|%d|%s|%v|%s|%s|%d|%s", params.Name, params.Age, strings.Join(params.Tags, ","), params.Enabled, params.Role, params.City, len(body), this.ParamString("address.city"))
+}
+
+func TestParseRequestBody_JSON(t *testing.T) {
+	var body = `{"user_name":"Lu","age":20,"tags":["a","b"],"enabled":true,"role":"","address":{"city":"Beijing"}}`
+	resp := NewTesting(new(testBodyAction)).
+		Method("POST").
+		URL("/body").
+		Params(Params{"city": []string{"Shanghai"}}).
+		Body("application/json; charset=utf-8", []byte(body)).
+		Run(t)
+	t.Log(string(resp.Data))
+	if string(resp.Data) != "Lu|20|a,b|true|guest|Shanghai|"+strconv.Itoa(len(body))+"|Beijing" {
+		t.Fatal("invalid binding")
+	}
+}
+
+func TestParseRequestBody_XML(t *testing.T) {
+	var body = `<user><user_name>Lu</user_name><age>20</age><tags>a</tags><tags>b</tags><enabled>true</enabled><address city="Beijing"/></user>`
+	resp := NewTesting(new(testBodyAction)).
+		Method("POST").
+		URL("/body").
+		Body("application/xml", []byte(body)).
+		Run(t)
+	t.Log(string(resp.Data))
+	if string(resp.Data) != "Lu|20|a,b|true|guest||"+strconv.Itoa(len(body))+"|Beijing" {
+		t.Fatal("invalid binding")
+	}
+}
+
+func TestParseRequestBody_YAML(t *testing.T) {
+	var body = "user_name: Lu\nage: 20\ntags: [a, b]\nenabled: yes\nrole: admin\naddress:\n  city: Beijing\n"
+	resp := NewTesting(new(testBodyAction)).
+		Method("POST").
+		URL("/body").
+		Body("application/x-yaml", []byte(body)).
+		Run(t)
+	t.Log(string(resp.Data))
+	if string(resp.Data) != "Lu|20|a,b|true|admin||"+strconv.Itoa(len(body))+"|Beijing" {
+		t.Fatal("invalid binding")
+	}
+}
+
+func TestParseRequestBody_Errors(t *testing.T) {
+	resp := NewTesting(new(testBodyAction)).
+		Method("POST").
+		URL("/body").
+		Body("application/json", []byte(`{"name":`)).
+		Run(t)
+	t.Log(string(resp.Data))
+	if !strings.HasPrefix(string(resp.Data), "400 ") {
+		t.Fatal("invalid json should be rejected")
+	}
+
+	var action = new(testBodyAction)
+	action.SetMaxSize(10)
+	resp = NewTesting(action).
+		Method("POST").
+		URL("/body").
+		Body("application/json", []byte(`{"user_name":"Lu"}`)).
+		Run(t)
+	t.Log(string(resp.Data))
+	if !strings.HasPrefix(string(resp.Data), "413 ") {
+		t.Fatal("large body should be rejected")
+	}
+}
+
+func TestParseRequestBody_Depth(t *testing.T) {
+	for _, testCase := range []struct {
+		contentType string
+		body        string
+	}{
+		{"application/xml", strings.Repeat("<a>", 100000) + strings.Repeat("</a>", 100000)},
+		{"application/json", strings.Repeat(`{"a":`, 1000) + "1" + strings.Repeat("}", 1000)},
+	} {
+		resp := NewTesting(new(testBodyAction)).
+			Method("POST").
+			URL("/body").
+			Body(testCase.contentType, []byte(testCase.body)).
+			Run(t)
+		if !strings.HasPrefix(string(resp.Data), "400 ") {
+			t.Fatal("deeply nested " + testCase.contentType + " body should be rejected, but got: " + string(resp.Data))
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package actions
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/caches"
@@ -26,6 +27,7 @@ type ActionObject struct {
 	Context *ActionContext
 
 	Module string
+	Host   string // 路由所在的虚拟主机，比如 admin.example.com 或 *.example.com，默认主机为空
 
 	Code    int
 	Data    Data
@@ -56,7 +58,12 @@ type ActionObject struct {
 		Hash   string                 `json:"hash"`
 	}
 
-	writer ActionWriter
+	writer      ActionWriter
+	eventStream *EventStream
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	ctxLocker sync.Mutex
 }
 
 // Object 取得内置的动作对象
@@ -208,6 +215,29 @@ func (this *ActionObject) Write(bytes []byte) (n int, err error) {
 	}
 }
 
+// Flush 将已经输出的内容立即发送给客户端
+func (this *ActionObject) Flush() {
+	if this.writer != nil {
+		flusher, ok := this.writer.(http.Flusher)
+		if ok {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher, ok := this.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// 不再使用Gzip等自定义的输出，直接向ResponseWriter输出
+func (this *ActionObject) detachWriter() {
+	if gzipHelper, ok := this.writer.(*Gzip); ok {
+		gzipHelper.gzipWriter = nil
+	}
+	this.writer = nil
+}
+
 // WriteFormat 输出可以格式化的内容
 func (this *ActionObject) WriteFormat(format string, args ...interface{}) (n int, err error) {
 	if len(args) > 0 {
@@ -216,16 +246,14 @@ func (this *ActionObject) WriteFormat(format string, args ...interface{}) (n int
 	return this.Write([]byte(format))
 }
 
-// WriteJSON 写入JSON
+// WriteJSON 写入JSON，会根据请求的 Accept 选择XML、YAML或MessagePack等格式
 func (this *ActionObject) WriteJSON(value interface{}) {
-	this.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
-
-	var jsonBytes, err = this.marshal(value)
+	var data, err = this.encode(value)
 	if err != nil {
 		this.Write([]byte(err.Error()))
 		return
 	}
-	this.Write(jsonBytes)
+	this.Write(data)
 }
 
 // Success 成功返回
@@ -234,41 +262,17 @@ func (this *ActionObject) Success(message ...string) {
 		this.Message = message[0]
 	}
 
-	this.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
-
 	var code = this.Code
 	if code == 0 {
 		code = 200
 		this.Code = 200
 	}
-	respJson := JSON{
-		"code":    code,
-		"message": this.Message,
-		"data":    this.Data,
-	}
-	if len(this.next.Action) > 0 {
-		respJson["next"] = this.next
-	}
-	var jsonBytes, err = this.marshal(respJson)
-	if err != nil {
-		jsonBytes, err = this.marshal(JSON{
-			"code":    http.StatusInternalServerError,
-			"message": err.Error(),
-			"data":    nil,
-		})
-		if err != nil {
-			this.Write([]byte(err.Error()))
-
-			panic(this)
-			return
-		}
-		this.Write(jsonBytes)
-
-		panic(this)
-		return
-	}
-
-	this.Write(jsonBytes)
+	this.writeEnvelope(&Envelope{
+		Success: true,
+		Code:    code,
+		Message: this.Message,
+		Data:    this.Data,
+	})
 
 	panic(this)
 }
@@ -297,35 +301,74 @@ func (this *ActionObject) FailField(field string, message ...string) {
 
 // 不使用panic的返回，仅供内部使用
 func (this *ActionObject) failWithoutPanic() {
-	this.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
-
 	var code = this.Code
 	if code == 0 {
 		code = http.StatusBadRequest
 	}
-	var jsonBytes, err = this.marshal(JSON{
-		"code":    code,
-		"message": this.Message,
-		"data":    this.Data,
-		"errors":  this.errors,
+	this.writeEnvelope(&Envelope{
+		Success: false,
+		Code:    code,
+		Message: this.Message,
+		Data:    this.Data,
+		Errors:  this.errors,
 	})
+}
+
+// 使用模块对应的格式输出数据
+func (this *ActionObject) writeEnvelope(envelope *Envelope) {
+	if len(this.next.Action) > 0 {
+		envelope.Next = this.next
+	}
+	value, status := findEnvelopeFormatter(this.Module)(envelope)
+
+	var data, err = this.encode(value)
 	if err != nil {
-		jsonBytes, err = this.marshal(JSON{
-			"code":    http.StatusInternalServerError,
-			"message": err.Error(),
-			"data":    nil,
-			"errors":  this.errors,
+		value, status = findEnvelopeFormatter(this.Module)(&Envelope{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Errors:  envelope.Errors,
 		})
+		data, err = this.encode(value)
 		if err != nil {
 			this.Write([]byte(err.Error()))
-			panic(this)
 			return
 		}
-		this.Write(jsonBytes)
-		panic(this)
-		return
 	}
-	this.Write(jsonBytes)
+	if status > 0 && status != http.StatusOK {
+		this.ResponseWriter.WriteHeader(status)
+	}
+	this.Write(data)
+}
+
+// Ctx 取得请求的上下文，客户端断开连接、超过路由设置的超时时间或者动作执行结束后会被取消
+// 可以传给 dbs.Query.Context() 等方法，以便及时中止正在执行的查询
+func (this *ActionObject) Ctx() context.Context {
+	this.ctxLocker.Lock()
+	defer this.ctxLocker.Unlock()
+
+	if this.ctx != nil {
+		return this.ctx
+	}
+
+	var ctx = context.Background()
+	if this.Request != nil {
+		ctx = this.Request.Context()
+	}
+	if this.Spec != nil && this.Spec.Timeout > 0 {
+		ctx, this.ctxCancel = context.WithTimeout(ctx, this.Spec.Timeout)
+	}
+	this.ctx = ctx
+	return ctx
+}
+
+// 释放上下文
+func (this *ActionObject) cancelCtx() {
+	this.ctxLocker.Lock()
+	if this.ctxCancel != nil {
+		this.ctxCancel()
+		this.ctxCancel = nil
+	}
+	this.ctxLocker.Unlock()
 }
 
 // SetSessionManager 设置Session管理器
@@ -418,6 +461,17 @@ func (this *ActionObject) File(field string) *File {
 	return nil
 }
 
+// FieldFiles 取得某个字段的所有文件
+func (this *ActionObject) FieldFiles(field string) []*File {
+	var result = []*File{}
+	for _, file := range this.Files {
+		if file.Field == field {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
 // Next 设置下一个动作
 func (this *ActionObject) Next(nextAction string, params map[string]interface{}, hash ...string) *ActionObject {
 	this.next.Action = nextAction
@@ -434,6 +488,14 @@ func (this *ActionObject) Refresh() *ActionObject {
 	return this
 }
 
+// URL 根据路由名称和参数生成URL
+func (this *ActionObject) URL(name string, params map[string]interface{}) (string, error) {
+	if this.Spec == nil || this.Spec.URLBuilder == nil {
+		return "", errors.New("can not build url for route '" + name + "': url builder not set")
+	}
+	return this.Spec.URLBuilder(name, params)
+}
+
 // RedirectURL 跳转
 func (this *ActionObject) RedirectURL(url string) {
 	http.Redirect(this.ResponseWriter, this.Request, url, http.StatusTemporaryRedirect)
@@ -455,10 +517,13 @@ func (this *ActionObject) TemplateFilter(filter func(body []byte) []byte) {
 	this.templateFilter = filter
 }
 
-// marshal json
-func (this *ActionObject) marshal(value interface{}) ([]byte, error) {
-	if this.pretty {
-		return json.MarshalIndent(value, "", "   ")
+// 根据请求的 Accept 编码数据，并设置 Content-Type
+func (this *ActionObject) encode(value interface{}) ([]byte, error) {
+	var encoder = NegotiateResponseEncoder("")
+	if this.Request != nil {
+		encoder = NegotiateResponseEncoder(this.Request.Header.Get("Accept"))
+		AddVaryHeader(this.ResponseWriter.Header(), "Accept")
 	}
-	return json.Marshal(value)
+	this.ResponseWriter.Header().Set("Content-Type", encoder.ContentType)
+	return encoder.Encode(value, this.pretty)
 }
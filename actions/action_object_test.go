@@ -0,0 +1,49 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCtxAction Action
+
+func (this *testCtxAction) RunGet(params struct{}) {
+	this.Success()
+}
+
+func TestActionObject_Ctx(t *testing.T) {
+	var spec = NewActionSpec(new(testCtxAction))
+	spec.Timeout = 50 * time.Millisecond
+
+	var action = spec.NewPtrValue().Interface().(*testCtxAction)
+	action.Spec = spec
+	action.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	var ctx = action.Ctx()
+	if ctx != action.Ctx() {
+		t.Fatal("context should be reused")
+	}
+	_, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("context should have a deadline")
+	}
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatal("context should be timeout")
+	}
+
+	// 动作结束后取消
+	action = new(testCtxAction)
+	action.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx = action.Ctx()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("context should not have a deadline")
+	}
+	action.cancelCtx()
+	if ctx.Err() != nil {
+		t.Fatal("request context should not be canceled by action")
+	}
+}
@@ -2,10 +2,19 @@ package actions
 
 import (
 	"github.com/iwind/TeaGo/caches"
+	"net/http"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
+// URLBuilder 根据路由名称和参数生成URL
+type URLBuilder func(name string, params map[string]interface{}) (string, error)
+
+// ErrorHandler 处理Action执行过程中发生的错误，比如没有恢复的panic
+type ErrorHandler func(writer http.ResponseWriter, request *http.Request, code int, err error)
+
 // ActionSpec Action相关定义
 type ActionSpec struct {
 	Type reflect.Type
@@ -16,12 +25,25 @@ type ActionSpec struct {
 	FuncMap map[string]*reflect.Value
 
 	Module    string
+	Host      string // 虚拟主机，为空表示默认主机
 	PkgPath   string
 	ClassName string
 
 	caches.CacheFactory
 
 	Context *ActionContext
+
+	URLBuilder   URLBuilder   // 用来生成命名路由的URL
+	ErrorHandler ErrorHandler // 用来输出错误页面
+
+	Timeout   time.Duration // Ctx() 的超时时间，为0表示不限制
+	Container *Container    // 依赖注入容器
+
+	StreamUpload   bool               // 是否将上传的文件直接写入到临时目录中，而不是先缓存在内存中
+	UploadProgress UploadProgressFunc // 流式上传时的进度回调
+
+	validators     map[reflect.Type]*validateStruct // 解析后的参数校验规则
+	validateErrors []error                          // 解析 validate 标签时发现的错误
 }
 
 // NewActionSpec 创建新定义
@@ -60,9 +82,44 @@ func NewActionSpec(actionPtr ActionWrapper) *ActionSpec {
 		}
 	}
 
+	// 解析参数中的 validate 标签
+	spec.validators = map[reflect.Type]*validateStruct{}
+	var funcNames = []string{}
+	for funcName := range spec.FuncMap {
+		funcNames = append(funcNames, funcName)
+	}
+	sort.Strings(funcNames)
+	for _, funcName := range funcNames {
+		var runMethodType = spec.FuncMap[funcName].Type()
+		if runMethodType.NumIn() != 2 || runMethodType.In(1).Kind() != reflect.Struct {
+			continue
+		}
+		var argType = runMethodType.In(1)
+		if _, ok := spec.validators[argType]; ok {
+			continue
+		}
+		validator, errs := parseValidateStruct(argType, spec.ClassName+"."+funcName+"()")
+		spec.validators[argType] = validator
+		spec.validateErrors = append(spec.validateErrors, errs...)
+	}
+
 	return spec
 }
 
+// ValidateErrors 解析参数中的 validate 标签时发现的错误，比如未知的规则、无法解析的规则参数等
+func (this *ActionSpec) ValidateErrors() []error {
+	return this.validateErrors
+}
+
+// 使用解析后的规则校验参数
+func (this *ActionSpec) validateParams(argValue reflect.Value) []ActionParamError {
+	validator, ok := this.validators[argValue.Type()]
+	if !ok {
+		return ValidateStruct(argValue.Interface())
+	}
+	return runValidateStruct(validator, argValue)
+}
+
 // NewPtrValue 新建一个Action指针
 func (this *ActionSpec) NewPtrValue() reflect.Value {
 	actionPtr := reflect.New(this.Type)
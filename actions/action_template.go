@@ -1,17 +1,19 @@
 package actions
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/iwind/TeaGo/Tea"
-	"github.com/iwind/TeaGo/files"
 	"github.com/iwind/TeaGo/gohtml"
 	"github.com/iwind/TeaGo/gohtml/atom"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/utils/string"
 	"html"
+	"io/fs"
+	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
@@ -36,6 +38,10 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 	var filename = this.viewTemplate
 	var data = this.Data
 	var viewFuncMap = this.viewFuncMap
+	var urlBuilder URLBuilder
+	if this.Spec != nil {
+		urlBuilder = this.Spec.URLBuilder
+	}
 
 	// 去除末尾的.html
 	tailReplacer, err := stringutil.RegexpCompile("\\.html")
@@ -49,7 +55,7 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 	if ok {
 		// 生产环境直接使用缓存
 		if Tea.Env == Tea.EnvProd {
-			teaFuncMap := createTeaFuncMap(cache.(*TemplateCache).template, viewFuncMap, module, dir, filename, data)
+			teaFuncMap := createTeaFuncMap(cache.(*TemplateCache).template, viewFuncMap, module, dir, filename, data, urlBuilder)
 			t := cache.(*TemplateCache).template.Funcs(teaFuncMap)
 			if this.writer != nil {
 				return t.Execute(this.writer, data)
@@ -60,7 +66,7 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 
 		var isChanged = false
 		for watchingFile, modifiedAt := range cache.(*TemplateCache).watchingFiles {
-			stat, err := os.Stat(watchingFile)
+			stat, err := Tea.StatFile(watchingFile)
 			if err != nil {
 				return err
 			}
@@ -71,7 +77,7 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 		}
 
 		if !isChanged {
-			teaFuncMap := createTeaFuncMap(cache.(*TemplateCache).template, viewFuncMap, module, dir, filename, data)
+			teaFuncMap := createTeaFuncMap(cache.(*TemplateCache).template, viewFuncMap, module, dir, filename, data, urlBuilder)
 
 			t := cache.(*TemplateCache).template.Funcs(teaFuncMap)
 			if this.writer != nil {
@@ -85,18 +91,18 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 	var watchingFiles = map[string]int64{}
 
 	var originFilename = filename
-	_, err = os.Stat(filename + ".html")
+	_, err = Tea.StatFile(filename + ".html")
 	if err != nil {
 		// 查找 *_plus.
 		var plusFilename = filename + "_plus"
-		_, err2 := os.Stat(plusFilename + ".html")
+		_, err2 := Tea.StatFile(plusFilename + ".html")
 		if err2 != nil {
 			return err
 		}
 		filename = plusFilename
 	}
 
-	bodyBytes, err := os.ReadFile(filename + ".html")
+	bodyBytes, err := Tea.ReadFile(filename + ".html")
 	if err != nil {
 		return err
 	}
@@ -132,9 +138,9 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 			layoutFile := dir + "/@" + layoutTemplate + ".html"
 			addFileToWatchingFiles(&watchingFiles, layoutFile)
 
-			_, err := os.Stat(layoutFile)
+			_, err := Tea.StatFile(layoutFile)
 			if err == nil {
-				layoutBytes, err := os.ReadFile(layoutFile)
+				layoutBytes, err := Tea.ReadFile(layoutFile)
 				if err == nil {
 					layoutBody := string(layoutBytes)
 
@@ -184,7 +190,7 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 
 	// 内部自定义函数
 	tpl := NewTemplate(filename)
-	teaFuncMap := createTeaFuncMap(tpl, viewFuncMap, module, dir, filename, data)
+	teaFuncMap := createTeaFuncMap(tpl, viewFuncMap, module, dir, filename, data, urlBuilder)
 	newTemplate, err := tpl.Delims("{$", "}").Funcs(teaFuncMap).Parse(body)
 	if err != nil {
 		logs.Errorf("Template parse error:%s", err.Error())
@@ -225,12 +231,46 @@ func (this *ActionObject) render(dir string, templateFilter func(body []byte) []
 	}
 }
 
+// RenderView 使用模板引擎渲染视图并返回渲染后的内容
+// view 为相对于视图目录的路径，可以用 @模块 开头，比如 @default/errors/404
+func RenderView(request *http.Request, view string, data Data) ([]byte, error) {
+	var viewDir = ""
+	if strings.HasPrefix(view, "@") {
+		index := strings.Index(view, "/")
+		if index > 0 {
+			viewDir = view[:index]
+			view = view[index+1:]
+		}
+	}
+
+	var buffer = &bytes.Buffer{}
+	var action = &ActionObject{
+		Request:     request,
+		Module:      strings.TrimPrefix(viewDir, "@"),
+		Data:        data,
+		viewFuncMap: template.FuncMap{},
+		writer:      buffer,
+	}
+	action.ViewDir(viewDir)
+	action.View(view)
+
+	var dir = Tea.ViewsDir()
+	if len(viewDir) > 0 {
+		dir += "/" + viewDir
+	}
+	err := action.render(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 func loadChildTemplate(watchingFiles *map[string]int64, tpl *Template, dir string, filename string, childTemplateName string) error {
 	viewPath := pathRelative(dir, filename, childTemplateName)
-	childBytes, err := os.ReadFile(viewPath)
+	childBytes, err := Tea.ReadFile(viewPath)
 	if err != nil {
 		var plusViewPath = regexp.MustCompile(`\.html$`).ReplaceAllString(viewPath, "_plus.html")
-		plusChildBytes, err2 := os.ReadFile(plusViewPath)
+		plusChildBytes, err2 := Tea.ReadFile(plusViewPath)
 		if err2 != nil {
 			return err
 		}
@@ -273,7 +313,7 @@ func loadChildTemplate(watchingFiles *map[string]int64, tpl *Template, dir strin
 }
 
 func addFileToWatchingFiles(watchingFiles *map[string]int64, filename string) {
-	stat, err := os.Stat(filename)
+	stat, err := Tea.StatFile(filename)
 	if err != nil {
 		(*watchingFiles)[filename] = 0
 	} else {
@@ -298,7 +338,7 @@ func pathRelative(dir string, filename string, path string) string {
 	}
 }
 
-func createTeaFuncMap(tpl *Template, funcMap template.FuncMap, module string, dir string, filename string, data map[string]interface{}) template.FuncMap {
+func createTeaFuncMap(tpl *Template, funcMap template.FuncMap, module string, dir string, filename string, data map[string]interface{}, urlBuilder URLBuilder) template.FuncMap {
 	parent := filepath.Dir(strings.TrimPrefix(filename, dir))
 	if runtime.GOOS == "windows" {
 		parent = strings.Replace(parent, "\\", "/", -1)
@@ -350,9 +390,9 @@ window.TEA = {
 					pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+stringutil.ConvertID(templateCacheTime)+"\"></script>")
 				} else {
 					jsFile := "js/vue.js"
-					stat, err := os.Stat(Tea.PublicFile(jsFile))
+					stat, err := Tea.StatFile(Tea.PublicFile(jsFile))
 					if err == nil {
-						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\"></script>")
+						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+fileVersion(stat)+"\"></script>")
 					} else {
 						pieces = append(pieces, "<!-- warning: "+jsFile+" not appeared in public/ -->")
 					}
@@ -364,9 +404,9 @@ window.TEA = {
 				if Tea.Env == Tea.EnvProd {
 					pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+stringutil.ConvertID(templateCacheTime)+"\"></script>")
 				} else {
-					stat, err := os.Stat(Tea.PublicFile(jsFile))
+					stat, err := Tea.StatFile(Tea.PublicFile(jsFile))
 					if err == nil {
-						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\"></script>")
+						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/"+jsFile+"?v="+fileVersion(stat)+"\"></script>")
 					} else {
 						pieces = append(pieces, "<!-- warning: "+jsFile+" not appeared in public/ -->")
 					}
@@ -376,9 +416,9 @@ window.TEA = {
 			{
 				jsFile := filename + ".js"
 				if Tea.Env == Tea.EnvProd {
-					stat, err := os.Stat(jsFile)
+					stat, err := Tea.StatFile(jsFile)
 					if err != nil {
-						stat2, err2 := os.Stat(filename + "_plus.js")
+						stat2, err2 := Tea.StatFile(filename + "_plus.js")
 						if err2 == nil {
 							err = nil
 							stat = stat2
@@ -386,12 +426,12 @@ window.TEA = {
 						}
 					}
 					if err == nil {
-						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/_/"+strings.TrimPrefix(jsFile, Tea.ViewsDir()+"/")+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\"></script>")
+						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/_/"+strings.TrimPrefix(jsFile, Tea.ViewsDir()+"/")+"?v="+fileVersion(stat)+"\"></script>")
 					}
 				} else {
-					stat, err := os.Stat(jsFile)
+					stat, err := Tea.StatFile(jsFile)
 					if err != nil {
-						stat2, err2 := os.Stat(filename + "_plus.js")
+						stat2, err2 := Tea.StatFile(filename + "_plus.js")
 						if err2 == nil {
 							err = nil
 							stat = stat2
@@ -399,7 +439,7 @@ window.TEA = {
 						}
 					}
 					if err == nil {
-						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/_/"+strings.TrimPrefix(jsFile, Tea.ViewsDir()+"/")+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\"></script>")
+						pieces = append(pieces, "<script type=\"text/javascript\" src=\"/_/"+strings.TrimPrefix(jsFile, Tea.ViewsDir()+"/")+"?v="+fileVersion(stat)+"\"></script>")
 					} else {
 						pieces = append(pieces, "<!-- warning: "+strings.TrimPrefix(jsFile, Tea.ViewsDir()+"/")+" not appeared in views/ -->")
 					}
@@ -409,9 +449,9 @@ window.TEA = {
 			{
 				cssFile := filename + ".css"
 				if Tea.Env == Tea.EnvProd {
-					stat, err := os.Stat(cssFile)
+					stat, err := Tea.StatFile(cssFile)
 					if err != nil {
-						stat2, err2 := os.Stat(filename + "_plus.css")
+						stat2, err2 := Tea.StatFile(filename + "_plus.css")
 						if err2 == nil {
 							err = nil
 							stat = stat2
@@ -419,12 +459,12 @@ window.TEA = {
 						}
 					}
 					if err == nil {
-						pieces = append(pieces, "<link rel=\"stylesheet\" type=\"text/css\" href=\"/_/"+strings.TrimPrefix(cssFile, Tea.ViewsDir()+"/")+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\" media=\"all\"/>")
+						pieces = append(pieces, "<link rel=\"stylesheet\" type=\"text/css\" href=\"/_/"+strings.TrimPrefix(cssFile, Tea.ViewsDir()+"/")+"?v="+fileVersion(stat)+"\" media=\"all\"/>")
 					}
 				} else {
-					stat, err := os.Stat(cssFile)
+					stat, err := Tea.StatFile(cssFile)
 					if err != nil {
-						stat2, err2 := os.Stat(filename + "_plus.css")
+						stat2, err2 := Tea.StatFile(filename + "_plus.css")
 						if err2 == nil {
 							err = nil
 							stat = stat2
@@ -432,7 +472,7 @@ window.TEA = {
 						}
 					}
 					if err == nil {
-						pieces = append(pieces, "<link rel=\"stylesheet\" type=\"text/css\" href=\"/_/"+strings.TrimPrefix(cssFile, Tea.ViewsDir()+"/")+"?v="+stringutil.ConvertID(stat.ModTime().Unix())+"\" media=\"all\"/>")
+						pieces = append(pieces, "<link rel=\"stylesheet\" type=\"text/css\" href=\"/_/"+strings.TrimPrefix(cssFile, Tea.ViewsDir()+"/")+"?v="+fileVersion(stat)+"\" media=\"all\"/>")
 					} else {
 						pieces = append(pieces, "<!-- warning: "+strings.TrimPrefix(cssFile, Tea.ViewsDir()+"/")+" not appeared in views/ -->")
 					}
@@ -462,9 +502,9 @@ window.TEA = {
 			if found {
 				return includeHTML.(string)
 			} else {
-				stat, err := os.Stat(cssFile)
+				stat, err := Tea.StatFile(cssFile)
 				if err == nil {
-					s := "<link rel=\"stylesheet\" type=\"text/css\" href=\"/css/semantic.min.css?v=" + stringutil.ConvertID(stat.ModTime().Unix()) + "\" media=\"all\"/>"
+					s := "<link rel=\"stylesheet\" type=\"text/css\" href=\"/css/semantic.min.css?v=" + fileVersion(stat) + "\" media=\"all\"/>"
 					templateFileStatCache.Store(filename+"_TEA_SEMANTIC", s)
 					return s
 				} else {
@@ -472,9 +512,9 @@ window.TEA = {
 				}
 			}
 		} else {
-			stat, err := os.Stat(cssFile)
+			stat, err := Tea.StatFile(cssFile)
 			if err == nil {
-				return "<link rel=\"stylesheet\" type=\"text/css\" href=\"/css/semantic.min.css?v=" + stringutil.ConvertID(stat.ModTime().Unix()) + "\" media=\"all\"/>"
+				return "<link rel=\"stylesheet\" type=\"text/css\" href=\"/css/semantic.min.css?v=" + fileVersion(stat) + "\" media=\"all\"/>"
 			}
 		}
 
@@ -501,6 +541,39 @@ window.TEA = {
 		return url.QueryEscape(s)
 	}
 
+	// {$url "routeName" "param1" value1 "param2" value2 ...} 或者 {$url "routeName" .paramsMap}
+	funcMap["url"] = func(name string, args ...interface{}) (string, error) {
+		if urlBuilder == nil {
+			return "", errors.New("can not build url for route '" + name + "': url builder not set")
+		}
+
+		var params = map[string]interface{}{}
+		if len(args) == 1 {
+			switch m := args[0].(type) {
+			case map[string]interface{}:
+				params = m
+			case maps.Map:
+				params = m
+			case JSON:
+				params = m
+			default:
+				return "", errors.New("url: params should be a map or key-value pairs")
+			}
+		} else {
+			if len(args)%2 != 0 {
+				return "", errors.New("url: params should be key-value pairs")
+			}
+			for i := 0; i < len(args); i += 2 {
+				key, ok := args[i].(string)
+				if !ok {
+					return "", errors.New("url: param name should be a string")
+				}
+				params[key] = args[i+1]
+			}
+		}
+		return urlBuilder(name, params)
+	}
+
 	return funcMap
 }
 
@@ -600,22 +673,18 @@ func getResourceVersion(resourceURL string) string {
 
 	query := uri.Query()
 
-	var file *files.File
+	var filePath string
 	if strings.HasPrefix(uri.Path, "/_/") {
-		file = files.NewFile(Tea.ViewsDir() + uri.Path[2:])
+		filePath = Tea.ViewsDir() + uri.Path[2:]
 	} else {
-		file = files.NewFile(Tea.PublicFile(uri.Path))
+		filePath = Tea.PublicFile(uri.Path)
 	}
-	if !file.Exists() || !file.IsFile() {
+	stat, err := Tea.StatFile(filePath)
+	if err != nil || stat.IsDir() {
 		return resourceURL
 	}
 
-	modifiedTime, err := file.LastModified()
-	if err != nil {
-		return resourceURL
-	}
-
-	version := stringutil.ConvertID(modifiedTime.Unix())
+	version := fileVersion(stat)
 	if len(query) == 0 {
 		resourceURL = resourceURL + "?v=" + version
 	} else {
@@ -629,3 +698,11 @@ func getResourceVersion(resourceURL string) string {
 
 	return resourceURL
 }
+
+// 根据文件修改时间生成资源版本号，嵌入的文件没有修改时间，使用启动时间
+func fileVersion(stat fs.FileInfo) string {
+	if stat.ModTime().IsZero() {
+		return stringutil.ConvertID(templateCacheTime)
+	}
+	return stringutil.ConvertID(stat.ModTime().Unix())
+}
@@ -0,0 +1,309 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var actionObjectPtrType = reflect.TypeOf(&ActionObject{})
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Container 依赖注入容器，用来向Action结构体和Run()参数中注入服务
+//
+// 以下字段会被注入：
+//   - 带有 inject:"" 标签的字段，inject:"optional" 表示没有对应的服务时保持为空
+//   - 类型为已注册的接口类型的字段
+//
+// 单例在所有请求中共享；工厂函数在每个请求中最多执行一次，请求结束（After()之后）时，
+// 如果生成的值实现了 io.Closer 或者有 Close() 方法，则会被自动关闭
+type Container struct {
+	bindings map[reflect.Type]*containerBinding
+	locker   sync.RWMutex
+}
+
+type containerBinding struct {
+	valueType reflect.Type
+	value     reflect.Value // 单例
+	factory   reflect.Value // 工厂函数
+}
+
+// NewContainer 获取新的容器
+func NewContainer() *Container {
+	return &Container{
+		bindings: map[reflect.Type]*containerBinding{},
+	}
+}
+
+// Singleton 注册单例，使用值的类型作为键，比如 *UserService
+// as 用来同时注册为某些接口类型，使用接口指针表示，比如 (*UserStore)(nil)
+func (this *Container) Singleton(value interface{}, as ...interface{}) error {
+	if value == nil {
+		return errors.New("inject: singleton should not be nil")
+	}
+	var binding = &containerBinding{
+		valueType: reflect.TypeOf(value),
+		value:     reflect.ValueOf(value),
+	}
+	return this.bind(binding, as)
+}
+
+// Factory 注册请求范围的工厂函数，函数返回值的类型作为键
+// 工厂函数的形式为 func(参数...) T 或 func(参数...) (T, error)，参数可以是 *ActionObject、context.Context 或者容器中已注册的类型
+func (this *Container) Factory(factory interface{}, as ...interface{}) error {
+	var factoryValue = reflect.ValueOf(factory)
+	if factory == nil || factoryValue.Kind() != reflect.Func {
+		return errors.New("inject: factory should be a function")
+	}
+	var factoryType = factoryValue.Type()
+	if factoryType.NumOut() == 0 || factoryType.NumOut() > 2 || (factoryType.NumOut() == 2 && factoryType.Out(1) != errorType) {
+		return errors.New("inject: factory should return 'T' or '(T, error)'")
+	}
+	var binding = &containerBinding{
+		valueType: factoryType.Out(0),
+		factory:   factoryValue,
+	}
+	return this.bind(binding, as)
+}
+
+func (this *Container) bind(binding *containerBinding, as []interface{}) error {
+	var types = []reflect.Type{binding.valueType}
+	for _, asType := range as {
+		var t = reflect.TypeOf(asType)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+			return errors.New("inject: 'as' should be a pointer to interface, for example (*Store)(nil)")
+		}
+		if !binding.valueType.Implements(t.Elem()) {
+			return errors.New("inject: '" + binding.valueType.String() + "' does not implement '" + t.Elem().String() + "'")
+		}
+		types = append(types, t.Elem())
+	}
+
+	this.locker.Lock()
+	for _, t := range types {
+		this.bindings[t] = binding
+	}
+	this.locker.Unlock()
+	return nil
+}
+
+// 查找类型对应的绑定，接口类型没有直接绑定时查找唯一实现了此接口的绑定
+func (this *Container) lookup(valueType reflect.Type) (binding *containerBinding, err error) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	binding, ok := this.bindings[valueType]
+	if ok {
+		return binding, nil
+	}
+	if valueType.Kind() != reflect.Interface {
+		return nil, nil
+	}
+	for t, b := range this.bindings {
+		if t.Kind() == reflect.Interface || !t.Implements(valueType) {
+			continue
+		}
+		if binding != nil && binding != b {
+			return nil, errors.New("inject: '" + valueType.String() + "' is ambiguous, implemented by both '" + binding.valueType.String() + "' and '" + b.valueType.String() + "'")
+		}
+		binding = b
+	}
+	return binding, nil
+}
+
+// 判断字段是否需要注入，返回是否为可选
+func (this *Container) shouldInject(field reflect.StructField) (should bool, optional bool) {
+	tag, ok := field.Tag.Lookup("inject")
+	if ok {
+		return true, tag == "optional"
+	}
+	if field.Type.Kind() != reflect.Interface || field.Type == contextType {
+		return false, false
+	}
+	return this.has(field.Type), false
+}
+
+// 判断某个类型是否已注册
+func (this *Container) has(valueType reflect.Type) bool {
+	this.locker.RLock()
+	_, ok := this.bindings[valueType]
+	this.locker.RUnlock()
+	return ok
+}
+
+// Check 检查Action结构体和Run()参数中需要注入的字段是否都有对应的服务
+func (this *Container) Check(spec *ActionSpec) []error {
+	var result = this.checkStruct(spec.Type, spec.Type.String())
+
+	var funcNames = []string{}
+	for funcName := range spec.FuncMap {
+		funcNames = append(funcNames, funcName)
+	}
+	sort.Strings(funcNames)
+	for _, funcName := range funcNames {
+		var runMethodType = spec.FuncMap[funcName].Type()
+		if runMethodType.NumIn() == 2 && runMethodType.In(1).Kind() == reflect.Struct {
+			result = append(result, this.checkStruct(runMethodType.In(1), spec.Type.String()+"."+funcName+"()")...)
+		}
+	}
+	return result
+}
+
+func (this *Container) checkStruct(structType reflect.Type, prefix string) []error {
+	var result = []error{}
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		should, optional := this.shouldInject(field)
+		if !should || optional {
+			continue
+		}
+		err := this.checkType(field.Type, map[reflect.Type]bool{})
+		if err != nil {
+			result = append(result, errors.New("field '"+prefix+"."+field.Name+"': "+err.Error()))
+		}
+	}
+	return result
+}
+
+func (this *Container) checkType(valueType reflect.Type, visiting map[reflect.Type]bool) error {
+	if valueType == actionObjectPtrType || valueType == contextType {
+		return nil
+	}
+	binding, err := this.lookup(valueType)
+	if err != nil {
+		return err
+	}
+	if binding == nil {
+		return errors.New("inject: no binding for '" + valueType.String() + "'")
+	}
+	if !binding.factory.IsValid() {
+		return nil
+	}
+	if visiting[binding.valueType] {
+		return errors.New("inject: circular dependency on '" + binding.valueType.String() + "'")
+	}
+	visiting[binding.valueType] = true
+	defer delete(visiting, binding.valueType)
+
+	var factoryType = binding.factory.Type()
+	for i := 0; i < factoryType.NumIn(); i++ {
+		err = this.checkType(factoryType.In(i), visiting)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 请求范围的注入
+type containerScope struct {
+	container    *Container
+	actionObject *ActionObject
+	values       map[*containerBinding]reflect.Value
+	closers      []reflect.Value
+}
+
+func (this *Container) newScope(actionObject *ActionObject) *containerScope {
+	return &containerScope{
+		container:    this,
+		actionObject: actionObject,
+		values:       map[*containerBinding]reflect.Value{},
+	}
+}
+
+// 向结构体字段中注入服务，返回已经注入的字段索引
+func (this *containerScope) injectStruct(structValue reflect.Value) (injected map[int]bool) {
+	injected = map[int]bool{}
+	var structType = structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		should, optional := this.container.shouldInject(field)
+		if !should {
+			continue
+		}
+		var fieldValue = structValue.Field(i)
+		if !fieldValue.CanSet() {
+			continue
+		}
+		value, err := this.resolve(field.Type)
+		if err != nil {
+			if optional && strings.HasPrefix(err.Error(), "inject: no binding") {
+				injected[i] = true
+				continue
+			}
+			panic(errors.New("field '" + structType.String() + "." + field.Name + "': " + err.Error()))
+		}
+		fieldValue.Set(value)
+		injected[i] = true
+	}
+	return
+}
+
+// 取得某个类型的值
+func (this *containerScope) resolve(valueType reflect.Type) (reflect.Value, error) {
+	switch valueType {
+	case actionObjectPtrType:
+		return reflect.ValueOf(this.actionObject), nil
+	case contextType:
+		return reflect.ValueOf(this.actionObject.Ctx()), nil
+	}
+
+	binding, err := this.container.lookup(valueType)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if binding == nil {
+		return reflect.Value{}, errors.New("inject: no binding for '" + valueType.String() + "'")
+	}
+	if !binding.factory.IsValid() {
+		return binding.value, nil
+	}
+
+	value, ok := this.values[binding]
+	if ok {
+		return value, nil
+	}
+
+	var factoryType = binding.factory.Type()
+	var args = []reflect.Value{}
+	for i := 0; i < factoryType.NumIn(); i++ {
+		arg, err := this.resolve(factoryType.In(i))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		args = append(args, arg)
+	}
+	var results = binding.factory.Call(args)
+	if len(results) == 2 && !results[1].IsNil() {
+		return reflect.Value{}, results[1].Interface().(error)
+	}
+	value = results[0]
+	this.values[binding] = value
+	this.closers = append(this.closers, value)
+	return value, nil
+}
+
+// 关闭请求范围内生成的值，按照生成的相反顺序关闭
+func (this *containerScope) close() {
+	for i := len(this.closers) - 1; i >= 0; i-- {
+		var value = this.closers[i]
+		if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+			continue
+		}
+		switch closer := value.Interface().(type) {
+		case io.Closer:
+			_ = closer.Close()
+		case interface{ Close() }:
+			closer.Close()
+		}
+	}
+	this.closers = nil
+}
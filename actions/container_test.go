@@ -0,0 +1,130 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testUserStore interface {
+	Name(id int) string
+}
+
+type testMemoryStore struct {
+}
+
+func (this *testMemoryStore) Name(id int) string {
+	if id == 1 {
+		return "Lu"
+	}
+	return ""
+}
+
+type testTx struct {
+	ctx    context.Context
+	closed bool
+	calls  *[]string
+}
+
+func (this *testTx) Close() error {
+	this.closed = true
+	*this.calls = append(*this.calls, "close")
+	return nil
+}
+
+type testInjectAction struct {
+	Action
+
+	Store testUserStore
+	Tx    *testTx          `inject:""`
+	Cache *strings.Builder `inject:"optional"`
+}
+
+func (this *testInjectAction) Before() {
+	*this.Tx.calls = append(*this.Tx.calls, "before")
+}
+
+func (this *testInjectAction) After() {
+	*this.Tx.calls = append(*this.Tx.calls, "after")
+}
+
+func (this *testInjectAction) RunGet(params struct {
+	Id    int
+	Tx    *testTx `inject:""`
+	Store testUserStore
+}) {
+	if params.Tx != this.Tx {
+		this.Fail("factory should be called once in a request")
+	}
+	if this.Cache != nil {
+		this.Fail("optional field should be nil")
+	}
+	if params.Tx.ctx != this.Ctx() {
+		this.Fail("factory should receive action context")
+	}
+	this.Data["name"] = params.Store.Name(params.Id)
+	this.Success()
+}
+
+func TestContainer_Inject(t *testing.T) {
+	var calls = []string{}
+	var container = NewContainer()
+	err := container.Singleton(&testMemoryStore{}, (*testUserStore)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = container.Factory(func(ctx context.Context) *testTx {
+		calls = append(calls, "factory")
+		return &testTx{ctx: ctx, calls: &calls}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec = NewActionSpec(new(testInjectAction))
+	spec.Container = container
+	if errs := container.Check(spec); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	var recorder = httptest.NewRecorder()
+	RunAction(new(testInjectAction), spec, httptest.NewRequest(http.MethodGet, "/", nil), recorder, Params{"id": {"1"}}, nil, nil)
+	t.Log(recorder.Body.String(), calls)
+	if !strings.Contains(recorder.Body.String(), `"name":"Lu"`) {
+		t.Fatal("services should be injected")
+	}
+	if strings.Join(calls, ",") != "factory,before,after,close" {
+		t.Fatal("request-scoped values should be closed after After()")
+	}
+}
+
+func TestContainer_Check(t *testing.T) {
+	var container = NewContainer()
+	var errs = container.Check(NewActionSpec(new(testInjectAction)))
+	t.Log(errs)
+	if len(errs) != 2 {
+		t.Fatal("expected 2 missing bindings, but got", len(errs))
+	}
+	if !strings.Contains(errs[0].Error(), "no binding for '*actions.testTx'") {
+		t.Fatal("unexpected error:", errs[0])
+	}
+
+	// 工厂函数的参数也需要有对应的服务
+	_ = container.Factory(func(store testUserStore) (*testTx, error) {
+		return nil, errors.New("unreachable")
+	})
+	errs = container.Check(NewActionSpec(new(testInjectAction)))
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "no binding for 'actions.testUserStore'") {
+		t.Fatal("factory dependencies should be checked:", errs)
+	}
+
+	if container.Singleton(&testMemoryStore{}, (*error)(nil)) == nil {
+		t.Fatal("singleton should implement 'as' interfaces")
+	}
+	if container.Factory(func() {}) == nil {
+		t.Fatal("factory should return a value")
+	}
+}
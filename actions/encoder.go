@@ -0,0 +1,319 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"gopkg.in/yaml.v3"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResponseEncoder 响应内容编码器，根据请求的 Accept 选择
+type ResponseEncoder struct {
+	ContentType string   // 输出的Content-Type
+	MediaTypes  []string // 可以匹配 Accept 中的媒体类型
+
+	// Encode 编码数据，pretty 表示是否格式化输出
+	// 内置的 JSON 和 XML 编码器支持格式化输出，YAML 和 msgpack 编码器会忽略 pretty 参数
+	Encode func(value interface{}, pretty bool) ([]byte, error)
+}
+
+var responseEncoders = []*ResponseEncoder{}
+var responseEncoderNames = []string{}
+var responseEncodersLocker = sync.RWMutex{}
+
+var xmlNameReg = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+
+func init() {
+	RegisterResponseEncoder("json", &ResponseEncoder{
+		ContentType: "application/json; charset=utf-8",
+		MediaTypes:  []string{"application/json", "text/json"},
+		Encode:      encodeJSON,
+	})
+	RegisterResponseEncoder("xml", &ResponseEncoder{
+		ContentType: "application/xml; charset=utf-8",
+		MediaTypes:  []string{"application/xml", "text/xml"},
+		Encode:      encodeXML,
+	})
+	RegisterResponseEncoder("yaml", &ResponseEncoder{
+		ContentType: "application/yaml; charset=utf-8",
+		MediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"},
+		Encode:      encodeYAML,
+	})
+	RegisterResponseEncoder("msgpack", &ResponseEncoder{
+		ContentType: "application/msgpack",
+		MediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		Encode:      encodeMsgPack,
+	})
+}
+
+// RegisterResponseEncoder 注册响应编码器，相同名称的编码器会被替换
+// 第一个注册的编码器（json）为默认编码器，在请求没有 Accept 或者都不匹配时使用
+func RegisterResponseEncoder(name string, encoder *ResponseEncoder) {
+	responseEncodersLocker.Lock()
+	defer responseEncodersLocker.Unlock()
+
+	for index, encoderName := range responseEncoderNames {
+		if encoderName == name {
+			responseEncoders[index] = encoder
+			return
+		}
+	}
+	responseEncoderNames = append(responseEncoderNames, name)
+	responseEncoders = append(responseEncoders, encoder)
+}
+
+// NegotiateResponseEncoder 根据 Accept 选择编码器
+// Accept 中含有 text/html 时认为是浏览器直接访问，使用默认编码器
+func NegotiateResponseEncoder(accept string) *ResponseEncoder {
+	responseEncodersLocker.RLock()
+	defer responseEncodersLocker.RUnlock()
+
+	var defaultEncoder = responseEncoders[0]
+	if len(accept) == 0 {
+		return defaultEncoder
+	}
+
+	type acceptType struct {
+		mediaType string
+		q         float64
+	}
+	var acceptTypes = []acceptType{}
+	for _, piece := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(piece))
+		if err != nil {
+			continue
+		}
+		var q = 1.0
+		qString, ok := params["q"]
+		if ok {
+			q, err = strconv.ParseFloat(qString, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if mediaType == "text/html" {
+			return defaultEncoder
+		}
+		acceptTypes = append(acceptTypes, acceptType{
+			mediaType: mediaType,
+			q:         q,
+		})
+	}
+	sort.SliceStable(acceptTypes, func(i, j int) bool {
+		return acceptTypes[i].q > acceptTypes[j].q
+	})
+
+	for _, acceptType := range acceptTypes {
+		if acceptType.mediaType == "*/*" {
+			return defaultEncoder
+		}
+		if strings.HasSuffix(acceptType.mediaType, "+json") {
+			return defaultEncoder
+		}
+		var isWildcard = strings.HasSuffix(acceptType.mediaType, "/*")
+		for _, encoder := range responseEncoders {
+			for _, mediaType := range encoder.MediaTypes {
+				if mediaType == acceptType.mediaType || (isWildcard && strings.HasPrefix(mediaType, acceptType.mediaType[:len(acceptType.mediaType)-1])) {
+					return encoder
+				}
+			}
+		}
+	}
+	return defaultEncoder
+}
+
+// 编码为JSON
+func encodeJSON(value interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(value, "", "   ")
+	}
+	return json.Marshal(value)
+}
+
+// 编码为YAML
+func encodeYAML(value interface{}, pretty bool) ([]byte, error) {
+	genericValue, err := toGenericValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(genericValue)
+}
+
+// 编码为XML，根元素为 response，数组中的元素使用 item，不能作为元素名的键使用 <item key="键">
+func encodeXML(value interface{}, pretty bool) ([]byte, error) {
+	genericValue, err := toGenericValue(value)
+	if err != nil {
+		return nil, err
+	}
+	var buf = &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	writeXMLElement(buf, "response", "", genericValue, pretty, 0)
+	if pretty {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXMLElement(buf *bytes.Buffer, name string, key string, value interface{}, pretty bool, depth int) {
+	if pretty && depth > 0 {
+		buf.WriteString("\n" + strings.Repeat("  ", depth))
+	}
+	buf.WriteString("<" + name)
+	if len(key) > 0 {
+		buf.WriteString(` key="`)
+		_ = xml.EscapeText(buf, []byte(key))
+		buf.WriteString(`"`)
+	}
+	if value == nil {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteString(">")
+
+	var hasChildren = false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var keys = []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if xmlNameReg.MatchString(k) && !strings.HasPrefix(strings.ToLower(k), "xml") {
+				writeXMLElement(buf, k, "", v[k], pretty, depth+1)
+			} else {
+				writeXMLElement(buf, "item", k, v[k], pretty, depth+1)
+			}
+		}
+		hasChildren = len(keys) > 0
+	case []interface{}:
+		for _, item := range v {
+			writeXMLElement(buf, "item", "", item, pretty, depth+1)
+		}
+		hasChildren = len(v) > 0
+	case string:
+		_ = xml.EscapeText(buf, []byte(v))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	if pretty && hasChildren {
+		buf.WriteString("\n" + strings.Repeat("  ", depth))
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+// 将值转换为只包含 map[string]interface{}、[]interface{}、string、bool、int64、uint64、float64 和 nil 的值
+// 使用JSON作为中间格式，以便各种编码器都使用 json 标签中的名称
+func toGenericValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var result interface{}
+	err = decoder.Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return convertJSONNumbers(result), nil
+}
+
+func convertJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = convertJSONNumbers(item)
+		}
+	case []interface{}:
+		for index, item := range v {
+			v[index] = convertJSONNumbers(item)
+		}
+	case json.Number:
+		i, err := strconv.ParseInt(string(v), 10, 64)
+		if err == nil {
+			return i
+		}
+		u, err := strconv.ParseUint(string(v), 10, 64)
+		if err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// NegotiateEncoding 根据 Accept-Encoding 从支持的编码中选择一个，没有合适的则返回空
+// 权重相同时，以 supported 中的顺序为准
+func NegotiateEncoding(acceptEncoding string, supported []string) string {
+	if len(acceptEncoding) == 0 || len(supported) == 0 {
+		return ""
+	}
+
+	var qualities = map[string]float64{}
+	for _, piece := range strings.Split(acceptEncoding, ",") {
+		var name = piece
+		var quality = 1.0
+		index := strings.Index(piece, ";")
+		if index > -1 {
+			name = piece[:index]
+			for _, param := range strings.Split(piece[index+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					q, err := strconv.ParseFloat(param[2:], 64)
+					if err == nil {
+						quality = q
+					}
+				}
+			}
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) > 0 {
+			qualities[name] = quality
+		}
+	}
+
+	var result = ""
+	var resultQuality = 0.0
+	for _, encoding := range supported {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > resultQuality {
+			result = encoding
+			resultQuality = quality
+		}
+	}
+	return result
+}
+
+// AddVaryHeader 给响应的 Vary 加上某个请求Header，已经存在时不再重复添加
+func AddVaryHeader(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, piece := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(piece), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// 编码为MessagePack，https://github.com/msgpack/msgpack/blob/master/spec.md
+// 对象中的键按照字母顺序排列
+func encodeMsgPack(value interface{}, pretty bool) ([]byte, error) {
+	genericValue, err := toGenericValue(value)
+	if err != nil {
+		return nil, err
+	}
+	var buf = &bytes.Buffer{}
+	err = writeMsgPack(buf, genericValue)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMsgPack(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int64:
+		writeMsgPackInt(buf, v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			writeMsgPackInt(buf, int64(v))
+		} else {
+			buf.WriteByte(0xcf)
+			_ = binary.Write(buf, binary.BigEndian, v)
+		}
+	case float64:
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		var length = len(v)
+		switch {
+		case length < 32:
+			buf.WriteByte(0xa0 | byte(length))
+		case length <= math.MaxUint8:
+			buf.WriteByte(0xd9)
+			buf.WriteByte(byte(length))
+		case length <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			_ = binary.Write(buf, binary.BigEndian, uint16(length))
+		default:
+			buf.WriteByte(0xdb)
+			_ = binary.Write(buf, binary.BigEndian, uint32(length))
+		}
+		buf.WriteString(v)
+	case []interface{}:
+		var length = len(v)
+		switch {
+		case length < 16:
+			buf.WriteByte(0x90 | byte(length))
+		case length <= math.MaxUint16:
+			buf.WriteByte(0xdc)
+			_ = binary.Write(buf, binary.BigEndian, uint16(length))
+		default:
+			buf.WriteByte(0xdd)
+			_ = binary.Write(buf, binary.BigEndian, uint32(length))
+		}
+		for _, item := range v {
+			err := writeMsgPack(buf, item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		var length = len(v)
+		switch {
+		case length < 16:
+			buf.WriteByte(0x80 | byte(length))
+		case length <= math.MaxUint16:
+			buf.WriteByte(0xde)
+			_ = binary.Write(buf, binary.BigEndian, uint16(length))
+		default:
+			buf.WriteByte(0xdf)
+			_ = binary.Write(buf, binary.BigEndian, uint32(length))
+		}
+		var keys = []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			_ = writeMsgPack(buf, key)
+			err := writeMsgPack(buf, v[key])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("msgpack: unsupported type")
+	}
+	return nil
+}
+
+// 使用最短的格式写入整数
+func writeMsgPackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
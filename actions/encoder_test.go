@@ -0,0 +1,111 @@
+package actions
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateResponseEncoder(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                                  "application/json; charset=utf-8",
+		"*/*":                               "application/json; charset=utf-8",
+		"application/xml":                   "application/xml; charset=utf-8",
+		"text/yaml":                         "application/yaml; charset=utf-8",
+		"application/x-msgpack":             "application/msgpack",
+		"application/json;q=0.5, text/xml":  "application/xml; charset=utf-8",
+		"application/xml;q=0, text/plain":   "application/json; charset=utf-8",
+		"application/problem+json":          "application/json; charset=utf-8",
+		"text/html,application/xml;q=0.9":   "application/json; charset=utf-8",
+		"application/msgpack;q=0.8, text/*": "application/json; charset=utf-8",
+	} {
+		var contentType = NegotiateResponseEncoder(accept).ContentType
+		if contentType != expected {
+			t.Fatal("'"+accept+"': expected", expected, "got", contentType)
+		}
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	data, err := encodeXML(JSON{
+		"code": 200,
+		"data": Data{
+			"name":  "Lu & Co",
+			"tags":  []string{"a", "b"},
+			"1st":   true,
+			"empty": nil,
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(data))
+	if !strings.HasSuffix(string(data), `<response><code>200</code><data><item key="1st">true</item><empty/><name>Lu &amp; Co</name><tags><item>a</item><item>b</item></tags></data></response>`) {
+		t.Fatal("invalid xml")
+	}
+}
+
+func TestEncodeMsgPack(t *testing.T) {
+	data, err := encodeMsgPack(JSON{
+		"a": 1,
+		"b": []interface{}{-1, 300, "hi", nil, true, 1.5},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = []byte{
+		0x82,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0x96, 0xff, 0xd1, 0x01, 0x2c, 0xa2, 'h', 'i', 0xc0, 0xc3,
+		0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("invalid msgpack: % x", data)
+	}
+}
+
+func TestActionObject_WriteJSON(t *testing.T) {
+	var recorder = httptest.NewRecorder()
+	var request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept", "application/yaml")
+	var action = &ActionObject{
+		Request:        request,
+		ResponseWriter: recorder,
+	}
+	action.WriteJSON(map[string]interface{}{
+		"name": "Lu",
+	})
+	t.Log(recorder.Body.String())
+	if recorder.Header().Get("Content-Type") != "application/yaml; charset=utf-8" || recorder.Body.String() != "name: Lu\n" {
+		t.Fatal("invalid yaml output")
+	}
+
+	// Vary 不会重复添加
+	action.WriteJSON(map[string]interface{}{
+		"name": "Lu",
+	})
+	if len(recorder.Header().Values("Vary")) != 1 {
+		t.Fatal("Vary should be added once:", recorder.Header().Values("Vary"))
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for acceptEncoding, expected := range map[string]string{
+		"":                         "",
+		"gzip":                     "gzip",
+		"deflate, gzip":            "gzip",
+		"gzip;q=0.5, deflate":      "deflate",
+		"gzip;q=0, deflate;q=0":    "",
+		"*":                        "gzip",
+		"br":                       "",
+		"identity, *;q=0":          "",
+		"GZIP ; q=0.8 , br;q=1.0 ": "gzip",
+	} {
+		result := NegotiateEncoding(acceptEncoding, []string{"gzip", "deflate"})
+		if result != expected {
+			t.Fatal("'" + acceptEncoding + "': expected '" + expected + "', but got '" + result + "'")
+		}
+	}
+}
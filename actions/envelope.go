@@ -0,0 +1,97 @@
+package actions
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Envelope Success()和Fail()输出的数据
+type Envelope struct {
+	Success bool
+	Code    int // 业务代码，成功时默认为200，失败时默认为400
+	Message string
+	Data    Data
+	Errors  []ActionParamError
+	Next    interface{} // 通过 Next() 或 Refresh() 设置的下一个动作，没有设置时为nil
+}
+
+// EnvelopeFormatter 将输出的数据转换为需要编码的值，同时返回HTTP状态码
+type EnvelopeFormatter func(envelope *Envelope) (value interface{}, status int)
+
+var envelopeFormatter EnvelopeFormatter
+var moduleEnvelopeFormatters = map[string]EnvelopeFormatter{}
+var envelopeFormattersLocker = sync.RWMutex{}
+
+// SetEnvelopeFormatter 设置全局的输出格式，为nil时表示使用 DefaultEnvelopeFormatter
+func SetEnvelopeFormatter(formatter EnvelopeFormatter) {
+	envelopeFormattersLocker.Lock()
+	envelopeFormatter = formatter
+	envelopeFormattersLocker.Unlock()
+}
+
+// SetModuleEnvelopeFormatter 设置某个模块的输出格式，优先于全局的输出格式，模块为空表示默认模块
+// formatter 为nil时表示使用全局的输出格式
+func SetModuleEnvelopeFormatter(module string, formatter EnvelopeFormatter) {
+	envelopeFormattersLocker.Lock()
+	if formatter == nil {
+		delete(moduleEnvelopeFormatters, module)
+	} else {
+		moduleEnvelopeFormatters[module] = formatter
+	}
+	envelopeFormattersLocker.Unlock()
+}
+
+// 查找模块对应的输出格式
+func findEnvelopeFormatter(module string) EnvelopeFormatter {
+	envelopeFormattersLocker.RLock()
+	defer envelopeFormattersLocker.RUnlock()
+
+	formatter, ok := moduleEnvelopeFormatters[module]
+	if ok {
+		return formatter
+	}
+	if envelopeFormatter != nil {
+		return envelopeFormatter
+	}
+	return DefaultEnvelopeFormatter
+}
+
+// DefaultEnvelopeFormatter 默认的输出格式 {code, message, data, errors, next}，HTTP状态码总是200
+func DefaultEnvelopeFormatter(envelope *Envelope) (value interface{}, status int) {
+	var result = JSON{
+		"code":    envelope.Code,
+		"message": envelope.Message,
+		"data":    envelope.Data,
+	}
+	if !envelope.Success {
+		result["errors"] = envelope.Errors
+	}
+	if envelope.Next != nil {
+		result["next"] = envelope.Next
+	}
+	return result, http.StatusOK
+}
+
+// StatusEnvelopeFormatter 使用业务代码作为HTTP状态码，输出中不再包含 code
+func StatusEnvelopeFormatter(envelope *Envelope) (value interface{}, status int) {
+	var result = JSON{
+		"message": envelope.Message,
+		"data":    envelope.Data,
+	}
+	if !envelope.Success {
+		result["errors"] = envelope.Errors
+	}
+	if envelope.Next != nil {
+		result["next"] = envelope.Next
+	}
+
+	status = envelope.Code
+	if status < 100 || status > 999 {
+		if envelope.Success {
+			status = http.StatusOK
+		} else {
+			status = http.StatusBadRequest
+		}
+	}
+	return result, status
+}
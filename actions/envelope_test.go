@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testEnvelopeRun(module string, accept string, f func(action *ActionObject)) *httptest.ResponseRecorder {
+	var recorder = httptest.NewRecorder()
+	var request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if len(accept) > 0 {
+		request.Header.Set("Accept", accept)
+	}
+	var action = &ActionObject{
+		Request:        request,
+		ResponseWriter: recorder,
+		Module:         module,
+		Data:           Data{},
+	}
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		f(action)
+	}()
+	return recorder
+}
+
+func TestEnvelope_Default(t *testing.T) {
+	var recorder = testEnvelopeRun("", "", func(action *ActionObject) {
+		action.Data["name"] = "Lu"
+		action.Success()
+	})
+	t.Log(recorder.Body.String())
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"code":200,"data":{"name":"Lu"},"message":""}` {
+		t.Fatal("invalid default envelope")
+	}
+
+	recorder = testEnvelopeRun("", "application/xml", func(action *ActionObject) {
+		action.Fail("invalid name")
+	})
+	t.Log(recorder.Body.String())
+	if recorder.Header().Get("Content-Type") != "application/xml; charset=utf-8" {
+		t.Fatal("xml should be used")
+	}
+}
+
+func TestEnvelope_Formatter(t *testing.T) {
+	SetModuleEnvelopeFormatter("gateway", func(envelope *Envelope) (value interface{}, status int) {
+		var result = JSON{
+			"ok":     envelope.Success,
+			"result": envelope.Data,
+		}
+		if !envelope.Success {
+			result["error"] = envelope.Message
+		}
+		return result, http.StatusOK
+	})
+	SetEnvelopeFormatter(StatusEnvelopeFormatter)
+	defer func() {
+		SetModuleEnvelopeFormatter("gateway", nil)
+		SetEnvelopeFormatter(nil)
+	}()
+
+	var recorder = testEnvelopeRun("gateway", "", func(action *ActionObject) {
+		action.Fail("not found")
+	})
+	t.Log(recorder.Body.String())
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"error":"not found","ok":false,"result":{}}` {
+		t.Fatal("invalid module envelope")
+	}
+
+	recorder = testEnvelopeRun("admin", "", func(action *ActionObject) {
+		action.Code = http.StatusNotFound
+		action.Fail("not found")
+	})
+	t.Log(recorder.Code, recorder.Body.String())
+	if recorder.Code != http.StatusNotFound || recorder.Body.String() != `{"data":{},"errors":null,"message":"not found"}` {
+		t.Fatal("invalid status envelope")
+	}
+}
@@ -0,0 +1,245 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventStreamDefaultHeartbeatInterval 默认的心跳间隔
+var EventStreamDefaultHeartbeatInterval = 15 * time.Second
+
+// ErrEventStreamClosed 事件流已关闭，通常是因为客户端断开了连接
+var ErrEventStreamClosed = errors.New("event stream: closed")
+
+// EventStreamOptions 事件流选项
+type EventStreamOptions struct {
+	Retry             time.Duration // 客户端断开后重连的等待时间，为0表示使用客户端默认值
+	HeartbeatInterval time.Duration // 发送心跳注释的间隔，以防止代理断开空闲连接，小于0表示不发送，默认为 EventStreamDefaultHeartbeatInterval
+}
+
+// ServerEvent 单个事件
+type ServerEvent struct {
+	Id    string        // 事件ID，客户端重连时会在 Last-Event-ID 中带上最后收到的ID
+	Event string        // 事件名称，为空时客户端触发 message 事件
+	Data  string        // 数据，可以包含多行
+	Retry time.Duration // 重连等待时间
+}
+
+// EventStream Server-Sent Events 输出
+// 可以在多个协程中同时发送事件，客户端断开或者动作执行结束后自动关闭
+type EventStream struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+
+	lastEventId string
+
+	locker    sync.Mutex
+	closed    bool
+	closeChan chan bool
+	closeOnce sync.Once
+	err       error
+}
+
+// EventStream 以 text/event-stream 格式输出事件
+// 当前的ResponseWriter不支持 http.Flusher 时会输出错误并结束当前动作
+func (this *ActionObject) EventStream(options ...*EventStreamOptions) *EventStream {
+	if this.eventStream != nil {
+		return this.eventStream
+	}
+
+	var opts = EventStreamOptions{}
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if opts.HeartbeatInterval == 0 {
+		opts.HeartbeatInterval = EventStreamDefaultHeartbeatInterval
+	}
+
+	flusher, ok := this.ResponseWriter.(http.Flusher)
+	if !ok {
+		http.Error(this.ResponseWriter, "streaming is not supported", http.StatusInternalServerError)
+		panic(this)
+	}
+
+	// 不再使用压缩等自定义输出
+	this.detachWriter()
+
+	var header = this.ResponseWriter.Header()
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	this.ResponseWriter.WriteHeader(http.StatusOK)
+
+	var lastEventId = this.Request.Header.Get("Last-Event-ID")
+	if len(lastEventId) == 0 {
+		lastEventId = this.Request.URL.Query().Get("lastEventId")
+	}
+
+	var stream = &EventStream{
+		writer:      this.ResponseWriter,
+		flusher:     flusher,
+		ctx:         this.Request.Context(),
+		lastEventId: lastEventId,
+		closeChan:   make(chan bool),
+	}
+	this.eventStream = stream
+
+	if opts.Retry > 0 {
+		_ = stream.write("retry: " + strconv.FormatInt(opts.Retry.Milliseconds(), 10) + "\n\n")
+	} else {
+		stream.locker.Lock()
+		stream.flusher.Flush()
+		stream.locker.Unlock()
+	}
+
+	go stream.watch(opts.HeartbeatInterval)
+
+	return stream
+}
+
+// LastEventId 客户端重连时带上的最后收到的事件ID
+func (this *EventStream) LastEventId() string {
+	return this.lastEventId
+}
+
+// Send 发送一个事件
+func (this *EventStream) Send(event *ServerEvent) error {
+	var builder = strings.Builder{}
+	if len(event.Id) > 0 {
+		builder.WriteString("id: " + removeLineBreaks(event.Id) + "\n")
+	}
+	if len(event.Event) > 0 {
+		builder.WriteString("event: " + removeLineBreaks(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		builder.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data = strings.ReplaceAll(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		builder.WriteString("data: " + line + "\n")
+	}
+	builder.WriteString("\n")
+	return this.write(builder.String())
+}
+
+// SendData 发送只有数据的事件
+func (this *EventStream) SendData(data string) error {
+	return this.Send(&ServerEvent{
+		Data: data,
+	})
+}
+
+// SendEvent 发送有名称的事件
+func (this *EventStream) SendEvent(event string, data string) error {
+	return this.Send(&ServerEvent{
+		Event: event,
+		Data:  data,
+	})
+}
+
+// SendJSON 将值编码为JSON后作为事件数据发送，event 为空时客户端触发 message 事件
+func (this *EventStream) SendJSON(event string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return this.Send(&ServerEvent{
+		Event: event,
+		Data:  string(data),
+	})
+}
+
+// Comment 发送注释，客户端会忽略
+func (this *EventStream) Comment(comment string) error {
+	var builder = strings.Builder{}
+	for _, line := range strings.Split(strings.ReplaceAll(comment, "\r", ""), "\n") {
+		builder.WriteString(": " + line + "\n")
+	}
+	builder.WriteString("\n")
+	return this.write(builder.String())
+}
+
+// Done 事件流关闭时会关闭此通道，可以用来在客户端断开后停止发送
+func (this *EventStream) Done() <-chan bool {
+	return this.closeChan
+}
+
+// Err 事件流关闭的原因
+func (this *EventStream) Err() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.err
+}
+
+// Close 关闭事件流，关闭后不能再发送事件
+func (this *EventStream) Close() {
+	this.closeWithError(ErrEventStreamClosed)
+}
+
+// 写入数据并立即发送给客户端
+func (this *EventStream) write(s string) error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.closed {
+		return this.err
+	}
+	_, err := this.writer.Write([]byte(s))
+	if err != nil {
+		this.closeLocked(err)
+		return err
+	}
+	this.flusher.Flush()
+	return nil
+}
+
+// 发送心跳，并在客户端断开时关闭
+func (this *EventStream) watch(heartbeatInterval time.Duration) {
+	var heartbeatChan <-chan time.Time
+	if heartbeatInterval > 0 {
+		var ticker = time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		heartbeatChan = ticker.C
+	}
+
+	for {
+		select {
+		case <-heartbeatChan:
+			if this.write(": heartbeat\n\n") != nil {
+				return
+			}
+		case <-this.ctx.Done():
+			this.closeWithError(this.ctx.Err())
+			return
+		case <-this.closeChan:
+			return
+		}
+	}
+}
+
+func (this *EventStream) closeWithError(err error) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	this.closeLocked(err)
+}
+
+func (this *EventStream) closeLocked(err error) {
+	this.closeOnce.Do(func() {
+		this.closed = true
+		this.err = err
+		close(this.closeChan)
+	})
+}
+
+// 去除换行符
+func removeLineBreaks(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
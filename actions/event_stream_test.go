@@ -0,0 +1,105 @@
+package actions
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var action = &ActionObject{
+			Request:        request,
+			ResponseWriter: writer,
+		}
+		var stream = action.EventStream(&EventStreamOptions{
+			Retry:             3 * time.Second,
+			HeartbeatInterval: 50 * time.Millisecond,
+		})
+		_ = stream.Send(&ServerEvent{
+			Id:    "2",
+			Event: "progress",
+			Data:  "line1\nline2",
+		})
+		_ = stream.SendData(stream.LastEventId())
+		time.Sleep(80 * time.Millisecond)
+		stream.Close()
+		if stream.SendData("closed") != ErrEventStreamClosed {
+			t.Fatal("send should fail after close")
+		}
+	}))
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatal("invalid content type:", resp.Header.Get("Content-Type"))
+	}
+
+	var lines = []string{}
+	var scanner = bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	var body = strings.Join(lines, "\n")
+	t.Log(body)
+
+	if !strings.HasPrefix(body, "retry: 3000\n\nid: 2\nevent: progress\ndata: line1\ndata: line2\n\ndata: 1\n\n") {
+		t.Fatal("invalid events")
+	}
+	if !strings.Contains(body, ": heartbeat") {
+		t.Fatal("heartbeat should be sent")
+	}
+}
+
+func TestEventStream_Disconnect(t *testing.T) {
+	var closedChan = make(chan error, 1)
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var action = &ActionObject{
+			Request:        request,
+			ResponseWriter: writer,
+		}
+		var stream = action.EventStream()
+		_ = stream.SendData("hello")
+		select {
+		case <-stream.Done():
+			closedChan <- stream.Err()
+		case <-time.After(5 * time.Second):
+			closedChan <- nil
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "data: hello\n" {
+		t.Fatal("invalid event:", line)
+	}
+	_ = resp.Body.Close()
+
+	err = <-closedChan
+	if err == nil {
+		t.Fatal("stream should be closed after client disconnected")
+	}
+	t.Log(err)
+}
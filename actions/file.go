@@ -10,22 +10,29 @@ import (
 
 // File 上传的文件封装
 type File struct {
-	OriginFile *multipart.FileHeader
+	OriginFile *multipart.FileHeader // 流式上传时为nil
 
 	Filename    string
 	Size        int64
 	Field       string
 	Ext         string
-	ContentType string
+	ContentType string // 客户端提供的类型
+	MimeType    string // 根据文件内容检测到的类型
+	Path        string // 流式上传时文件在磁盘上的路径
+
+	isTmp bool // 是否为需要在请求结束后删除的临时文件
 }
 
 func (this *File) Reader() (io.ReadCloser, error) {
+	if this.OriginFile == nil {
+		return os.Open(this.Path)
+	}
 	return this.OriginFile.Open()
 }
 
 // Read 读取文件内容
 func (this *File) Read() ([]byte, error) {
-	reader, err := this.OriginFile.Open()
+	reader, err := this.Reader()
 	if err != nil {
 		return nil, err
 	}
@@ -70,3 +77,37 @@ func (this *File) WriteToPath(path string) (int64, error) {
 
 	return this.WriteTo(fp)
 }
+
+// MoveTo 将文件移动到一个文件路径中，流式上传的临时文件会直接重命名，移动后不会在请求结束时被删除
+func (this *File) MoveTo(path string) error {
+	if this.OriginFile == nil && len(this.Path) > 0 {
+		var file = files.NewFile(filepath.Dir(path))
+		if !file.Exists() {
+			err := file.MkdirAll()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := os.Rename(this.Path, path)
+		if err == nil {
+			this.Path = path
+			this.isTmp = false
+			return nil
+		}
+	}
+
+	// 不能重命名时（比如跨设备）复制文件
+	_, err := this.WriteToPath(path)
+	if err != nil {
+		return err
+	}
+	if this.isTmp {
+		_ = os.Remove(this.Path)
+		this.isTmp = false
+	}
+	if this.OriginFile == nil {
+		this.Path = path
+	}
+	return nil
+}
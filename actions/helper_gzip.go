@@ -5,14 +5,16 @@ import (
 	"compress/gzip"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/types"
+	"net/http"
 	"regexp"
 )
 
 type Gzip struct {
 	Level int
 
-	actionPtr  ActionWrapper
-	gzipWriter *gzip.Writer
+	actionPtr   ActionWrapper
+	gzipWriter  *gzip.Writer
+	wroteHeader bool
 }
 
 func (this *Gzip) BeforeAction(ptr ActionWrapper, paramName string) (goNext bool) {
@@ -37,6 +39,11 @@ func (this *Gzip) BeforeAction(ptr ActionWrapper, paramName string) (goNext bool
 		}
 	}
 
+	// 客户端不支持gzip时不压缩，包括 gzip;q=0 的情况
+	if NegotiateEncoding(ptr.Object().Request.Header.Get("Accept-Encoding"), []string{"gzip"}) != "gzip" {
+		return true
+	}
+
 	gzipWriter, err := gzip.NewWriterLevel(this.actionPtr.Object().ResponseWriter, this.Level)
 	if err != nil {
 		logs.Error(err)
@@ -51,11 +58,15 @@ func (this *Gzip) Write(data []byte) (n int, err error) {
 	var action = this.actionPtr.Object()
 
 	if this.gzipWriter != nil {
-		var header = action.ResponseWriter.Header()
-		header.Set("Content-Encoding", "gzip")
-		header.Set("Transfer-Encoding", "chunked")
-		header.Set("Vary", "Accept-Encoding")
-		header.Set("Accept-encoding", "gzip, deflate, br")
+		// 只在第一次输出之前设置压缩相关的Header
+		if !this.wroteHeader {
+			this.wroteHeader = true
+
+			var header = action.ResponseWriter.Header()
+			header.Set("Content-Encoding", "gzip")
+			header.Del("Content-Length")
+			AddVaryHeader(header, "Accept-Encoding")
+		}
 
 		n, err = this.gzipWriter.Write(data)
 	} else {
@@ -65,6 +76,16 @@ func (this *Gzip) Write(data []byte) (n int, err error) {
 	return
 }
 
+func (this *Gzip) Flush() {
+	if this.gzipWriter != nil {
+		_ = this.gzipWriter.Flush()
+	}
+	flusher, ok := this.actionPtr.Object().ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
 func (this *Gzip) AfterAction() {
 	if this.gzipWriter != nil {
 		_ = this.gzipWriter.Close()
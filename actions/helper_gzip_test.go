@@ -0,0 +1,54 @@
+package actions
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testGzipAction Action
+
+func (this *testGzipAction) RunGet(params struct {
+	Gzip *Gzip
+}) {
+	this.ResponseWriter.Header().Set("Vary", "Accept-Encoding")
+	this.WriteString("hello, ")
+	this.WriteString("world")
+}
+
+func TestGzip_Write(t *testing.T) {
+	var request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	var recorder = httptest.NewRecorder()
+	RunAction(new(testGzipAction), NewActionSpec(new(testGzipAction)), request, recorder, nil, nil, nil)
+
+	var header = recorder.Header()
+	t.Log(header)
+	if header.Get("Content-Encoding") != "gzip" || len(header.Values("Vary")) != 1 {
+		t.Fatal("encoding headers should be set once")
+	}
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Fatal("unexpected body:", string(data))
+	}
+}
+
+func TestGzip_Refused(t *testing.T) {
+	var request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Encoding", "gzip;q=0, deflate")
+	var recorder = httptest.NewRecorder()
+	RunAction(new(testGzipAction), NewActionSpec(new(testGzipAction)), request, recorder, nil, nil, nil)
+	if len(recorder.Header().Get("Content-Encoding")) > 0 || recorder.Body.String() != "hello, world" {
+		t.Fatal("gzip;q=0 should not be compressed")
+	}
+}
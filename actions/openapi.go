@@ -0,0 +1,335 @@
+package actions
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var fileType = reflect.TypeOf(File{})
+
+// OpenAPIParam Run()方法中的一个参数，用来生成OpenAPI文档
+type OpenAPIParam struct {
+	Name        string
+	Field       string // 结构体中的字段名
+	Cookie      bool   // 是否从Cookie中读取
+	File        bool   // 是否为上传的文件
+	Required    bool
+	Description string
+	Schema      map[string]interface{}
+}
+
+// RunParams 取得某个请求方法对应的Run()方法中的参数，用来生成OpenAPI文档
+// 使用 doc 或 description 标签为参数添加描述，使用 example 标签添加示例
+// 如果没有对应的Run()方法则返回 found=false
+func (this *ActionSpec) RunParams(method string) (params []OpenAPIParam, found bool) {
+	method = strings.ToUpper(method)
+	if len(method) == 0 {
+		return nil, false
+	}
+	runFuncValue, found := this.FuncMap["Run"+method[:1]+strings.ToLower(method[1:])]
+	if !found {
+		runFuncValue, found = this.FuncMap["Run"]
+		if !found {
+			return nil, false
+		}
+	}
+
+	var runMethodType = runFuncValue.Type()
+	if runMethodType.NumIn() != 2 || runMethodType.In(1).Kind() != reflect.Struct {
+		return nil, true
+	}
+
+	var argType = runMethodType.In(1)
+	for i := 0; i < argType.NumField(); i++ {
+		var field = argType.Field(i)
+		if len(field.Name) == 0 || len(field.PkgPath) > 0 {
+			continue
+		}
+
+		// Session中的数据不是请求参数
+		sessionName, ok := field.Tag.Lookup("session")
+		if ok && len(sessionName) > 0 {
+			continue
+		}
+
+		// 注入的服务不是请求参数
+		_, ok = field.Tag.Lookup("inject")
+		if ok || (this.Container != nil && field.Type.Kind() == reflect.Interface && this.Container.has(field.Type)) {
+			continue
+		}
+
+		var param = OpenAPIParam{
+			Name:  field.Name,
+			Field: field.Name,
+		}
+
+		// 文件
+		if field.Type == fileType || field.Type == reflect.PtrTo(fileType) || field.Type == fileSliceType {
+			bindName, ok := field.Tag.Lookup("field")
+			if ok {
+				param.Name = bindName
+			} else {
+				param.Name = strings.ToLower(field.Name[:1]) + field.Name[1:]
+			}
+			param.File = true
+			param.Schema = map[string]interface{}{
+				"type":   "string",
+				"format": "binary",
+			}
+			param.Required = applyOpenAPITags(param.Schema, field.Tag)
+			if field.Type == fileSliceType {
+				param.Schema = map[string]interface{}{
+					"type":  "array",
+					"items": param.Schema,
+				}
+				maxFiles, err := strconv.Atoi(field.Tag.Get("maxFiles"))
+				if err == nil && maxFiles > 0 {
+					param.Schema["maxItems"] = maxFiles
+				}
+			}
+			param.Description = openAPIDescription(field.Tag)
+			params = append(params, param)
+			continue
+		}
+
+		// Helper，比如 *Must
+		if isActionHelperType(field.Type) {
+			continue
+		}
+
+		param.Name = validateParamName(field)
+		cookieName, ok := field.Tag.Lookup("cookie")
+		if ok && len(cookieName) > 0 {
+			param.Name = cookieName
+			param.Cookie = true
+		}
+		param.Schema = openAPISchema(field.Type, map[reflect.Type]bool{})
+		param.Required = applyOpenAPITags(param.Schema, field.Tag)
+		param.Description = openAPIDescription(field.Tag)
+		params = append(params, param)
+	}
+	return params, true
+}
+
+// 根据类型生成Schema
+func openAPISchema(valueType reflect.Type, visited map[reflect.Type]bool) map[string]interface{} {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	switch valueType {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "string", "example": "1m30s"}
+	case fileType:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	}
+	if reflect.PtrTo(valueType).Implements(textUnmarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch valueType.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if valueType.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": openAPISchema(valueType.Elem(), visited),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": openAPISchema(valueType.Elem(), visited),
+		}
+	case reflect.Struct:
+		// 避免结构体引用自身时无限递归
+		if visited[valueType] {
+			return map[string]interface{}{"type": "object"}
+		}
+		visited[valueType] = true
+		defer delete(visited, valueType)
+
+		var properties = map[string]interface{}{}
+		var required = []string{}
+		openAPIStructProperties(valueType, visited, properties, &required)
+
+		var schema = map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// 结构体中的字段，匿名结构体中的字段和当前结构体中的字段处于同一级
+func openAPIStructProperties(structType reflect.Type, visited map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		alias, _ := field.Tag.Lookup("alias")
+		if len(alias) == 0 && field.Anonymous {
+			var fieldType = field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				openAPIStructProperties(fieldType, visited, properties, required)
+				continue
+			}
+		}
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		var name = validateParamName(field)
+		var schema = openAPISchema(field.Type, visited)
+		if applyOpenAPITags(schema, field.Tag) {
+			*required = append(*required, name)
+		}
+		var description = openAPIDescription(field.Tag)
+		if len(description) > 0 {
+			schema["description"] = description
+		}
+		properties[name] = schema
+	}
+}
+
+// 根据 default、example、layout 和 validate 标签补充Schema，返回是否为必填
+func applyOpenAPITags(schema map[string]interface{}, tag reflect.StructTag) (required bool) {
+	var schemaType, _ = schema["type"].(string)
+
+	defaultValue, ok := tag.Lookup("default")
+	if ok {
+		schema["default"] = openAPIValue(schemaType, defaultValue)
+	}
+	example, ok := tag.Lookup("example")
+	if ok {
+		schema["example"] = openAPIValue(schemaType, example)
+	}
+	layout, ok := tag.Lookup("layout")
+	if ok && len(layout) > 0 && schema["format"] == "date-time" {
+		delete(schema, "format")
+		schema["x-layout"] = layout
+	}
+
+	for _, rule := range splitValidateRules(tag.Get("validate")) {
+		var name = rule
+		var param = ""
+		index := strings.Index(rule, "=")
+		if index > 0 {
+			name = rule[:index]
+			param = rule[index+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema["format"] = "email"
+		case "match":
+			schema["pattern"] = param
+		case "in":
+			var enum = []interface{}{}
+			for _, piece := range strings.Split(param, "|") {
+				enum = append(enum, openAPIValue(schemaType, piece))
+			}
+			schema["enum"] = enum
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			f, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			var isMin = name == "min" || name == "len" || strings.HasPrefix(name, "gt")
+			var isMax = name == "max" || name == "len" || strings.HasPrefix(name, "lt")
+			switch schemaType {
+			case "integer", "number":
+				if isMin {
+					schema["minimum"] = f
+					if name == "gt" {
+						schema["exclusiveMinimum"] = true
+					}
+				}
+				if isMax {
+					schema["maximum"] = f
+					if name == "lt" {
+						schema["exclusiveMaximum"] = true
+					}
+				}
+			case "string":
+				if isMin {
+					schema["minLength"] = int(f)
+				}
+				if isMax {
+					schema["maxLength"] = int(f)
+				}
+			case "array":
+				if isMin {
+					schema["minItems"] = int(f)
+				}
+				if isMax {
+					schema["maxItems"] = int(f)
+				}
+			}
+		}
+	}
+	return
+}
+
+// 参数描述
+func openAPIDescription(tag reflect.StructTag) string {
+	description, ok := tag.Lookup("doc")
+	if ok {
+		return description
+	}
+	return tag.Get("description")
+}
+
+// 将标签中的字符串转换为和Schema类型一致的值
+func openAPIValue(schemaType string, s string) interface{} {
+	switch schemaType {
+	case "integer":
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return i
+		}
+	case "number":
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil {
+			return f
+		}
+	case "boolean":
+		b, err := strconv.ParseBool(s)
+		if err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// 类型是否有某个方法
+func hasMethod(valueType reflect.Type, name string) bool {
+	_, ok := valueType.MethodByName(name)
+	return ok
+}
@@ -1,6 +1,8 @@
 package actions
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,6 +17,7 @@ type Testing struct {
 	method     string
 	remoteAddr string
 	header     http.Header
+	body       []byte
 	cost       bool
 }
 
@@ -63,6 +66,13 @@ func (this *Testing) SetHeader(key string, value string) *Testing {
 	return this
 }
 
+// 设置请求体，比如JSON数据
+func (this *Testing) Body(contentType string, body []byte) *Testing {
+	this.header.Set("Content-Type", contentType)
+	this.body = body
+	return this
+}
+
 // 计算耗时
 func (this *Testing) Cost() *Testing {
 	this.cost = true
@@ -77,12 +87,16 @@ func (this *Testing) Run(t *testing.T) *TestingResponseWriter {
 		values[k] = v
 	}
 
-	request, err := http.NewRequest(this.method, this.requestURL, strings.NewReader(values.Encode()))
+	var body io.Reader = strings.NewReader(values.Encode())
+	if this.body != nil {
+		body = bytes.NewReader(this.body)
+	}
+	request, err := http.NewRequest(this.method, this.requestURL, body)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if strings.ToUpper(this.method) == "POST" {
+	if strings.ToUpper(this.method) == "POST" && this.body == nil {
 		request.Form = values
 	}
 
@@ -0,0 +1,405 @@
+package actions
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/utils/string"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 流式上传时普通表单字段的最大尺寸
+const uploadMaxValuesSize = 10 << 20
+
+// 用来检测文件类型的数据长度
+const uploadSniffLength = 512
+
+var fileSliceType = reflect.TypeOf([]*File{})
+
+// UploadProgress 上传进度
+type UploadProgress struct {
+	Field    string // 字段名
+	Filename string // 文件名
+	Size     int64  // 当前文件已接收的字节数
+	Read     int64  // 整个请求已读取的字节数
+	Total    int64  // 请求的总字节数，未知时为-1
+	Done     bool   // 当前文件是否已接收完毕
+}
+
+// UploadProgressFunc 上传进度回调函数
+type UploadProgressFunc func(request *http.Request, progress *UploadProgress)
+
+// 单个文件字段的上传限制，从 *File、File 或 []*File 类型参数的标签中读取：
+//   - maxSize:"10m" 单个文件的最大尺寸
+//   - maxFiles:"3" 字段中最多的文件数量
+//   - mime:"image/*,application/pdf" 允许的文件类型，根据文件内容检测，而不是客户端提供的 Content-Type
+type uploadRule struct {
+	param     string
+	maxSize   int64
+	maxFiles  int
+	mimeTypes []string
+}
+
+// 检查文件尺寸
+func (this *uploadRule) checkSize(size int64) string {
+	if this.maxSize > 0 && size > this.maxSize {
+		return this.param + " should be at most " + strconv.FormatInt(this.maxSize, 10) + " bytes"
+	}
+	return ""
+}
+
+// 检查文件数量
+func (this *uploadRule) checkCount(count int) string {
+	if this.maxFiles > 0 && count > this.maxFiles {
+		return this.param + " should contain at most " + strconv.Itoa(this.maxFiles) + " files"
+	}
+	return ""
+}
+
+// 检查文件类型
+func (this *uploadRule) checkMimeType(mimeType string) string {
+	if len(this.mimeTypes) == 0 {
+		return ""
+	}
+	for _, allowedType := range this.mimeTypes {
+		if allowedType == mimeType || allowedType == "*/*" || (strings.HasSuffix(allowedType, "/*") && strings.HasPrefix(mimeType, allowedType[:len(allowedType)-1])) {
+			return ""
+		}
+	}
+	return this.param + " should be one of " + strings.Join(this.mimeTypes, ", ")
+}
+
+// 从Run()参数中读取文件字段的上传限制，字段名 => 限制
+func parseUploadRules(spec *ActionSpec, method string) map[string]*uploadRule {
+	var rules = map[string]*uploadRule{}
+	if len(method) == 0 {
+		return rules
+	}
+	runFuncValue, found := spec.FuncMap["Run"+strings.ToUpper(method[:1])+strings.ToLower(method[1:])]
+	if !found {
+		runFuncValue, found = spec.FuncMap["Run"]
+		if !found {
+			return rules
+		}
+	}
+	var runMethodType = runFuncValue.Type()
+	if runMethodType.NumIn() != 2 || runMethodType.In(1).Kind() != reflect.Struct {
+		return rules
+	}
+
+	var argType = runMethodType.In(1)
+	for i := 0; i < argType.NumField(); i++ {
+		var field = argType.Field(i)
+		if field.Type != fileType && field.Type != reflect.PtrTo(fileType) && field.Type != fileSliceType {
+			continue
+		}
+
+		var names = uploadFieldNames(field)
+		var rule = &uploadRule{
+			param: names[len(names)-1],
+		}
+		maxSize, ok := field.Tag.Lookup("maxSize")
+		if ok {
+			size, err := stringutil.ParseFileSize(maxSize)
+			if err != nil {
+				logs.Errorf("Action.Run(): invalid 'maxSize' tag of field '" + field.Name + "': " + err.Error())
+			} else {
+				rule.maxSize = int64(size)
+			}
+		}
+		maxFiles, ok := field.Tag.Lookup("maxFiles")
+		if ok {
+			count, err := strconv.Atoi(maxFiles)
+			if err != nil {
+				logs.Errorf("Action.Run(): invalid 'maxFiles' tag of field '" + field.Name + "': " + err.Error())
+			} else {
+				rule.maxFiles = count
+			}
+		}
+		mimeTypes, ok := field.Tag.Lookup("mime")
+		if ok {
+			for _, mimeType := range strings.Split(mimeTypes, ",") {
+				mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+				if len(mimeType) > 0 {
+					rule.mimeTypes = append(rule.mimeTypes, mimeType)
+				}
+			}
+		}
+
+		for _, name := range names {
+			rules[name] = rule
+		}
+	}
+	return rules
+}
+
+// 文件字段对应的表单字段名，可以使用 field 标签指定，否则为字段名或者首字母小写的字段名
+func uploadFieldNames(field reflect.StructField) []string {
+	bindName, ok := field.Tag.Lookup("field")
+	if ok {
+		return []string{bindName}
+	}
+	var lowerFirstName = strings.ToLower(field.Name[:1]) + field.Name[1:]
+	if lowerFirstName == field.Name {
+		return []string{field.Name}
+	}
+	return []string{field.Name, lowerFirstName}
+}
+
+// 检查已经接收的文件，用于非流式上传
+func checkUploadFiles(files []*File, rules map[string]*uploadRule) []ActionParamError {
+	var paramErrors = []ActionParamError{}
+	var counts = map[*uploadRule]int{}
+	for _, file := range files {
+		rule, ok := rules[file.Field]
+		if !ok {
+			continue
+		}
+		counts[rule]++
+		for _, message := range []string{rule.checkCount(counts[rule]), rule.checkSize(file.Size), rule.checkMimeType(file.MimeType)} {
+			if len(message) > 0 {
+				paramErrors = append(paramErrors, ActionParamError{
+					Param:    rule.param,
+					Messages: []string{message},
+				})
+				return paramErrors
+			}
+		}
+	}
+	return paramErrors
+}
+
+// 判断是否为 multipart/form-data 请求
+func isMultipartRequest(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// 检测文件类型，不包含 charset 之类的参数
+func sniffMimeType(data []byte) string {
+	var mimeType = http.DetectContentType(data)
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return mimeType
+	}
+	return mediaType
+}
+
+// 检测已接收文件的类型
+func sniffUploadFile(header *multipart.FileHeader) string {
+	reader, err := header.Open()
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	var data = make([]byte, uploadSniffLength)
+	n, _ := io.ReadFull(reader, data)
+	return sniffMimeType(data[:n])
+}
+
+// 计算已读取字节数的请求体
+type uploadCountingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (this *uploadCountingReader) Read(p []byte) (n int, err error) {
+	n, err = this.reader.Read(p)
+	this.read += int64(n)
+	return
+}
+
+// 流式读取 multipart/form-data 请求，文件直接写入到 Tea.TmpDir() 中，普通字段放入参数中
+// 请求的总尺寸受 Upload.MaxSize 限制（没有设置时为32M），Run()参数中没有对应字段的文件会被丢弃
+// 返回的 paramErrors 为违反上传限制的错误，err 为读取请求时发生的错误
+func streamRequestFiles(action *ActionObject, params Params, rules map[string]*uploadRule, progressFunc UploadProgressFunc) (paramErrors []ActionParamError, err error) {
+	var request = action.Request
+	_, mediaParams, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || len(mediaParams["boundary"]) == 0 {
+		return nil, &bodyError{code: http.StatusBadRequest, err: errors.New("invalid multipart boundary")}
+	}
+	// 限制请求的总尺寸，没有设置 Upload.MaxSize 时使用默认的尺寸
+	var maxSize = int64(action.maxSize)
+	if maxSize <= 0 {
+		maxSize = defaultBodyMaxSize
+	}
+	if request.ContentLength > maxSize {
+		return nil, &bodyError{code: http.StatusRequestEntityTooLarge, err: errors.New("request body too large")}
+	}
+	var counter = &uploadCountingReader{reader: http.MaxBytesReader(action.ResponseWriter, request.Body, maxSize)}
+	var reader = multipart.NewReader(counter, mediaParams["boundary"])
+
+	var tmpDir = Tea.TmpDir()
+	err = os.MkdirAll(tmpDir, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts = map[*uploadRule]int{}
+	var valuesSize int64 = 0
+	var buf = make([]byte, 32<<10)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, uploadReadError(err)
+		}
+
+		var field = part.FormName()
+		if len(field) == 0 {
+			_ = part.Close()
+			continue
+		}
+
+		// 普通字段
+		var filename = part.FileName()
+		if len(filename) == 0 {
+			data, err := io.ReadAll(io.LimitReader(part, uploadMaxValuesSize-valuesSize+1))
+			_ = part.Close()
+			if err != nil {
+				return nil, uploadReadError(err)
+			}
+			valuesSize += int64(len(data))
+			if valuesSize > uploadMaxValuesSize {
+				return nil, &bodyError{code: http.StatusRequestEntityTooLarge, err: errors.New("multipart values too large")}
+			}
+			params[field] = append(params[field], string(data))
+			continue
+		}
+
+		// 文件，Run()参数中没有对应字段的文件直接丢弃
+		var rule = rules[field]
+		if rule == nil {
+			_, err = io.Copy(io.Discard, part)
+			_ = part.Close()
+			if err != nil {
+				return nil, uploadReadError(err)
+			}
+			continue
+		}
+		counts[rule]++
+		message := rule.checkCount(counts[rule])
+		if len(message) > 0 {
+			_ = part.Close()
+			return []ActionParamError{{Param: rule.param, Messages: []string{message}}}, nil
+		}
+
+		fp, err := os.CreateTemp(tmpDir, "upload-*")
+		if err != nil {
+			_ = part.Close()
+			return nil, err
+		}
+		var file = &File{
+			Filename:    filename,
+			Field:       field,
+			Ext:         strings.ToLower(filepath.Ext(filename)),
+			ContentType: part.Header.Get("Content-Type"),
+			Path:        fp.Name(),
+			isTmp:       true,
+		}
+		action.Files = append(action.Files, file)
+
+		var progress = &UploadProgress{
+			Field:    field,
+			Filename: filename,
+			Total:    request.ContentLength,
+		}
+		for {
+			n, readErr := io.ReadFull(part, buf)
+			if n > 0 {
+				// 根据文件开头的内容检测文件类型
+				if file.Size == 0 {
+					var sniffLength = n
+					if sniffLength > uploadSniffLength {
+						sniffLength = uploadSniffLength
+					}
+					file.MimeType = sniffMimeType(buf[:sniffLength])
+					message = rule.checkMimeType(file.MimeType)
+					if len(message) > 0 {
+						break
+					}
+				}
+
+				file.Size += int64(n)
+				message = rule.checkSize(file.Size)
+				if len(message) > 0 {
+					break
+				}
+
+				_, err = fp.Write(buf[:n])
+				if err != nil {
+					break
+				}
+
+				if progressFunc != nil {
+					progress.Size = file.Size
+					progress.Read = counter.read
+					progressFunc(request, progress)
+				}
+			}
+			if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+				break
+			}
+			if readErr != nil {
+				err = uploadReadError(readErr)
+				break
+			}
+		}
+		_ = part.Close()
+		closeErr := fp.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(message) > 0 {
+			return []ActionParamError{{Param: rule.param, Messages: []string{message}}}, nil
+		}
+		if file.Size == 0 {
+			file.MimeType = sniffMimeType(nil)
+			message = rule.checkMimeType(file.MimeType)
+			if len(message) > 0 {
+				return []ActionParamError{{Param: rule.param, Messages: []string{message}}}, nil
+			}
+		}
+
+		if progressFunc != nil {
+			progress.Size = file.Size
+			progress.Read = counter.read
+			progress.Done = true
+			progressFunc(request, progress)
+		}
+	}
+}
+
+// 读取请求体时发生的错误，超出尺寸时返回413
+func uploadReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &bodyError{code: http.StatusRequestEntityTooLarge, err: errors.New("request body too large")}
+	}
+	return &bodyError{code: http.StatusBadRequest, err: errors.New("read multipart body failed: " + err.Error())}
+}
+
+// 删除流式上传时生成的临时文件
+func removeUploadTmpFiles(files []*File) {
+	for _, file := range files {
+		if file.isTmp {
+			_ = os.Remove(file.Path)
+			file.isTmp = false
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package actions
+
+import (
+	"bytes"
+	"github.com/iwind/TeaGo/Tea"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var testPNGData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+type testUploadAction Action
+
+func (this *testUploadAction) RunPost(params struct {
+	Name   string
+	Avatar *File   `maxSize:"1k" mime:"image/png,image/gif"`
+	Docs   []*File `maxFiles:"2"`
+}) {
+	if params.Avatar == nil || len(params.Docs) != 2 {
+		this.Fail("files should be bound")
+	}
+
+	_, err := os.Stat(params.Avatar.Path)
+	if err != nil {
+		this.Fail("file should be written to disk")
+	}
+	data, err := params.Docs[1].Read()
+	if err != nil {
+		this.Fail(err.Error())
+	}
+
+	this.Data["name"] = params.Name
+	this.Data["mimeType"] = params.Avatar.MimeType
+	this.Data["doc"] = string(data)
+	this.Data["path"] = params.Avatar.Path
+	this.Success()
+}
+
+type testUploadPart struct {
+	field    string
+	filename string
+	data     []byte
+}
+
+func testUploadRequest(parts []testUploadPart) *http.Request {
+	var body = &bytes.Buffer{}
+	var writer = multipart.NewWriter(body)
+	for _, part := range parts {
+		if len(part.filename) == 0 {
+			_ = writer.WriteField(part.field, string(part.data))
+			continue
+		}
+		partWriter, _ := writer.CreateFormFile(part.field, part.filename)
+		_, _ = partWriter.Write(part.data)
+	}
+	_ = writer.Close()
+
+	var request = httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestStreamRequestFiles(t *testing.T) {
+	Tea.SetTmpDir(t.TempDir())
+	defer Tea.SetTmpDir("")
+
+	var progresses = []UploadProgress{}
+	var spec = NewActionSpec(new(testUploadAction))
+	spec.StreamUpload = true
+	spec.UploadProgress = func(request *http.Request, progress *UploadProgress) {
+		progresses = append(progresses, *progress)
+	}
+
+	var recorder = httptest.NewRecorder()
+	RunAction(new(testUploadAction), spec, testUploadRequest([]testUploadPart{
+		{field: "name", data: []byte("Lu")},
+		{field: "avatar", filename: "avatar.txt", data: testPNGData},
+		{field: "docs", filename: "a.txt", data: []byte("a")},
+		{field: "docs", filename: "b.txt", data: []byte("b")},
+	}), recorder, nil, nil, nil)
+	t.Log(recorder.Body.String())
+
+	var body = recorder.Body.String()
+	if !strings.Contains(body, `"name":"Lu"`) || !strings.Contains(body, `"mimeType":"image/png"`) || !strings.Contains(body, `"doc":"b"`) {
+		t.Fatal("files should be streamed")
+	}
+	if len(progresses) != 6 || !progresses[1].Done || progresses[1].Size != int64(len(testPNGData)) || progresses[1].Field != "avatar" {
+		t.Fatal("unexpected progresses:", progresses)
+	}
+
+	// 临时文件已经被删除
+	var index = strings.Index(body, `"path":"`)
+	var path = body[index+8:]
+	path = path[:strings.Index(path, `"`)]
+	_, err := os.Stat(path)
+	if !os.IsNotExist(err) {
+		t.Fatal("tmp file should be removed after action")
+	}
+}
+
+func TestStreamRequestFiles_Limits(t *testing.T) {
+	Tea.SetTmpDir(t.TempDir())
+	defer Tea.SetTmpDir("")
+
+	var spec = NewActionSpec(new(testUploadAction))
+	spec.StreamUpload = true
+
+	for _, testCase := range []struct {
+		parts   []testUploadPart
+		message string
+	}{
+		{
+			parts:   []testUploadPart{{field: "avatar", filename: "avatar.png", data: []byte("plain text")}},
+			message: "avatar should be one of image/png, image/gif",
+		},
+		{
+			parts:   []testUploadPart{{field: "avatar", filename: "avatar.png", data: append(testPNGData, make([]byte, 1024)...)}},
+			message: "avatar should be at most 1024 bytes",
+		},
+		{
+			parts: []testUploadPart{
+				{field: "docs", filename: "a.txt", data: []byte("a")},
+				{field: "docs", filename: "b.txt", data: []byte("b")},
+				{field: "docs", filename: "c.txt", data: []byte("c")},
+			},
+			message: "docs should contain at most 2 files",
+		},
+	} {
+		var recorder = httptest.NewRecorder()
+		RunAction(new(testUploadAction), spec, testUploadRequest(testCase.parts), recorder, nil, nil, nil)
+		t.Log(recorder.Body.String())
+		if !strings.Contains(recorder.Body.String(), `"message":"`+testCase.message+`"`) {
+			t.Fatal("expected '" + testCase.message + "'")
+		}
+	}
+
+	// 所有的临时文件都已经被删除
+	entries, err := os.ReadDir(Tea.TmpDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Fatal("tmp files should be removed")
+	}
+}
+
+func TestStreamRequestFiles_MaxSize(t *testing.T) {
+	Tea.SetTmpDir(t.TempDir())
+	defer Tea.SetTmpDir("")
+
+	var spec = NewActionSpec(new(testUploadAction))
+	spec.StreamUpload = true
+
+	// 没有对应参数的文件被丢弃
+	var recorder = httptest.NewRecorder()
+	var action = new(testUploadAction)
+	action.SetMaxSize(4096)
+	RunAction(action, spec, testUploadRequest([]testUploadPart{
+		{field: "other", filename: "other.txt", data: bytes.Repeat([]byte("a"), 1024)},
+	}), recorder, nil, nil, nil)
+	entries, _ := os.ReadDir(Tea.TmpDir())
+	if len(entries) > 0 {
+		t.Fatal("undeclared files should be discarded")
+	}
+
+	// 请求的总尺寸，包括没有 Content-Length 的请求
+	for _, knownLength := range []bool{true, false} {
+		recorder = httptest.NewRecorder()
+		var request = testUploadRequest([]testUploadPart{
+			{field: "other", filename: "other.txt", data: bytes.Repeat([]byte("a"), 8192)},
+		})
+		if !knownLength {
+			request.ContentLength = -1
+		}
+		action = new(testUploadAction)
+		action.SetMaxSize(4096)
+		RunAction(action, spec, request, recorder, nil, nil, nil)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Fatal("expected 413, but got", recorder.Code)
+		}
+	}
+}
+
+func TestFile_MoveTo(t *testing.T) {
+	var dir = t.TempDir()
+	fp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fp.WriteString("hello")
+	_ = fp.Close()
+
+	var file = &File{Path: fp.Name(), Size: 5, isTmp: true}
+	err = file.MoveTo(dir + "/files/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeUploadTmpFiles([]*File{file})
+
+	data, err := file.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatal("moved file should be kept")
+	}
+}
@@ -0,0 +1,586 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ValidateRuleFunc 自定义的校验规则
+// value 为字段的值，param 为规则参数，parent 为字段所在的结构体，可以用来校验多个字段之间的关系
+type ValidateRuleFunc func(value interface{}, param string, parent interface{}) bool
+
+type validateRule struct {
+	message  string
+	ruleFunc ValidateRuleFunc
+}
+
+// 默认的错误提示，{field} 会被替换为参数名（或者 label 标签），{param} 会被替换为规则参数
+var validateMessages = map[string]string{
+	"required":         "{field} is required",
+	"required_with":    "{field} is required when {param} is present",
+	"required_without": "{field} is required when {param} is absent",
+	"min":              "{field} length should be at least {param}",
+	"max":              "{field} length should be at most {param}",
+	"len":              "{field} length should be {param}",
+	"gt":               "{field} should be greater than {param}",
+	"gte":              "{field} should be greater than or equal to {param}",
+	"lt":               "{field} should be less than {param}",
+	"lte":              "{field} should be less than or equal to {param}",
+	"email":            "{field} should be a valid email address",
+	"mobile":           "{field} should be a valid mobile number",
+	"in":               "{field} should be one of {param}",
+	"match":            "{field} format is invalid",
+	"equal":            "{field} should be {param}",
+	"eqfield":          "{field} should be equal to {param}",
+	"nefield":          "{field} should not be equal to {param}",
+	"gtfield":          "{field} should be greater than {param}",
+	"gtefield":         "{field} should be greater than or equal to {param}",
+	"ltfield":          "{field} should be less than {param}",
+	"ltefield":         "{field} should be less than or equal to {param}",
+}
+
+var validateRules = map[string]*validateRule{}
+var validateLocker = sync.RWMutex{}
+
+// RegisterValidateRule 注册自定义的校验规则，注册后可以在 validate 标签中使用，比如 validate:"required,username"
+func RegisterValidateRule(name string, message string, ruleFunc ValidateRuleFunc) {
+	validateLocker.Lock()
+	defer validateLocker.Unlock()
+
+	validateRules[name] = &validateRule{
+		message:  message,
+		ruleFunc: ruleFunc,
+	}
+}
+
+// SetValidateMessage 修改某个内置规则的默认错误提示，比如 SetValidateMessage("required", "请输入{field}")
+func SetValidateMessage(rule string, message string) {
+	validateLocker.Lock()
+	defer validateLocker.Unlock()
+
+	validateMessages[rule] = message
+}
+
+// 解析后的结构体校验规则
+type validateStruct struct {
+	fields []*validateFieldSpec
+}
+
+// 解析后的字段校验规则
+type validateFieldSpec struct {
+	index   int
+	name    string // 参数名
+	label   string // label 标签
+	message string // message 标签
+	kind    reflect.Kind
+	isPtr   bool
+
+	rules  []*validateFieldRule
+	embed  *validateStruct // 匿名结构体
+	nested *validateStruct // 嵌套的结构体，或者列表、字典中的结构体
+}
+
+// 解析后的单个规则
+type validateFieldRule struct {
+	name       string
+	param      string
+	length     int            // 字符串、列表和字典的 min、max、len
+	number     float64        // 数字的比较
+	values     []string       // in
+	reg        *regexp.Regexp // match
+	other      []int          // 关联的字段
+	otherLabel string
+}
+
+var validateStructCache = sync.Map{} // reflect.Type => *validateStruct
+
+// ValidateStruct 使用字段的 validate 标签校验结构体，返回所有的错误
+//
+// 支持的规则：
+//   - required、required_with=Field、required_without=Field
+//   - min=N、max=N、len=N：字符串为字符数，列表和字典为元素个数，数字为值的大小
+//   - gt=N、gte=N、lt=N、lte=N
+//   - email、mobile、in=a|b|c、equal=value、match=正则表达式（必须为最后一个规则）
+//   - eqfield=Field、nefield=Field、gtfield=Field、gtefield=Field、ltfield=Field、ltefield=Field
+//   - 通过 RegisterValidateRule() 注册的规则
+//
+// 除了 required* 之外的规则在值为空（数字除外）时不检查；可以使用 message 标签设置字段统一的错误提示，使用 label 标签设置提示中的字段名
+//
+// 每个结构体的标签只解析一次，标签中的错误（未知的规则、错误的参数等）会打印到日志中，对应的规则被忽略
+func ValidateStruct(value interface{}) []ActionParamError {
+	var reflectValue = reflect.Indirect(reflect.ValueOf(value))
+	if reflectValue.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var structType = reflectValue.Type()
+	cached, ok := validateStructCache.Load(structType)
+	if !ok {
+		validator, errs := parseValidateStruct(structType, structType.String())
+		for _, err := range errs {
+			logs.Error(err)
+		}
+		cached, _ = validateStructCache.LoadOrStore(structType, validator)
+	}
+	return runValidateStruct(cached.(*validateStruct), reflectValue)
+}
+
+// 使用解析后的规则校验结构体
+func runValidateStruct(validator *validateStruct, structValue reflect.Value) []ActionParamError {
+	var errors = []ActionParamError{}
+	validateStructValue(validator, structValue, "", &errors)
+	return errors
+}
+
+// 解析结构体中的 validate 标签，prefix 用来在错误中表示结构体
+func parseValidateStruct(structType reflect.Type, prefix string) (*validateStruct, []error) {
+	var errs = []error{}
+	var validator = parseValidateStructType(structType, prefix, map[reflect.Type]*validateStruct{}, &errs)
+	return validator, errs
+}
+
+// 解析结构体的所有字段，parsed 用来处理相互引用的结构体
+func parseValidateStructType(structType reflect.Type, prefix string, parsed map[reflect.Type]*validateStruct, errs *[]error) *validateStruct {
+	validator, ok := parsed[structType]
+	if ok {
+		return validator
+	}
+	validator = &validateStruct{}
+	parsed[structType] = validator
+
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		if len(field.PkgPath) > 0 || isActionHelperType(field.Type) || field.Type == fileType || field.Type == reflect.PtrTo(fileType) {
+			continue
+		}
+
+		var fieldType = field.Type
+		var fieldSpec = &validateFieldSpec{
+			index: i,
+			name:  validateParamName(field),
+			isPtr: fieldType.Kind() == reflect.Ptr,
+		}
+		if fieldSpec.isPtr {
+			fieldType = fieldType.Elem()
+		}
+		fieldSpec.kind = fieldType.Kind()
+
+		// 匿名结构体中的字段和当前结构体中的字段处于同一级
+		if field.Anonymous && len(field.Tag.Get("alias")) == 0 {
+			if fieldType.Kind() == reflect.Struct {
+				fieldSpec.embed = parseValidateStructType(fieldType, prefix, parsed, errs)
+				validator.fields = append(validator.fields, fieldSpec)
+			}
+			continue
+		}
+
+		fieldSpec.label = field.Tag.Get("label")
+		fieldSpec.message = field.Tag.Get("message")
+
+		var tag = field.Tag.Get("validate")
+		if len(tag) > 0 && tag != "-" {
+			for _, rule := range splitValidateRules(tag) {
+				fieldRule, err := parseValidateRule(structType, fieldSpec, rule)
+				if err != nil {
+					*errs = append(*errs, errors.New("validate: field '"+prefix+"."+field.Name+"': "+err.Error()))
+					continue
+				}
+				fieldSpec.rules = append(fieldSpec.rules, fieldRule)
+			}
+		}
+
+		// 嵌套的结构体
+		var nestedType = validateNestedType(fieldType)
+		if nestedType != nil {
+			fieldSpec.nested = parseValidateStructType(nestedType, prefix+"."+field.Name, parsed, errs)
+		}
+
+		validator.fields = append(validator.fields, fieldSpec)
+	}
+	return validator
+}
+
+// 解析单个规则，并检查规则的参数
+func parseValidateRule(structType reflect.Type, fieldSpec *validateFieldSpec, rule string) (*validateFieldRule, error) {
+	var fieldRule = &validateFieldRule{
+		name: rule,
+	}
+	index := strings.Index(rule, "=")
+	if index > -1 {
+		fieldRule.name = rule[:index]
+		fieldRule.param = rule[index+1:]
+	}
+
+	var err error
+	switch fieldRule.name {
+	case "required", "email", "mobile", "equal":
+	case "required_with", "required_without":
+		otherField, ok := findValidateField(structType, fieldRule.param)
+		if !ok {
+			return nil, errors.New("invalid validate rule '" + rule + "': field not found")
+		}
+		fieldRule.other = otherField.Index
+	case "min", "max", "len":
+		if isNumberKind(fieldSpec.kind) {
+			fieldRule.number, err = strconv.ParseFloat(fieldRule.param, 64)
+		} else {
+			fieldRule.length, err = strconv.Atoi(fieldRule.param)
+		}
+	case "gt", "gte", "lt", "lte":
+		fieldRule.number, err = strconv.ParseFloat(fieldRule.param, 64)
+	case "in":
+		fieldRule.values = strings.Split(fieldRule.param, "|")
+	case "match":
+		fieldRule.reg, err = regexp.Compile(fieldRule.param)
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield":
+		otherField, ok := findValidateField(structType, fieldRule.param)
+		if !ok {
+			return nil, errors.New("invalid validate rule '" + rule + "': field not found")
+		}
+
+		// 需要读取字段的值，所以字段和字段所在的匿名结构体都必须是导出的
+		for i := range otherField.Index {
+			if len(structType.FieldByIndex(otherField.Index[:i+1]).PkgPath) > 0 {
+				return nil, errors.New("invalid validate rule '" + rule + "': field '" + otherField.Name + "' is not exported")
+			}
+		}
+		fieldRule.other = otherField.Index
+		fieldRule.otherLabel = otherField.Tag.Get("label")
+		if len(fieldRule.otherLabel) == 0 {
+			fieldRule.otherLabel = validateParamName(otherField)
+		}
+	default:
+		validateLocker.RLock()
+		_, ok := validateRules[fieldRule.name]
+		validateLocker.RUnlock()
+		if !ok {
+			return nil, errors.New("unknown validate rule '" + fieldRule.name + "'")
+		}
+	}
+	if err != nil {
+		return nil, errors.New("invalid validate rule '" + rule + "': " + err.Error())
+	}
+	return fieldRule, nil
+}
+
+// 需要继续校验的嵌套结构体，包括列表和字典中的结构体
+func validateNestedType(valueType reflect.Type) reflect.Type {
+	for {
+		switch valueType.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			valueType = valueType.Elem()
+		case reflect.Struct:
+			if isParamScalarType(valueType) || valueType == fileType {
+				return nil
+			}
+			return valueType
+		default:
+			return nil
+		}
+	}
+}
+
+// 校验结构体中的所有字段
+func validateStructValue(validator *validateStruct, structValue reflect.Value, prefix string, errors *[]ActionParamError) {
+	for _, fieldSpec := range validator.fields {
+		var fieldValue = structValue.Field(fieldSpec.index)
+		if fieldSpec.embed != nil {
+			var embedValue = reflect.Indirect(fieldValue)
+			if embedValue.IsValid() {
+				validateStructValue(fieldSpec.embed, embedValue, prefix, errors)
+			}
+			continue
+		}
+
+		var path = fieldSpec.name
+		if len(prefix) > 0 {
+			path = prefix + "." + path
+		}
+
+		if len(fieldSpec.rules) > 0 {
+			paramErrors := validateField(structValue, fieldSpec, fieldValue, path)
+			if len(paramErrors) > 0 {
+				*errors = append(*errors, paramErrors...)
+				continue
+			}
+		}
+
+		// 嵌套的结构体
+		if fieldSpec.nested != nil {
+			validateNestedValue(fieldSpec.nested, fieldValue, path, errors)
+		}
+	}
+}
+
+// 校验嵌套的结构体和结构体列表
+func validateNestedValue(validator *validateStruct, value reflect.Value, path string, errors *[]ActionParamError) {
+	value = reflect.Indirect(value)
+	if !value.IsValid() {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		validateStructValue(validator, value, path, errors)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateNestedValue(validator, value.Index(i), path+"."+strconv.Itoa(i), errors)
+		}
+	case reflect.Map:
+		var iterator = value.MapRange()
+		for iterator.Next() {
+			validateNestedValue(validator, iterator.Value(), path+"."+fmt.Sprint(iterator.Key().Interface()), errors)
+		}
+	}
+}
+
+// 校验单个字段，使用 Must 检查，遇到第一个错误时停止
+func validateField(parent reflect.Value, fieldSpec *validateFieldSpec, fieldValue reflect.Value, path string) (paramErrors []ActionParamError) {
+	var must = &Must{}
+	if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+		must.Field(path, fieldValue.Elem().Interface())
+	} else {
+		must.Field(path, fieldValue.Interface())
+	}
+
+	var label = fieldSpec.label
+	if len(label) == 0 {
+		label = path
+	}
+	var message = func(rule string, param string) string {
+		if len(fieldSpec.message) > 0 {
+			return fieldSpec.message
+		}
+		validateLocker.RLock()
+		var format = validateMessages[rule]
+		validateLocker.RUnlock()
+		if len(format) == 0 {
+			format = "{field} is invalid"
+		}
+		return strings.NewReplacer("{field}", label, "{param}", param).Replace(format)
+	}
+
+	// Must在出错时会使用panic返回错误
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		mustErrors, ok := r.([]ActionParamError)
+		if !ok {
+			panic(r)
+		}
+		paramErrors = mustErrors
+	}()
+
+	var isEmpty = isEmptyValidateValue(fieldValue)
+	var kind = fieldSpec.kind
+	var isNumber = isNumberKind(kind)
+
+	for _, rule := range fieldSpec.rules {
+		var name = rule.name
+		var param = rule.param
+
+		// 非必填的规则在值为空时不检查，数字为0时仍然检查
+		var isAbsent = isEmpty && (!isNumber || fieldSpec.isPtr)
+		if isAbsent && name != "required" && name != "required_with" && name != "required_without" {
+			continue
+		}
+
+		switch name {
+		case "required":
+			if kind == reflect.String || fieldSpec.isPtr {
+				must.Require(message(name, param))
+			} else {
+				must.Expect(func() (string, bool) {
+					return message(name, param), !isEmpty
+				})
+			}
+		case "required_with", "required_without":
+			var otherPresent = !isEmptyValidateValue(parent.FieldByIndex(rule.other))
+			if isEmpty && (otherPresent == (name == "required_with")) {
+				must.Expect(func() (string, bool) {
+					return message(name, param), false
+				})
+			}
+		case "min", "max", "len":
+			if isNumber {
+				var numberRule = map[string]string{"min": "gte", "max": "lte", "len": "equal"}[name]
+				validateNumber(must, numberRule, rule.number, message(numberRule, param))
+				continue
+			}
+			switch {
+			case kind == reflect.String && name == "min":
+				must.MinCharacters(rule.length, message(name, param))
+			case kind == reflect.String && name == "max":
+				must.MaxCharacters(rule.length, message(name, param))
+			default:
+				must.Expect(func() (string, bool) {
+					var valueLength = validateValueLength(fieldValue)
+					switch name {
+					case "min":
+						return message(name, param), valueLength >= rule.length
+					case "max":
+						return message(name, param), valueLength <= rule.length
+					}
+					return message(name, param), valueLength == rule.length
+				})
+			}
+		case "gt", "gte", "lt", "lte":
+			validateNumber(must, name, rule.number, message(name, param))
+		case "email":
+			must.Email(message(name, param))
+		case "mobile":
+			must.Mobile(message(name, param))
+		case "in":
+			must.Expect(func() (string, bool) {
+				return message(name, strings.Join(rule.values, ", ")), lists.ContainsString(rule.values, must.valueString)
+			})
+		case "equal":
+			must.Equal(param, message(name, param))
+		case "match":
+			must.Expect(func() (string, bool) {
+				return message(name, param), rule.reg.MatchString(must.valueString)
+			})
+		case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield":
+			var otherMust = (&Must{}).Value(parent.FieldByIndex(rule.other).Interface())
+			must.Expect(func() (string, bool) {
+				var ok bool
+				switch name {
+				case "eqfield":
+					ok = must.valueString == otherMust.valueString
+				case "nefield":
+					ok = must.valueString != otherMust.valueString
+				case "gtfield":
+					ok = must.valueFloat > otherMust.valueFloat
+				case "gtefield":
+					ok = must.valueFloat >= otherMust.valueFloat
+				case "ltfield":
+					ok = must.valueFloat < otherMust.valueFloat
+				case "ltefield":
+					ok = must.valueFloat <= otherMust.valueFloat
+				}
+				return message(name, rule.otherLabel), ok
+			})
+		default:
+			validateLocker.RLock()
+			customRule, ok := validateRules[name]
+			validateLocker.RUnlock()
+			if !ok {
+				continue
+			}
+			must.Expect(func() (string, bool) {
+				var ruleMessage = fieldSpec.message
+				if len(ruleMessage) == 0 {
+					ruleMessage = strings.NewReplacer("{field}", label, "{param}", param).Replace(customRule.message)
+				}
+				return ruleMessage, customRule.ruleFunc(fieldValue.Interface(), param, parent.Interface())
+			})
+		}
+	}
+	return nil
+}
+
+// 比较数字
+func validateNumber(must *Must, rule string, number float64, message string) {
+	must.Expect(func() (string, bool) {
+		switch rule {
+		case "gt":
+			return message, must.valueFloat > number
+		case "gte":
+			return message, must.valueFloat >= number
+		case "lt":
+			return message, must.valueFloat < number
+		case "lte":
+			return message, must.valueFloat <= number
+		}
+		return message, must.valueFloat == number
+	})
+}
+
+// 拆分规则，match 规则中的正则表达式可能包含逗号，所以会使用其后所有的内容
+func splitValidateRules(tag string) []string {
+	var rules = []string{}
+	for len(tag) > 0 {
+		if strings.HasPrefix(tag, "match=") {
+			rules = append(rules, tag)
+			break
+		}
+		index := strings.Index(tag, ",")
+		if index < 0 {
+			rules = append(rules, strings.TrimSpace(tag))
+			break
+		}
+		var rule = strings.TrimSpace(tag[:index])
+		if len(rule) > 0 {
+			rules = append(rules, rule)
+		}
+		tag = strings.TrimLeft(tag[index+1:], " ")
+	}
+	return rules
+}
+
+// 查找同一个结构体中的字段，可以使用字段名或者参数名
+func findValidateField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	field, ok := structType.FieldByName(name)
+	if ok {
+		return field, true
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		if validateParamName(structType.Field(i)) == name {
+			return structType.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// 字段对应的参数名
+func validateParamName(field reflect.StructField) string {
+	alias, ok := field.Tag.Lookup("alias")
+	if ok && len(alias) > 0 {
+		return alias
+	}
+	return strings.ToLower(field.Name[:1]) + field.Name[1:]
+}
+
+// 判断值是否为空
+func isEmptyValidateValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.String:
+		return len(strings.TrimSpace(value.String())) == 0
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// 字符串的字符数，或者列表、字典的元素数
+func validateValueLength(value reflect.Value) int {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.String:
+		return len([]rune(value.String()))
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Chan:
+		return value.Len()
+	}
+	return len(types.String(value.Interface()))
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
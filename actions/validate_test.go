@@ -0,0 +1,170 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testValidateAction Action
+
+func (this *testValidateAction) RunPost(params struct {
+	Name     string `validate:"required,min=3,max=8"`
+	Email    string `validate:"email"`
+	Role     string `validate:"in=admin|user" default:"user"`
+	Code     string `validate:"match=^\\d{3,4}$"`
+	Age      int    `validate:"gte=18,lt=150"`
+	Password string `validate:"required"`
+	Confirm  string `validate:"eqfield=Password" label:"password confirmation"`
+	Username string `validate:"username"`
+	Items    []struct {
+		Id int `validate:"gt=0"`
+	}
+	Must *Must
+}) {
+	this.Success()
+}
+
+func init() {
+	RegisterValidateRule("username", "{field} should only contain letters", func(value interface{}, param string, parent interface{}) bool {
+		return strings.Trim(value.(string), "abcdefghijklmnopqrstuvwxyz") == ""
+	})
+}
+
+func testValidateRun(t *testing.T, params Params) (code int, errors map[string][]string) {
+	resp := NewTesting(new(testValidateAction)).
+		Method("POST").
+		URL("/validate").
+		Params(params).
+		Run(t)
+	t.Log(string(resp.Data))
+
+	var result = struct {
+		Code   int                `json:"code"`
+		Errors []ActionParamError `json:"errors"`
+	}{}
+	err := json.Unmarshal(resp.Data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errors = map[string][]string{}
+	for _, paramError := range result.Errors {
+		errors[paramError.Param] = paramError.Messages
+	}
+	return result.Code, errors
+}
+
+func TestValidate_Success(t *testing.T) {
+	code, _ := testValidateRun(t, Params{
+		"name":     {"Lu"},
+		"email":    {""},
+		"code":     {"1234"},
+		"age":      {"20"},
+		"password": {"123456"},
+		"confirm":  {"123456"},
+		"username": {"lu"},
+		"name2":    {""},
+	})
+	if code != 400 {
+		t.Fatal("name should be too short")
+	}
+
+	code, _ = testValidateRun(t, Params{
+		"name":        {"Lucy"},
+		"age":         {"20"},
+		"password":    {"123456"},
+		"confirm":     {"123456"},
+		"items[0].id": {"1"},
+	})
+	if code != 200 {
+		t.Fatal("validation should pass")
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	code, errors := testValidateRun(t, Params{
+		"name":         {"Lucy Lucy"},
+		"email":        {"lu@"},
+		"role":         {"root"},
+		"code":         {"12"},
+		"age":          {"10"},
+		"confirm":      {"123"},
+		"username":     {"lu123"},
+		"items[0][id]": {"0"},
+	})
+	if code != 400 {
+		t.Fatal("validation should fail")
+	}
+	var expected = map[string]string{
+		"name":       "name length should be at most 8",
+		"email":      "email should be a valid email address",
+		"role":       "role should be one of admin, user",
+		"code":       "code format is invalid",
+		"age":        "age should be greater than or equal to 18",
+		"password":   "password is required",
+		"confirm":    "password confirmation should be equal to password",
+		"username":   "username should only contain letters",
+		"items.0.id": "items.0.id should be greater than 0",
+	}
+	if len(errors) != len(expected) {
+		t.Fatal("expected", len(expected), "errors, got", len(errors))
+	}
+	for param, message := range expected {
+		if len(errors[param]) != 1 || errors[param][0] != message {
+			t.Fatal("invalid message for '"+param+"':", errors[param])
+		}
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	SetValidateMessage("required", "请输入{field}")
+	defer SetValidateMessage("required", "{field} is required")
+
+	var errors = ValidateStruct(&struct {
+		Title string   `validate:"required" label:"标题"`
+		Price *float64 `validate:"required_with=Title"`
+		Tags  []string `validate:"max=2"`
+	}{
+		Tags: []string{"a", "b", "c"},
+	})
+	t.Log(errors)
+	if len(errors) != 2 || errors[0].Messages[0] != "请输入标题" || errors[1].Param != "tags" {
+		t.Fatal("invalid errors")
+	}
+}
+
+type testValidateTagAction Action
+
+func (this *testValidateTagAction) RunPost(params struct {
+	Name     string `validate:"required,nickname"`
+	Age      int    `validate:"min=abc"`
+	Code     string `validate:"match=(\\d+"`
+	Confirm  string `validate:"eqfield=password"`
+	password string
+}) {
+	this.Success()
+}
+
+func TestValidate_TagErrors(t *testing.T) {
+	var spec = NewActionSpec(new(testValidateTagAction))
+	var errs = spec.ValidateErrors()
+	t.Log(errs)
+	if len(errs) != 4 {
+		t.Fatal("expected 4 tag errors, but got", len(errs))
+	}
+	for index, message := range []string{"unknown validate rule 'nickname'", "'min=abc'", "'match=(\\d+'", "field 'password' is not exported"} {
+		if !strings.Contains(errs[index].Error(), message) {
+			t.Fatal("expected '"+message+"', but got:", errs[index])
+		}
+	}
+
+	// 错误的规则被忽略，不会影响请求
+	var recorder = httptest.NewRecorder()
+	RunAction(new(testValidateTagAction), spec, httptest.NewRequest(http.MethodPost, "/", nil), recorder, Params{"name": {"Lu"}, "confirm": {"123"}}, nil, nil)
+	t.Log(recorder.Body.String())
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"code":200`) {
+		t.Fatal("invalid rules should not fail the request")
+	}
+}
@@ -0,0 +1,577 @@
+package actions
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket 消息类型
+const (
+	WebSocketContinuationFrame = 0
+	WebSocketTextMessage       = 1
+	WebSocketBinaryMessage     = 2
+	WebSocketCloseMessage      = 8
+	WebSocketPingMessage       = 9
+	WebSocketPongMessage       = 10
+)
+
+// WebSocket 关闭代码
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseUnsupportedData = 1003
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseAbnormal        = 1006
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket 默认选项
+var (
+	WebSocketDefaultMaxMessageSize int64 = 1 << 20
+	WebSocketDefaultPingInterval         = 30 * time.Second
+	WebSocketDefaultPongTimeout          = 10 * time.Second
+	WebSocketDefaultWriteTimeout         = 10 * time.Second
+)
+
+// ErrWebSocketClosed 连接已关闭
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// WebSocketOptions WebSocket选项
+type WebSocketOptions struct {
+	MaxMessageSize int64         // 接收的消息最大尺寸（字节），超过后以1009关闭连接，默认为 WebSocketDefaultMaxMessageSize
+	PingInterval   time.Duration // 发送ping的间隔，小于0表示不发送，默认为 WebSocketDefaultPingInterval
+	PongTimeout    time.Duration // 发送ping后等待响应的时间，超时后读取消息会返回错误，默认为 WebSocketDefaultPongTimeout
+	WriteTimeout   time.Duration // 写入单个消息的超时时间，默认为 WebSocketDefaultWriteTimeout
+
+	Subprotocols []string                         // 支持的子协议，按照客户端的顺序选择第一个支持的
+	CheckOrigin  func(request *http.Request) bool // 检查Origin，为nil时要求Origin和Host一致（没有Origin的请求允许通过）
+}
+
+// WebSocketCloseError 对方关闭连接或者因为错误关闭连接
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (this *WebSocketCloseError) Error() string {
+	var message = "websocket: close " + strconv.Itoa(this.Code)
+	if len(this.Reason) > 0 {
+		message += " " + this.Reason
+	}
+	return message
+}
+
+// WebSocketConn WebSocket连接
+// 同一时间只能有一个协程读取消息，写入消息可以在多个协程中同时进行
+type WebSocketConn struct {
+	Subprotocol string // 选择的子协议
+
+	conn    net.Conn
+	reader  *bufio.Reader
+	options WebSocketOptions
+
+	writeLocker sync.Mutex
+	closeSent   bool
+	closeOnce   sync.Once
+	closeChan   chan bool
+}
+
+// WebSocket 将当前请求升级为WebSocket连接
+// 升级失败时会输出错误信息并结束当前动作
+// 在升级之前设置的Cookie（包括新的SESSION）会随握手响应一起发送
+func (this *ActionObject) WebSocket(options ...*WebSocketOptions) *WebSocketConn {
+	var opts *WebSocketOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	// 不再使用压缩等自定义输出
+	this.detachWriter()
+
+	conn, err := UpgradeWebSocket(this.ResponseWriter, this.Request, opts)
+	if err != nil {
+		panic(this)
+	}
+	return conn
+}
+
+// UpgradeWebSocket 将HTTP请求升级为WebSocket连接，失败时会输出对应的错误状态码
+func UpgradeWebSocket(writer http.ResponseWriter, request *http.Request, options *WebSocketOptions) (*WebSocketConn, error) {
+	var opts = WebSocketOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = WebSocketDefaultMaxMessageSize
+	}
+	if opts.PingInterval == 0 {
+		opts.PingInterval = WebSocketDefaultPingInterval
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = WebSocketDefaultPongTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = WebSocketDefaultWriteTimeout
+	}
+
+	var fail = func(code int, message string) error {
+		http.Error(writer, message, code)
+		return errors.New("websocket: " + message)
+	}
+
+	// 校验握手请求
+	if request.Method != http.MethodGet {
+		return nil, fail(http.StatusMethodNotAllowed, "request method should be GET")
+	}
+	if !headerContainsToken(request.Header, "Connection", "upgrade") || !headerContainsToken(request.Header, "Upgrade", "websocket") {
+		return nil, fail(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if request.Header.Get("Sec-WebSocket-Version") != "13" {
+		writer.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	var key = strings.TrimSpace(request.Header.Get("Sec-WebSocket-Key"))
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decodedKey) != 16 {
+		return nil, fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	var checkOrigin = opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(request) {
+		return nil, fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	// 子协议
+	var subprotocol = ""
+	if len(opts.Subprotocols) > 0 {
+	FindProtocol:
+		for _, clientProtocol := range strings.Split(request.Header.Get("Sec-WebSocket-Protocol"), ",") {
+			clientProtocol = strings.TrimSpace(clientProtocol)
+			for _, protocol := range opts.Subprotocols {
+				if clientProtocol == protocol {
+					subprotocol = protocol
+					break FindProtocol
+				}
+			}
+		}
+	}
+
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		return nil, fail(http.StatusInternalServerError, "http.Hijacker not implemented by http.ResponseWriter")
+	}
+
+	// 响应
+	var hash = sha1.Sum([]byte(key + webSocketGUID))
+	var response = "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n"
+	if len(subprotocol) > 0 {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	for name, values := range writer.Header() {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding", "Vary",
+			"Upgrade", "Connection", "Sec-Websocket-Accept", "Sec-Websocket-Protocol":
+			continue
+		}
+		for _, value := range values {
+			response += name + ": " + strings.NewReplacer("\r", "", "\n", "").Replace(value) + "\r\n"
+		}
+	}
+	response += "\r\n"
+
+	netConn, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fail(http.StatusInternalServerError, err.Error())
+	}
+
+	// 清除HTTP服务设置的超时时间
+	_ = netConn.SetDeadline(time.Time{})
+
+	_ = netConn.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+	_, err = netConn.Write([]byte(response))
+	if err != nil {
+		_ = netConn.Close()
+		return nil, errors.New("websocket: " + err.Error())
+	}
+	_ = netConn.SetWriteDeadline(time.Time{})
+
+	var conn = &WebSocketConn{
+		Subprotocol: subprotocol,
+		conn:        netConn,
+		reader:      readWriter.Reader,
+		options:     opts,
+		closeChan:   make(chan bool),
+	}
+	conn.extendReadDeadline()
+	if opts.PingInterval > 0 {
+		go conn.keepAlive()
+	}
+	return conn, nil
+}
+
+// ReadMessage 读取一个完整的消息，分片的消息会被合并
+// ping会自动回复pong；收到关闭消息时会回复关闭消息并返回 *WebSocketCloseError
+func (this *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	var message []byte
+	messageType = -1
+	for {
+		fin, opcode, payload, err := this.readFrame()
+		if err != nil {
+			return -1, nil, err
+		}
+
+		switch opcode {
+		case WebSocketPingMessage:
+			err = this.writeFrame(WebSocketPongMessage, payload)
+			if err != nil {
+				return -1, nil, err
+			}
+			continue
+		case WebSocketPongMessage:
+			continue
+		case WebSocketCloseMessage:
+			return -1, nil, this.handleClose(payload)
+		case WebSocketTextMessage, WebSocketBinaryMessage:
+			if messageType != -1 {
+				return -1, nil, this.fail(WebSocketCloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		case WebSocketContinuationFrame:
+			if messageType == -1 {
+				return -1, nil, this.fail(WebSocketCloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return -1, nil, this.fail(WebSocketCloseProtocolError, "unknown opcode "+strconv.Itoa(opcode))
+		}
+
+		if int64(len(message))+int64(len(payload)) > this.options.MaxMessageSize {
+			return -1, nil, this.fail(WebSocketCloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if fin {
+			break
+		}
+	}
+
+	if messageType == WebSocketTextMessage && !utf8.Valid(message) {
+		return -1, nil, this.fail(WebSocketCloseInvalidPayload, "invalid UTF-8 text")
+	}
+	if message == nil {
+		message = []byte{}
+	}
+	return messageType, message, nil
+}
+
+// ReadJSON 读取一个消息并解析为JSON
+func (this *WebSocketConn) ReadJSON(ptr interface{}) error {
+	_, data, err := this.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, ptr)
+}
+
+// WriteMessage 写入一个消息
+func (this *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WebSocketTextMessage && messageType != WebSocketBinaryMessage {
+		return errors.New("websocket: invalid message type " + strconv.Itoa(messageType))
+	}
+	return this.writeFrame(messageType, data)
+}
+
+// WriteText 写入一个文本消息
+func (this *WebSocketConn) WriteText(text string) error {
+	return this.writeFrame(WebSocketTextMessage, []byte(text))
+}
+
+// WriteJSON 将值编码为JSON后作为文本消息写入
+func (this *WebSocketConn) WriteJSON(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return this.writeFrame(WebSocketTextMessage, data)
+}
+
+// Ping 发送ping
+func (this *WebSocketConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return errors.New("websocket: ping payload should not be longer than 125 bytes")
+	}
+	return this.writeFrame(WebSocketPingMessage, data)
+}
+
+// Close 正常关闭连接
+func (this *WebSocketConn) Close() error {
+	return this.CloseWithCode(WebSocketCloseNormal, "")
+}
+
+// CloseWithCode 使用关闭代码关闭连接
+func (this *WebSocketConn) CloseWithCode(code int, reason string) error {
+	var payload = []byte{}
+	if code != WebSocketCloseNoStatus {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+	}
+	err := this.writeFrame(WebSocketCloseMessage, payload)
+	this.closeConn()
+	if err == ErrWebSocketClosed {
+		return nil
+	}
+	return err
+}
+
+// RemoteAddr 取得客户端地址
+func (this *WebSocketConn) RemoteAddr() net.Addr {
+	return this.conn.RemoteAddr()
+}
+
+// 读取一个帧
+func (this *WebSocketConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	_, err = io.ReadFull(this.reader, header[:])
+	if err != nil {
+		return false, 0, nil, this.readError(err)
+	}
+	this.extendReadDeadline()
+
+	fin = header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, this.fail(WebSocketCloseProtocolError, "reserved bits should be zero")
+	}
+	opcode = int(header[0] & 0x0f)
+	var masked = header[1]&0x80 != 0
+	var length = int64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var b [2]byte
+		_, err = io.ReadFull(this.reader, b[:])
+		if err != nil {
+			return false, 0, nil, this.readError(err)
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, err = io.ReadFull(this.reader, b[:])
+		if err != nil {
+			return false, 0, nil, this.readError(err)
+		}
+		if b[0]&0x80 != 0 {
+			return false, 0, nil, this.fail(WebSocketCloseProtocolError, "invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+	}
+
+	// 控制帧
+	if opcode >= WebSocketCloseMessage {
+		if !fin {
+			return false, 0, nil, this.fail(WebSocketCloseProtocolError, "control frame should not be fragmented")
+		}
+		if length > 125 {
+			return false, 0, nil, this.fail(WebSocketCloseProtocolError, "control frame too long")
+		}
+	} else if length > this.options.MaxMessageSize {
+		return false, 0, nil, this.fail(WebSocketCloseMessageTooBig, "message too big")
+	}
+
+	// 客户端发送的帧必须有掩码
+	if !masked {
+		return false, 0, nil, this.fail(WebSocketCloseProtocolError, "client frame should be masked")
+	}
+	var mask [4]byte
+	_, err = io.ReadFull(this.reader, mask[:])
+	if err != nil {
+		return false, 0, nil, this.readError(err)
+	}
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(this.reader, payload)
+	if err != nil {
+		return false, 0, nil, this.readError(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// 写入一个帧，服务端发送的帧不使用掩码
+func (this *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	this.writeLocker.Lock()
+	defer this.writeLocker.Unlock()
+
+	if this.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == WebSocketCloseMessage {
+		this.closeSent = true
+	}
+
+	var frame = make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|byte(opcode))
+	var length = len(payload)
+	switch {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	_ = this.conn.SetWriteDeadline(time.Now().Add(this.options.WriteTimeout))
+	_, err := this.conn.Write(frame)
+	if err != nil {
+		this.closeConn()
+		return errors.New("websocket: " + err.Error())
+	}
+	return nil
+}
+
+// 处理对方发送的关闭消息
+func (this *WebSocketConn) handleClose(payload []byte) error {
+	var closeErr = &WebSocketCloseError{
+		Code: WebSocketCloseNoStatus,
+	}
+	if len(payload) == 1 {
+		return this.fail(WebSocketCloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !isValidCloseCode(closeErr.Code) {
+			return this.fail(WebSocketCloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return this.fail(WebSocketCloseInvalidPayload, "invalid UTF-8 close reason")
+		}
+	}
+
+	// 回复关闭消息
+	var replyCode = closeErr.Code
+	if replyCode == WebSocketCloseNoStatus {
+		_ = this.CloseWithCode(WebSocketCloseNoStatus, "")
+	} else {
+		_ = this.CloseWithCode(replyCode, "")
+	}
+	return closeErr
+}
+
+// 因为错误关闭连接
+func (this *WebSocketConn) fail(code int, reason string) error {
+	_ = this.CloseWithCode(code, reason)
+	return &WebSocketCloseError{
+		Code:   code,
+		Reason: reason,
+	}
+}
+
+func (this *WebSocketConn) readError(err error) error {
+	this.closeConn()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &WebSocketCloseError{
+			Code:   WebSocketCloseAbnormal,
+			Reason: "unexpected EOF",
+		}
+	}
+	return errors.New("websocket: " + err.Error())
+}
+
+// 延长读取超时时间，在收到任何帧后调用
+func (this *WebSocketConn) extendReadDeadline() {
+	if this.options.PingInterval > 0 {
+		_ = this.conn.SetReadDeadline(time.Now().Add(this.options.PingInterval + this.options.PongTimeout))
+	}
+}
+
+// 定时发送ping
+func (this *WebSocketConn) keepAlive() {
+	var ticker = time.NewTicker(this.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := this.writeFrame(WebSocketPingMessage, nil)
+			if err != nil {
+				return
+			}
+		case <-this.closeChan:
+			return
+		}
+	}
+}
+
+func (this *WebSocketConn) closeConn() {
+	this.closeOnce.Do(func() {
+		close(this.closeChan)
+		_ = this.conn.Close()
+	})
+}
+
+// 检查Origin是否和Host一致
+func checkSameOrigin(request *http.Request) bool {
+	var origin = request.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, request.Host)
+}
+
+// 判断Header中是否包含某个值，不区分大小写
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, piece := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(piece), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 是否为可以在关闭消息中使用的代码
+func isValidCloseCode(code int) bool {
+	switch code {
+	case 1000, 1001, 1002, 1003, 1007, 1008, 1009, 1010, 1011:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
@@ -0,0 +1,222 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testWebSocketServer(t *testing.T, options *WebSocketOptions) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.SetCookie(writer, &http.Cookie{Name: "sid", Value: "123"})
+		conn, err := UpgradeWebSocket(writer, request, options)
+		if err != nil {
+			return
+		}
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(messageType, data)
+		}
+	}))
+}
+
+func testWebSocketDial(t *testing.T, server *httptest.Server, origin string) (net.Conn, *bufio.Reader, string) {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request = "GET /ws HTTP/1.1\r\n" +
+		"Host: " + server.Listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if len(origin) > 0 {
+		request += "Origin: " + origin + "\r\n"
+	}
+	_, err = conn.Write([]byte(request + "\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reader = bufio.NewReader(conn)
+	var head = ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		head += line
+		if line == "\r\n" {
+			break
+		}
+	}
+	return conn, reader, head
+}
+
+func testWebSocketWriteFrame(conn net.Conn, fin bool, opcode byte, payload []byte) {
+	var frame = []byte{opcode}
+	if fin {
+		frame[0] |= 0x80
+	}
+	if len(payload) <= 125 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	var mask = []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, _ = conn.Write(frame)
+}
+
+func testWebSocketReadFrame(t *testing.T, reader *bufio.Reader) (opcode byte, payload []byte) {
+	var header = make([]byte, 2)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frame should not be masked")
+	}
+	var length = int(header[1] & 0x7f)
+	if length == 126 {
+		var b = make([]byte, 2)
+		_, _ = io.ReadFull(reader, b)
+		length = int(binary.BigEndian.Uint16(b))
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestWebSocket_Handshake(t *testing.T) {
+	var server = testWebSocketServer(t, nil)
+	defer server.Close()
+
+	conn, _, head := testWebSocketDial(t, server, "")
+	defer func() {
+		_ = conn.Close()
+	}()
+	t.Log(head)
+	if !strings.HasPrefix(head, "HTTP/1.1 101 ") {
+		t.Fatal("handshake failed")
+	}
+	if !strings.Contains(head, "Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=") {
+		t.Fatal("invalid accept key")
+	}
+	if !strings.Contains(head, "Set-Cookie: sid=123") {
+		t.Fatal("cookie should be sent with handshake")
+	}
+}
+
+func TestWebSocket_Origin(t *testing.T) {
+	var server = testWebSocketServer(t, nil)
+	defer server.Close()
+
+	conn, _, head := testWebSocketDial(t, server, "http://evil.example.com")
+	_ = conn.Close()
+	if !strings.HasPrefix(head, "HTTP/1.1 403 ") {
+		t.Fatal("cross origin request should be rejected, got:", head)
+	}
+
+	conn, _, head = testWebSocketDial(t, server, "http://"+server.Listener.Addr().String())
+	_ = conn.Close()
+	if !strings.HasPrefix(head, "HTTP/1.1 101 ") {
+		t.Fatal("same origin request should be accepted, got:", head)
+	}
+}
+
+func TestWebSocket_Messages(t *testing.T) {
+	var server = testWebSocketServer(t, nil)
+	defer server.Close()
+
+	conn, reader, _ := testWebSocketDial(t, server, "")
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// 文本
+	testWebSocketWriteFrame(conn, true, WebSocketTextMessage, []byte("Hello"))
+	opcode, payload := testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketTextMessage || string(payload) != "Hello" {
+		t.Fatal("invalid echo:", opcode, string(payload))
+	}
+
+	// 二进制
+	var data = []byte(strings.Repeat("a", 1000))
+	testWebSocketWriteFrame(conn, true, WebSocketBinaryMessage, data)
+	opcode, payload = testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketBinaryMessage || string(payload) != string(data) {
+		t.Fatal("invalid binary echo")
+	}
+
+	// 分片，中间插入ping
+	testWebSocketWriteFrame(conn, false, WebSocketTextMessage, []byte("Hello, "))
+	testWebSocketWriteFrame(conn, true, WebSocketPingMessage, []byte("p"))
+	testWebSocketWriteFrame(conn, true, WebSocketContinuationFrame, []byte("World"))
+	opcode, payload = testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketPongMessage || string(payload) != "p" {
+		t.Fatal("expect pong, got:", opcode)
+	}
+	opcode, payload = testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketTextMessage || string(payload) != "Hello, World" {
+		t.Fatal("invalid fragmented echo:", string(payload))
+	}
+
+	// 关闭
+	testWebSocketWriteFrame(conn, true, WebSocketCloseMessage, []byte{0x03, 0xe8})
+	opcode, payload = testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketCloseMessage || binary.BigEndian.Uint16(payload) != WebSocketCloseNormal {
+		t.Fatal("expect close 1000, got:", opcode, payload)
+	}
+}
+
+func TestWebSocket_MaxMessageSize(t *testing.T) {
+	var server = testWebSocketServer(t, &WebSocketOptions{
+		MaxMessageSize: 10,
+	})
+	defer server.Close()
+
+	conn, reader, _ := testWebSocketDial(t, server, "")
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	testWebSocketWriteFrame(conn, false, WebSocketTextMessage, []byte("123456"))
+	testWebSocketWriteFrame(conn, true, WebSocketContinuationFrame, []byte("789012"))
+	opcode, payload := testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketCloseMessage || binary.BigEndian.Uint16(payload) != WebSocketCloseMessageTooBig {
+		t.Fatal("expect close 1009, got:", opcode, payload)
+	}
+}
+
+func TestWebSocket_ProtocolError(t *testing.T) {
+	var server = testWebSocketServer(t, nil)
+	defer server.Close()
+
+	conn, reader, _ := testWebSocketDial(t, server, "")
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	testWebSocketWriteFrame(conn, true, WebSocketContinuationFrame, []byte("abc"))
+	opcode, payload := testWebSocketReadFrame(t, reader)
+	if opcode != WebSocketCloseMessage || binary.BigEndian.Uint16(payload) != WebSocketCloseProtocolError {
+		t.Fatal("expect close 1002, got:", opcode, payload)
+	}
+}
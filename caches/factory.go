@@ -23,6 +23,9 @@ type Factory struct {
 	looper      *timers.Looper
 }
 
+var allFactories = map[*Factory]bool{}
+var allFactoriesLocker = sync.Mutex{}
+
 // 创建一个新的缓存管理器
 func NewFactory() *Factory {
 	return newFactoryInterval(30 * time.Second)
@@ -38,6 +41,10 @@ func newFactoryInterval(duration time.Duration) *Factory {
 		factory.clean()
 	})
 
+	allFactoriesLocker.Lock()
+	allFactories[factory] = true
+	allFactoriesLocker.Unlock()
+
 	return factory
 }
 
@@ -123,6 +130,29 @@ func (this *Factory) Close() {
 	}
 
 	this.items = map[string]*Item{}
+
+	allFactoriesLocker.Lock()
+	delete(allFactories, this)
+	allFactoriesLocker.Unlock()
+}
+
+// 缓存数量
+func (this *Factory) Len() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return len(this.items)
+}
+
+// 取得所有没有关闭的缓存管理器
+func AllFactories() []*Factory {
+	allFactoriesLocker.Lock()
+	defer allFactoriesLocker.Unlock()
+
+	var result = []*Factory{}
+	for factory := range allFactories {
+		result = append(result, factory)
+	}
+	return result
 }
 
 // 重置状态
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// 显示合并后的配置
+type ConfigShowCommand struct {
+	*Command
+}
+
+func (this *ConfigShowCommand) Name() string {
+	return "print effective server and database config, secrets are masked"
+}
+
+func (this *ConfigShowCommand) Usage() string {
+	return ":config.show [server|db] [-env=ENV]"
+}
+
+func (this *ConfigShowCommand) Codes() []string {
+	return []string{":config.show"}
+}
+
+func (this *ConfigShowCommand) Run() {
+	var target, _ = this.Arg(1)
+	if target != "" && target != "server" && target != "db" {
+		this.ErrorString("invalid config '" + target + "', should be 'server' or 'db'")
+		return
+	}
+	env, _ := this.Param("env")
+
+	// server
+	serverData, serverFile, err := Tea.LoadConfig([]string{"server.yaml", "server.conf"}, "TEAGO_SERVER_", env)
+	if err != nil && !os.IsNotExist(err) {
+		this.Error(errors.New("load server config: " + err.Error()))
+		return
+	}
+	if len(env) == 0 {
+		env = Tea.Env
+		if serverData != nil {
+			serverEnv, ok := serverData["env"].(string)
+			if ok && len(serverEnv) > 0 {
+				env = serverEnv
+			}
+		}
+	}
+	if target == "" || target == "server" {
+		this.printConfig(serverFile, serverData)
+	}
+
+	// db
+	if target == "" || target == "db" {
+		dbData, dbFile, err := Tea.LoadConfig([]string{".db.yaml", "db.yaml", "db.conf"}, "TEAGO_DB_", env)
+		if err != nil && !os.IsNotExist(err) {
+			this.Error(errors.New("load db config: " + err.Error()))
+			return
+		}
+		this.printConfig(dbFile, dbData)
+	}
+}
+
+func (this *ConfigShowCommand) printConfig(configFile string, data map[string]interface{}) {
+	if data == nil {
+		return
+	}
+	yamlBytes, err := yaml.Marshal(Tea.MaskConfig(data))
+	if err != nil {
+		this.Error(err)
+		return
+	}
+	this.Output("<code># " + configFile + "</code>\n" + string(yamlBytes) + "\n")
+}
@@ -3,4 +3,5 @@ package cmd
 func init() {
 	Register(new(HelpCommand))
 	Register(new(QuitCommand))
+	Register(new(ConfigShowCommand))
 }
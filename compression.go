@@ -0,0 +1,168 @@
+package TeaGo
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"github.com/iwind/TeaGo/actions"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 动态压缩支持的编码，按优先级排列
+var compressEncodings = []string{"gzip", "deflate"}
+
+// 动态压缩的级别
+const compressLevel = 5
+
+// 小于此尺寸的内容不压缩
+const compressMinLength = 1024
+
+// 判断Content-Type是否为可以压缩的文本类型
+func isTextContentType(contentType string) bool {
+	index := strings.Index(contentType, ";")
+	if index > -1 {
+		contentType = contentType[:index]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if len(contentType) == 0 {
+		return false
+	}
+	_, found := textMimeMap[contentType]
+	return found || strings.HasPrefix(contentType, "text/")
+}
+
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// 压缩输出内容的Writer，在输出状态码时根据Content-Type等信息决定是否压缩
+type compressWriter struct {
+	responseWriter http.ResponseWriter
+	request        *http.Request
+	encoding       string
+
+	encoder     compressEncoder
+	wroteHeader bool
+}
+
+func newCompressWriter(writer http.ResponseWriter, request *http.Request, encoding string) *compressWriter {
+	return &compressWriter{
+		responseWriter: writer,
+		request:        request,
+		encoding:       encoding,
+	}
+}
+
+func (this *compressWriter) Header() http.Header {
+	return this.responseWriter.Header()
+}
+
+func (this *compressWriter) WriteHeader(status int) {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+
+	if this.shouldCompress(status) {
+		var header = this.responseWriter.Header()
+		var err error
+		switch this.encoding {
+		case "gzip":
+			this.encoder, err = gzip.NewWriterLevel(this.responseWriter, compressLevel)
+		case "deflate":
+			this.encoder, err = flate.NewWriter(this.responseWriter, compressLevel)
+		default:
+			err = errors.New("unsupported encoding '" + this.encoding + "'")
+		}
+		if err == nil {
+			header.Set("Content-Encoding", this.encoding)
+			header.Del("Content-Length")
+			header.Del("Accept-Ranges")
+			actions.AddVaryHeader(header, "Accept-Encoding")
+		}
+	}
+
+	this.responseWriter.WriteHeader(status)
+}
+
+func (this *compressWriter) Write(b []byte) (int, error) {
+	if !this.wroteHeader {
+		var header = this.responseWriter.Header()
+		if len(header.Get("Content-Type")) == 0 && len(b) > 0 {
+			header.Set("Content-Type", http.DetectContentType(b))
+		}
+		this.WriteHeader(http.StatusOK)
+	}
+	if this.encoder != nil {
+		return this.encoder.Write(b)
+	}
+	return this.responseWriter.Write(b)
+}
+
+func (this *compressWriter) Flush() {
+	if this.encoder != nil {
+		_ = this.encoder.Flush()
+	}
+	flusher, ok := this.responseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+func (this *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := this.responseWriter.(http.Hijacker)
+	if ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker not implemented by underlying http.ResponseWriter")
+}
+
+func (this *compressWriter) Unwrap() http.ResponseWriter {
+	return this.responseWriter
+}
+
+// Close 结束压缩，写入剩余的数据
+func (this *compressWriter) Close() error {
+	if this.encoder != nil {
+		return this.encoder.Close()
+	}
+	return nil
+}
+
+// 判断是否需要压缩
+func (this *compressWriter) shouldCompress(status int) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if this.request.Method == http.MethodHead {
+		return false
+	}
+
+	var header = this.responseWriter.Header()
+	if len(header.Get("Content-Encoding")) > 0 {
+		return false
+	}
+	if !isTextContentType(header.Get("Content-Type")) {
+		return false
+	}
+
+	// 事件流需要及时送达，不压缩
+	if strings.HasPrefix(strings.ToLower(header.Get("Content-Type")), "text/event-stream") {
+		return false
+	}
+
+	var contentLength = header.Get("Content-Length")
+	if len(contentLength) > 0 {
+		length, err := strconv.ParseInt(contentLength, 10, 64)
+		if err == nil && length < compressMinLength {
+			return false
+		}
+	}
+	return true
+}
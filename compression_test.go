@@ -0,0 +1,85 @@
+package TeaGo
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServer_ServeStaticFile(t *testing.T) {
+	var dir = t.TempDir()
+	var content = strings.Repeat("body { color: red; }\n", 100)
+	err := os.WriteFile(dir+"/app.css", []byte(content), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/app.css.gz", []byte("precompressed"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/app.js", []byte(strings.Repeat("console.log(1);\n", 100)), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server = NewServer(false)
+	var options = staticOptions{cacheControl: "max-age=3600", etag: true}
+	var serve = func(name string, header http.Header) *httptest.ResponseRecorder {
+		var request = httptest.NewRequest(http.MethodGet, "/"+name, nil)
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		var recorder = httptest.NewRecorder()
+		server.serveStaticFile(recorder, request, os.DirFS(dir), dir, name, options)
+		return recorder
+	}
+
+	// 没有 Accept-Encoding
+	recorder := serve("app.css", nil)
+	if recorder.Body.String() != content || len(recorder.Header().Get("Content-Encoding")) > 0 {
+		t.Fatal("should not be compressed")
+	}
+	if recorder.Header().Get("Cache-Control") != "max-age=3600" {
+		t.Fatal("Cache-Control should be set")
+	}
+	var etag = recorder.Header().Get("ETag")
+	if len(etag) == 0 || strings.HasPrefix(etag, "W/") {
+		t.Fatal("strong ETag should be set")
+	}
+
+	// If-None-Match
+	recorder = serve("app.css", http.Header{"If-None-Match": {etag}})
+	if recorder.Code != http.StatusNotModified {
+		t.Fatal("expected 304, but got", recorder.Code)
+	}
+
+	// 预压缩文件
+	recorder = serve("app.css", http.Header{"Accept-Encoding": {"gzip"}})
+	if recorder.Body.String() != "precompressed" || recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("precompressed file should be used")
+	}
+	if recorder.Header().Get("ETag") == etag {
+		t.Fatal("ETag should be different from the uncompressed one")
+	}
+
+	// 动态压缩
+	recorder = serve("app.js", http.Header{"Accept-Encoding": {"gzip"}})
+	if recorder.Header().Get("Content-Encoding") != "gzip" || !strings.HasSuffix(recorder.Header().Get("ETag"), "-gzip\"") {
+		t.Fatal("should be compressed")
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != strings.Repeat("console.log(1);\n", 100) {
+		t.Fatal("unexpected content")
+	}
+}
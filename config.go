@@ -1,58 +1,63 @@
 package TeaGo
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/actions"
 	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/utils/string"
-	"gopkg.in/yaml.v3"
-	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 )
 
 // ServerConfig 服务配置
 type ServerConfig struct {
 	Http struct {
 		On              bool     `yaml:"on" json:"on"`
-		Listen          []string `yaml:"listen" json:"listen"`                   // 监听地址，带端口
+		Listen          []string `yaml:"listen" json:"listen"`                   // 监听地址，带端口，也支持 unix:/path.sock 和 systemd:name，可以用 ?proxyProtocol=on 等选项
 		RedirectToHTTPS bool     `yaml:"redirectToHTTPS" json:"redirectToHTTPS"` // 自动跳转到HTTPS
+		RedirectPort    int      `yaml:"redirectPort" json:"redirectPort"`       // 跳转到HTTPS时使用的端口，为0时使用https中第一个监听地址的端口
 	} `yaml:"http" json:"http"`
 	Https struct {
-		On     bool     `yaml:"on" json:"on"`
-		Listen []string `yaml:"listen" json:"listen"`
-		Cert   string   `yaml:"cert" json:"cert"`
-		Key    string   `yaml:"key" json:"key"`
+		On           bool               `yaml:"on" json:"on"`
+		Listen       []string           `yaml:"listen" json:"listen"`
+		Cert         string             `yaml:"cert" json:"cert"`
+		Key          string             `yaml:"key" json:"key"`
+		Certs        []ServerCertConfig `yaml:"certs" json:"certs"`               // 多个证书，根据SNI选择
+		MinVersion   string             `yaml:"minVersion" json:"minVersion"`     // 最低TLS版本：1.0、1.1、1.2、1.3
+		CipherSuites []string           `yaml:"cipherSuites" json:"cipherSuites"` // 加密套件名称，比如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+		ClientCA     string             `yaml:"clientCA" json:"clientCA"`         // 用来校验客户端证书的CA文件
+		ClientAuth   string             `yaml:"clientAuth" json:"clientAuth"`     // 客户端证书校验方式：none、request、require、verifyIfGiven、requireAndVerify
 	} `yaml:"https" json:"https"`
 	Env     string `yaml:"env" json:"env"`         // 环境，dev、test或prod
 	Charset string `yaml:"charset" json:"charset"` // 字符集
 	Upload  struct {
 		MaxSize      string `yaml:"maxSize" json:"maxSize"` // 允许上传的最大尺寸
+		Stream       bool   `yaml:"stream" json:"stream"`   // 是否对所有路由使用流式上传
 		maxSizeFloat float64
 	} `yaml:"upload" json:"upload"` // 上传配置
 	Errors map[string]interface{} `yaml:"errors" json:"errors"` // 错误配置
 }
 
-func (this *ServerConfig) Load() {
-	// 先查找yaml
-	configFile := Tea.ConfigFile("server.yaml")
-	_, err := os.Stat(configFile)
-	if err != nil {
-		// 查找server.conf
-		configFile = Tea.ConfigFile("server.conf")
-		_, err = os.Stat(configFile)
-		if err != nil {
-			logs.Errorf("%s", err.Error())
-			return
-		}
-	}
+// ServerCertConfig 证书配置
+type ServerCertConfig struct {
+	Cert string `yaml:"cert" json:"cert"`
+	Key  string `yaml:"key" json:"key"`
+}
 
-	fileBytes, err := os.ReadFile(configFile)
+// Load 加载配置
+// 配置文件为 configs/server.yaml 或 configs/server.conf，支持 ${VAR} 环境变量，
+// 并会合并当前环境对应的 server.[env].yaml 或 server.[env].conf，再使用 TEAGO_SERVER_ 开头的环境变量覆盖单个选项
+func (this *ServerConfig) Load() {
+	data, _, err := Tea.LoadConfig([]string{"server.yaml", "server.conf"}, "TEAGO_SERVER_", "")
 	if err != nil {
 		logs.Errorf("%s", err.Error())
 		return
 	}
-	err = yaml.Unmarshal(fileBytes, this)
+	err = Tea.DecodeConfig(data, this)
 	if err != nil {
 		logs.Errorf("%s", err.Error())
 	} else {
@@ -84,48 +89,110 @@ func (this *ServerConfig) MaxSize() float64 {
 	return this.Upload.maxSizeFloat
 }
 
-func (this *ServerConfig) processError(request *http.Request, response io.Writer, code int, message string) {
-	if this.Errors == nil {
-		http.Error(response.(http.ResponseWriter), message, code)
+// 输出错误页面
+// 依次查找 errors.主机."@模块".错误码、errors.主机.错误码、errors."@模块".错误码 和 errors.错误码 中的配置，支持 url 和 view 两种方式
+func (this *ServerConfig) processError(request *http.Request, writer http.ResponseWriter, host string, module string, code int, message string, err error) {
+	// JSON
+	if strings.Contains(request.Header.Get("Accept"), "application/json") {
+		var errorMessage = message
+		if err != nil && this.Env != Tea.EnvProd {
+			errorMessage = err.Error()
+		}
+		jsonBytes, jsonErr := json.Marshal(maps.Map{
+			"code":    code,
+			"message": errorMessage,
+			"data":    nil,
+		})
+		if jsonErr != nil {
+			http.Error(writer, message, code)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.WriteHeader(code)
+		_, _ = writer.Write(jsonBytes)
 		return
 	}
-	errorConfig, found := this.Errors[strconv.Itoa(code)]
-	if !found {
-		http.Error(response.(http.ResponseWriter), message, code)
+
+	var errorConfig = this.findErrorConfig(host, module, code)
+	if errorConfig == nil {
+		http.Error(writer, message, code)
 		return
 	}
-	mapConfig, ok := errorConfig.(map[string]interface{})
-	if !ok {
-		http.Error(response.(http.ResponseWriter), message, code)
+
+	// 跳转
+	var url = errorConfig.GetString("url")
+	if len(url) > 0 {
+		http.Redirect(writer, request, url, http.StatusMovedPermanently)
 		return
 	}
-	url, ok := mapConfig["url"]
-	if ok {
-		urlString, ok := url.(string)
-		if ok {
-			http.Redirect(response.(http.ResponseWriter), request, urlString, http.StatusMovedPermanently)
+
+	// 读取错误页面
+	var view = errorConfig.GetString("view")
+	if len(view) > 0 {
+		var errorString = ""
+		if err != nil {
+			errorString = err.Error()
+		}
+		data, renderErr := actions.RenderView(request, view, actions.Data{
+			"code":    code,
+			"message": message,
+			"error":   errorString,
+			"request": maps.Map{
+				"method":     request.Method,
+				"uri":        request.RequestURI,
+				"path":       request.URL.Path,
+				"host":       request.Host,
+				"remoteAddr": request.RemoteAddr,
+			},
+		})
+		if renderErr != nil {
+			logs.Error(errors.New("render error view '" + view + "': " + renderErr.Error()))
+			http.Error(writer, message, code)
 			return
 		}
+
+		var contentType = "text/html"
+		if len(this.Charset) > 0 {
+			contentType += "; charset=" + this.Charset
+		}
+		writer.Header().Set("Content-Type", contentType)
+		writer.WriteHeader(code)
+		_, _ = writer.Write(data)
+		return
 	}
 
-	// 读取错误页面
-	viewFile, ok := mapConfig["view"]
-	if ok {
-		// @TODO
-		logs.Println(viewFile)
-		/**viewFileString, ok := viewFile.(string)
-		if ok {
-			data := map[string]interface{}{
-				"Request": request,
-			}
-			response.(http.ResponseWriter).WriteHeader(http.StatusNotFound)
+	http.Error(writer, message, code)
+}
 
-			//templates.Render(response, "default", "views", viewFileString, data, template.FuncMap{})
-			return
-		}**/
+// 查找某个错误码对应的配置，主机中的配置优先，其次为模块中的配置
+func (this *ServerConfig) findErrorConfig(host string, module string, code int) maps.Map {
+	if len(this.Errors) == 0 {
+		return nil
+	}
+
+	var errorsMap = maps.NewMap(this.Errors)
+	if len(host) > 0 {
+		var hostConfig = errorsMap.GetMap(host)
+		if hostConfig != nil {
+			var errorConfig = findErrorConfigInMap(hostConfig, module, code)
+			if errorConfig != nil {
+				return errorConfig
+			}
+		}
 	}
+	return findErrorConfigInMap(errorsMap, module, code)
+}
 
-	if !ok {
-		http.Error(response.(http.ResponseWriter), message, code)
+func findErrorConfigInMap(errorsMap maps.Map, module string, code int) maps.Map {
+	var codeString = strconv.Itoa(code)
+	if len(module) > 0 {
+		var moduleConfig = errorsMap.GetMap("@" + module)
+		if moduleConfig != nil && moduleConfig.Has(codeString) {
+			return moduleConfig.GetMap(codeString)
+		}
+	}
+	if errorsMap.Has(codeString) {
+		return errorsMap.GetMap(codeString)
 	}
+	return nil
 }
@@ -0,0 +1,76 @@
+package TeaGo
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServerConfig_ProcessError(t *testing.T) {
+	var dir = t.TempDir()
+	err := os.MkdirAll(dir+"/errors", 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/errors/default.html", []byte(`default {$.code} {$.message} {$.request.path}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/errors/admin.html", []byte(`admin {$.code} {$.error}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var oldViewsDir = Tea.ViewsDir()
+	Tea.SetViewsDir(dir)
+	defer Tea.SetViewsDir(oldViewsDir)
+
+	var config = &ServerConfig{}
+	config.Charset = "utf-8"
+	config.Errors = map[string]interface{}{
+		"404": map[string]interface{}{"view": "errors/default"},
+		"500": map[string]interface{}{"url": "/500.html"},
+		"@admin": map[interface{}]interface{}{
+			500: map[string]interface{}{"view": "errors/admin"},
+		},
+	}
+
+	for _, c := range []struct {
+		module   string
+		code     int
+		accept   string
+		status   int
+		contains string
+	}{
+		{"", http.StatusNotFound, "", http.StatusNotFound, "default 404 404 page not found /hello"},
+		{"admin", http.StatusNotFound, "", http.StatusNotFound, "default 404"},
+		{"admin", http.StatusInternalServerError, "", http.StatusInternalServerError, "admin 500 test error"},
+		{"", http.StatusInternalServerError, "", http.StatusMovedPermanently, "/500.html"},
+		{"", http.StatusMethodNotAllowed, "", http.StatusMethodNotAllowed, "Method Not Allowed"},
+		{"", http.StatusNotFound, "application/json", http.StatusNotFound, `"code":404`},
+	} {
+		var request = httptest.NewRequest(http.MethodGet, "/hello", nil)
+		if len(c.accept) > 0 {
+			request.Header.Set("Accept", c.accept)
+		}
+		var recorder = httptest.NewRecorder()
+		var message = http.StatusText(c.code)
+		if c.code == http.StatusNotFound {
+			message = "404 page not found"
+		}
+		config.processError(request, recorder, "", c.module, c.code, message, errors.New("test error"))
+
+		if recorder.Code != c.status {
+			t.Fatal("expected status", c.status, "but got", recorder.Code)
+		}
+		var body = recorder.Body.String() + recorder.Header().Get("Location")
+		if !strings.Contains(body, c.contains) {
+			t.Fatal("expected '" + c.contains + "', but got '" + body + "'")
+		}
+		t.Log(c.module, c.code, "=>", strings.TrimSpace(body))
+	}
+}
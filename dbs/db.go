@@ -1,13 +1,13 @@
 package dbs
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/types"
-	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -71,6 +71,32 @@ func Instance(dbId string) (*DB, error) {
 	return db, err
 }
 
+// CloseAll 关闭所有缓存的数据库实例
+func CloseAll() error {
+	dbCacheMutex.Lock()
+	defer dbCacheMutex.Unlock()
+
+	var errs = []error{}
+	for dbId, db := range dbCachedFactory {
+		errs = append(errs, db.Close())
+		delete(dbCachedFactory, dbId)
+	}
+
+	return anyError(errs...)
+}
+
+// Instances 取得所有缓存的数据库实例
+func Instances() []*DB {
+	dbCacheMutex.Lock()
+	defer dbCacheMutex.Unlock()
+
+	var result = []*DB{}
+	for _, db := range dbCachedFactory {
+		result = append(result, db)
+	}
+	return result
+}
+
 // NewInstance 根据ID获取一个新的数据库实例
 // 不会从上下文的缓存中读取
 func NewInstance(dbId string) (*DB, error) {
@@ -136,24 +162,17 @@ func loadConfig() {
 		}
 
 		// try .db.yaml
-		var configFileData []byte
-		var found bool
-		for _, configFilename := range []string{".db.yaml", "db.yaml", "db.conf"} {
-			var filename = Tea.ConfigFile(configFilename)
-			data, err := os.ReadFile(filename)
-			if err == nil {
-				configFileData = data
-				found = true
-				break
+		data, _, err := Tea.LoadConfig([]string{".db.yaml", "db.yaml", "db.conf"}, "TEAGO_DB_", Tea.Env)
+		if err != nil {
+			if os.IsNotExist(err) {
+				logs.Errorf("[DB]could not load database config file from '" + Tea.ConfigDir() + "'/[.db.yaml, db.yaml, db.conf]")
+			} else {
+				logs.Errorf("[DB]decode database config failed: %s", err.Error())
 			}
-		}
-
-		if !found {
-			logs.Errorf("[DB]could not load database config file from '" + Tea.ConfigDir() + "'/[.db.yaml, db.yaml, db.conf]")
 			return
 		}
 
-		err := yaml.Unmarshal(configFileData, &dbConfig)
+		err = Tea.DecodeConfig(data, &dbConfig)
 		if err != nil {
 			logs.Errorf("[DB]decode database config failed: %s", err.Error())
 			return
@@ -287,7 +306,12 @@ func (this *DB) Name() string {
 
 // Begin 开始一个事务
 func (this *DB) Begin() (*Tx, error) {
-	tx, err := this.rawDB.Begin()
+	return this.BeginTx(context.Background(), nil)
+}
+
+// BeginTx 使用上下文开始一个事务，上下文取消或超时后事务会被回滚
+func (this *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := this.rawDB.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -301,7 +325,12 @@ func (this *DB) StmtManager() *StmtManager {
 
 // RunTx 在函数中执行一个事务
 func (this *DB) RunTx(callback func(tx *Tx) error) error {
-	tx, err := this.Begin()
+	return this.RunTxContext(context.Background(), callback)
+}
+
+// RunTxContext 使用上下文在函数中执行一个事务
+func (this *DB) RunTxContext(ctx context.Context, callback func(tx *Tx) error) error {
+	tx, err := this.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -336,16 +365,36 @@ func (db *DB) Exec(query string, params ...any) (sql.Result, error) {
 	return db.rawDB.Exec(query, params...)
 }
 
+// ExecContext 使用上下文执行语句，上下文取消或超时后会中止执行
+func (this *DB) ExecContext(ctx context.Context, query string, params ...any) (sql.Result, error) {
+	return this.rawDB.ExecContext(ctx, query, params...)
+}
+
 func (this *DB) Prepare(query string) (*Stmt, error) {
 	return this.stmtManager.Prepare(this.rawDB, query)
 }
 
+// PrepareContext 使用上下文Prepare语句
+func (this *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	return this.stmtManager.PrepareContext(ctx, this.rawDB, query)
+}
+
 func (this *DB) PrepareOnce(query string) (*Stmt, bool, error) {
 	return this.stmtManager.PrepareOnce(this.rawDB, query, 0)
 }
 
+// PrepareOnceContext 使用上下文Prepare可重用的语句
+func (this *DB) PrepareOnceContext(ctx context.Context, query string) (*Stmt, bool, error) {
+	return this.stmtManager.PrepareOnceContext(ctx, this.rawDB, query, 0)
+}
+
 func (this *DB) FindOnes(query string, args ...any) (ones []maps.Map, columnNames []string, err error) {
-	rawRows, err := this.rawDB.Query(query, args...)
+	return this.FindOnesContext(context.Background(), query, args...)
+}
+
+// FindOnesContext 使用上下文查询一组数据
+func (this *DB) FindOnesContext(ctx context.Context, query string, args ...any) (ones []maps.Map, columnNames []string, err error) {
+	rawRows, err := this.rawDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -365,7 +414,12 @@ func (this *DB) FindOnes(query string, args ...any) (ones []maps.Map, columnName
 }
 
 func (this *DB) FindPreparedOnes(query string, args ...any) (results []maps.Map, columnNames []string, err error) {
-	stmt, cached, err := this.PrepareOnce(query)
+	return this.FindPreparedOnesContext(context.Background(), query, args...)
+}
+
+// FindPreparedOnesContext 使用上下文和可重用的语句查询一组数据
+func (this *DB) FindPreparedOnesContext(ctx context.Context, query string, args ...any) (results []maps.Map, columnNames []string, err error) {
+	stmt, cached, err := this.PrepareOnceContext(ctx, query)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -376,11 +430,16 @@ func (this *DB) FindPreparedOnes(query string, args ...any) (results []maps.Map,
 		}()
 	}
 
-	return stmt.FindOnes(args...)
+	return stmt.FindOnesContext(ctx, args...)
 }
 
 func (this *DB) FindOne(query string, args ...any) (maps.Map, error) {
-	rawRows, err := this.rawDB.Query(query, args...)
+	return this.FindOneContext(context.Background(), query, args...)
+}
+
+// FindOneContext 使用上下文查询一行数据
+func (this *DB) FindOneContext(ctx context.Context, query string, args ...any) (maps.Map, error) {
+	rawRows, err := this.rawDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -394,7 +453,12 @@ func (this *DB) FindOne(query string, args ...any) (maps.Map, error) {
 }
 
 func (this *DB) FindCol(colIndex int, query string, args ...any) (any, error) {
-	rawRows, err := this.rawDB.Query(query, args...)
+	return this.FindColContext(context.Background(), colIndex, query, args...)
+}
+
+// FindColContext 使用上下文查询某一列的值
+func (this *DB) FindColContext(ctx context.Context, colIndex int, query string, args ...any) (any, error) {
+	rawRows, err := this.rawDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -804,6 +868,14 @@ func (this *DB) Raw() *sql.DB {
 	return this.rawDB
 }
 
+// Ping 检查数据库连接是否可用
+func (this *DB) Ping(ctx context.Context) error {
+	if this.rawDB == nil {
+		return errors.New("database '" + this.id + "' has not been initialized")
+	}
+	return this.rawDB.PingContext(ctx)
+}
+
 func (this *DB) queryMaxPreparedStmtCount() int {
 	// query global variable
 	var row = this.rawDB.QueryRow("SELECT @@max_prepared_stmt_count")
@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -74,6 +75,7 @@ type Query struct {
 	db  *DB
 	tx  *Tx
 	dao *DAOObject
+	ctx context.Context
 
 	model  *Model
 	table  string
@@ -191,6 +193,12 @@ func (this *Query) Tx(tx *Tx) *Query {
 	return this
 }
 
+// Context 设置上下文，上下文取消或超时后正在执行的查询会被中止
+func (this *Query) Context(ctx context.Context) *Query {
+	this.ctx = ctx
+	return this
+}
+
 // DAO 设置DAO
 func (this *Query) DAO(dao *DAOObject) *Query {
 	this.dao = dao
@@ -888,7 +896,7 @@ func (this *Query) FindOnes() (ones []maps.Map, columnNames []string, err error)
 	}
 
 	if this.canReuse {
-		stmt, cached, prepareErr := this.executor().PrepareOnce(sqlString)
+		stmt, cached, prepareErr := this.prepareOnce(sqlString)
 		if prepareErr != nil {
 			return nil, nil, prepareErr
 		}
@@ -898,9 +906,9 @@ func (this *Query) FindOnes() (ones []maps.Map, columnNames []string, err error)
 			}()
 		}
 
-		ones, columnNames, err = stmt.FindOnes(this.params...)
+		ones, columnNames, err = stmt.FindOnesContext(this.context(), this.params...)
 	} else {
-		ones, columnNames, err = this.executor().FindOnes(sqlString, this.params...)
+		ones, columnNames, err = this.findOnes(sqlString, this.params...)
 	}
 	if err != nil {
 		return nil, nil, err
@@ -1246,7 +1254,7 @@ func (this *Query) Exec() (*Result, error) {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return nil, err
 		}
@@ -1256,9 +1264,9 @@ func (this *Query) Exec() (*Result, error) {
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return nil, err
@@ -1284,7 +1292,7 @@ func (this *Query) Replace() (rowsAffected int64, lastInsertId int64, err error)
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return 0, 0, err
 		}
@@ -1294,9 +1302,9 @@ func (this *Query) Replace() (rowsAffected int64, lastInsertId int64, err error)
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return 0, 0, err
@@ -1330,7 +1338,7 @@ func (this *Query) Insert() (lastInsertId int64, err error) {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return 0, err
 		}
@@ -1340,9 +1348,9 @@ func (this *Query) Insert() (lastInsertId int64, err error) {
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return 0, err
@@ -1375,7 +1383,7 @@ func (this *Query) Update() (rowsAffected int64, err error) {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return 0, err
 		}
@@ -1385,9 +1393,9 @@ func (this *Query) Update() (rowsAffected int64, err error) {
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return 0, err
@@ -1418,7 +1426,7 @@ func (this *Query) UpdateQuickly() error {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return err
 		}
@@ -1428,9 +1436,9 @@ func (this *Query) UpdateQuickly() error {
 			}()
 		}
 
-		_, err = stmt.Exec(this.params...)
+		_, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		_, err = this.executor().Exec(sqlString, this.params...)
+		_, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return err
@@ -1472,7 +1480,7 @@ func (this *Query) InsertOrUpdate(insertingValues maps.Map, updatingValues maps.
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return 0, 0, err
 		}
@@ -1482,9 +1490,9 @@ func (this *Query) InsertOrUpdate(insertingValues maps.Map, updatingValues maps.
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return 0, 0, err
@@ -1537,7 +1545,7 @@ func (this *Query) InsertOrUpdateQuickly(insertingValues maps.Map, updatingValue
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return err
 		}
@@ -1547,9 +1555,9 @@ func (this *Query) InsertOrUpdateQuickly(insertingValues maps.Map, updatingValue
 			}()
 		}
 
-		_, err = stmt.Exec(this.params...)
+		_, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		_, err = this.executor().Exec(sqlString, this.params...)
+		_, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return err
@@ -1576,7 +1584,7 @@ func (this *Query) Delete() (rowsAffected int64, err error) {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return 0, err
 		}
@@ -1586,9 +1594,9 @@ func (this *Query) Delete() (rowsAffected int64, err error) {
 			}()
 		}
 
-		result, err = stmt.Exec(this.params...)
+		result, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		result, err = this.executor().Exec(sqlString, this.params...)
+		result, err = this.exec(sqlString, this.params...)
 	}
 	if err != nil {
 		return 0, err
@@ -1619,7 +1627,7 @@ func (this *Query) DeleteQuickly() error {
 	if this.canReuse {
 		var stmt *Stmt
 		var cached bool
-		stmt, cached, err = this.executor().PrepareOnce(sqlString)
+		stmt, cached, err = this.prepareOnce(sqlString)
 		if err != nil {
 			return err
 		}
@@ -1629,9 +1637,9 @@ func (this *Query) DeleteQuickly() error {
 			}()
 		}
 
-		_, err = stmt.Exec(this.params...)
+		_, err = stmt.ExecContext(this.context(), this.params...)
 	} else {
-		_, err = this.executor().Exec(sqlString, this.params...)
+		_, err = this.exec(sqlString, this.params...)
 	}
 	return err
 }
@@ -1840,6 +1848,14 @@ func (this *Query) copyModelValue(valueType reflect.Type, data maps.Map) any {
 	return pointerValue.Interface()
 }
 
+// 获取上下文
+func (this *Query) context() context.Context {
+	if this.ctx == nil {
+		return context.Background()
+	}
+	return this.ctx
+}
+
 // 获取Executor
 func (this *Query) executor() SQLExecutor {
 	if this.tx != nil {
@@ -1848,6 +1864,45 @@ func (this *Query) executor() SQLExecutor {
 	return this.db
 }
 
+// 使用上下文Prepare，Executor不支持上下文时使用 PrepareOnce()
+func (this *Query) prepareOnce(sqlString string) (stmt *Stmt, cached bool, err error) {
+	contextExecutor, ok := this.executor().(SQLContextExecutor)
+	if ok {
+		return contextExecutor.PrepareOnceContext(this.context(), sqlString)
+	}
+	err = this.context().Err()
+	if err != nil {
+		return nil, false, err
+	}
+	return this.executor().PrepareOnce(sqlString)
+}
+
+// 使用上下文执行SQL，Executor不支持上下文时使用 Exec()
+func (this *Query) exec(sqlString string, args ...any) (result sql.Result, err error) {
+	contextExecutor, ok := this.executor().(SQLContextExecutor)
+	if ok {
+		return contextExecutor.ExecContext(this.context(), sqlString, args...)
+	}
+	err = this.context().Err()
+	if err != nil {
+		return nil, err
+	}
+	return this.executor().Exec(sqlString, args...)
+}
+
+// 使用上下文查询数据，Executor不支持上下文时使用 FindOnes()
+func (this *Query) findOnes(sqlString string, args ...any) (ones []maps.Map, columnNames []string, err error) {
+	contextExecutor, ok := this.executor().(SQLContextExecutor)
+	if ok {
+		return contextExecutor.FindOnesContext(this.context(), sqlString, args...)
+	}
+	err = this.context().Err()
+	if err != nil {
+		return nil, nil, err
+	}
+	return this.executor().FindOnes(sqlString, args...)
+}
+
 // 判断某个字符串是否为关键词
 func (this *Query) isKeyword(s string) bool {
 	for _, r := range s {
@@ -1,7 +1,11 @@
 package dbs
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/assert"
 	"github.com/iwind/TeaGo/logs"
@@ -687,3 +691,44 @@ func outputQueryResult(t *testing.T, result any) {
 	}
 	t.Log(string(jsonBytes))
 }
+
+// 不连接任何数据库的驱动，用来测试不需要数据库的逻辑
+type testNoDBDriver struct {
+}
+
+func (this *testNoDBDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("no database")
+}
+
+func init() {
+	sql.Register("teago_nodb", &testNoDBDriver{})
+}
+
+func TestQuery_Context(t *testing.T) {
+	db, err := NewInstanceFromConfig(&DBConfig{
+		Driver: "teago_nodb",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	// 已经取消的上下文不会再连接数据库
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = NewQuery(nil).DB(db).Table("users").Context(ctx).FindOnes()
+	if err != context.Canceled {
+		t.Fatal("expect context.Canceled, got", err)
+	}
+	_, err = NewQuery(nil).DB(db).Table("users").Reuse(false).Context(ctx).Set("name", "lu").Insert()
+	if err != context.Canceled {
+		t.Fatal("expect context.Canceled, got", err)
+	}
+	_, err = db.FindOneContext(ctx, "SELECT 1")
+	if err != context.Canceled {
+		t.Fatal("expect context.Canceled, got", err)
+	}
+}
@@ -2,11 +2,13 @@ package dbs
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 var readyCallbacks = []func(){}
 var readyDoneCallbacks = []func(){}
 var readyLocker = sync.Mutex{}
+var isReady int32 = 0
 
 // OnReady 添加Ready的回调函数
 func OnReady(f func()) {
@@ -35,5 +37,11 @@ func NotifyReady() {
 	for _, f := range readyDoneCallbacks {
 		f()
 	}
+	atomic.StoreInt32(&isReady, 1)
 	readyLocker.Unlock()
 }
+
+// IsReady 判断是否已经调用过 NotifyReady()，并且所有回调函数都已执行完成
+func IsReady() bool {
+	return atomic.LoadInt32(&isReady) == 1
+}
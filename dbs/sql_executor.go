@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"context"
 	"database/sql"
 	"github.com/iwind/TeaGo/maps"
 )
@@ -13,3 +14,15 @@ type SQLExecutor interface {
 
 	FindOnes(query string, args ...any) (ones []maps.Map, columnNames []string, err error)
 }
+
+// SQLContextExecutor 支持上下文的Executor，Query在设置了上下文时优先使用这些方法
+type SQLContextExecutor interface {
+	SQLExecutor
+
+	// PrepareOnceContext 使用上下文的可重用的Prepare
+	PrepareOnceContext(ctx context.Context, query string) (stmt *Stmt, cached bool, err error)
+
+	ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error)
+
+	FindOnesContext(ctx context.Context, query string, args ...any) (ones []maps.Map, columnNames []string, err error)
+}
@@ -1,6 +1,7 @@
 package dbs
 
 import (
+	"context"
 	"database/sql"
 	"github.com/iwind/TeaGo/maps"
 )
@@ -20,14 +21,24 @@ func NewStmt(stmt *sql.Stmt) *Stmt {
 }
 
 func (this *Stmt) Query(args ...any) (*sql.Rows, error) {
+	return this.QueryContext(context.Background(), args...)
+}
+
+// QueryContext 使用上下文执行查询，上下文取消或超时后会中止查询
+func (this *Stmt) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
 	this.accessAt = unixTime()
-	return this.rawStmt.Query(args...)
+	return this.rawStmt.QueryContext(ctx, args...)
 }
 
 func (this *Stmt) FindOnes(args ...any) (ones []maps.Map, columnNames []string, err error) {
+	return this.FindOnesContext(context.Background(), args...)
+}
+
+// FindOnesContext 使用上下文查询一组数据
+func (this *Stmt) FindOnesContext(ctx context.Context, args ...any) (ones []maps.Map, columnNames []string, err error) {
 	this.accessAt = unixTime()
 
-	rawRows, err := this.rawStmt.Query(args...)
+	rawRows, err := this.rawStmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -47,9 +58,14 @@ func (this *Stmt) FindOnes(args ...any) (ones []maps.Map, columnNames []string,
 }
 
 func (this *Stmt) FindOne(args ...any) (one maps.Map, err error) {
+	return this.FindOneContext(context.Background(), args...)
+}
+
+// FindOneContext 使用上下文查询一行数据
+func (this *Stmt) FindOneContext(ctx context.Context, args ...any) (one maps.Map, err error) {
 	this.accessAt = unixTime()
 
-	rawRows, err := this.rawStmt.Query(args...)
+	rawRows, err := this.rawStmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +79,14 @@ func (this *Stmt) FindOne(args ...any) (one maps.Map, err error) {
 }
 
 func (this *Stmt) FindCol(colIndex int, args ...any) (colValue any, err error) {
+	return this.FindColContext(context.Background(), colIndex, args...)
+}
+
+// FindColContext 使用上下文查询某一列的值
+func (this *Stmt) FindColContext(ctx context.Context, colIndex int, args ...any) (colValue any, err error) {
 	this.accessAt = unixTime()
 
-	rawRows, err := this.rawStmt.Query(args...)
+	rawRows, err := this.rawStmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +100,13 @@ func (this *Stmt) FindCol(colIndex int, args ...any) (colValue any, err error) {
 }
 
 func (this *Stmt) Exec(args ...any) (sql.Result, error) {
+	return this.ExecContext(context.Background(), args...)
+}
+
+// ExecContext 使用上下文执行语句
+func (this *Stmt) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
 	this.accessAt = unixTime()
-	return this.rawStmt.Exec(args...)
+	return this.rawStmt.ExecContext(ctx, args...)
 }
 
 // Close 关闭
@@ -3,6 +3,7 @@
 package dbs
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/go-sql-driver/mysql"
@@ -21,7 +22,7 @@ func IsPrepareError(err error) bool {
 }
 
 type sqlPreparer interface {
-	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
 var timestamp = time.Now().Unix()
@@ -67,6 +68,11 @@ func (this *StmtManager) SetMaxCount(count int) {
 
 // Prepare statement
 func (this *StmtManager) Prepare(preparer sqlPreparer, querySQL string) (*Stmt, error) {
+	return this.PrepareContext(context.Background(), preparer, querySQL)
+}
+
+// PrepareContext prepare statement with context
+func (this *StmtManager) PrepareContext(ctx context.Context, preparer sqlPreparer, querySQL string) (*Stmt, error) {
 	if this.isClosed {
 		return nil, errors.New("prepare failed: connection is closed")
 	}
@@ -75,7 +81,7 @@ func (this *StmtManager) Prepare(preparer sqlPreparer, querySQL string) (*Stmt,
 		logs.Println("[DB]prepare " + querySQL)
 	}
 
-	sqlStmt, err := preparer.Prepare(querySQL)
+	sqlStmt, err := preparer.PrepareContext(ctx, querySQL)
 	if err != nil {
 		if IsPrepareError(err) {
 			// lock for concurrent operation
@@ -84,7 +90,7 @@ func (this *StmtManager) Prepare(preparer sqlPreparer, querySQL string) (*Stmt,
 			this.locker.Unlock()
 
 			// retry
-			sqlStmt, err = preparer.Prepare(querySQL)
+			sqlStmt, err = preparer.PrepareContext(ctx, querySQL)
 		}
 		if err != nil {
 			return nil, err
@@ -96,6 +102,12 @@ func (this *StmtManager) Prepare(preparer sqlPreparer, querySQL string) (*Stmt,
 
 // PrepareOnce prepare statement for reuse
 func (this *StmtManager) PrepareOnce(preparer sqlPreparer, querySQL string, parentId int64) (resultStmt *Stmt, wasCached bool, err error) {
+	return this.PrepareOnceContext(context.Background(), preparer, querySQL, parentId)
+}
+
+// PrepareOnceContext prepare statement for reuse with context
+// the context is only used while preparing, cached statement is not bound to it
+func (this *StmtManager) PrepareOnceContext(ctx context.Context, preparer sqlPreparer, querySQL string, parentId int64) (resultStmt *Stmt, wasCached bool, err error) {
 	var cacheKey string
 	if parentId == 0 {
 		cacheKey = "0$" + querySQL
@@ -117,14 +129,14 @@ func (this *StmtManager) PrepareOnce(preparer sqlPreparer, querySQL string, pare
 		logs.Println("[DB]prepare " + querySQL)
 	}
 
-	sqlStmt, err := preparer.Prepare(querySQL)
+	sqlStmt, err := preparer.PrepareContext(ctx, querySQL)
 	if err != nil {
 		if IsPrepareError(err) {
 			// purge once
 			this.purge()
 
 			// retry
-			sqlStmt, err = preparer.Prepare(querySQL)
+			sqlStmt, err = preparer.PrepareContext(ctx, querySQL)
 		}
 		if err != nil {
 			return nil, false, err
@@ -30,6 +30,11 @@ func (this *Tx) Exec(query string, args ...any) (sql.Result, error) {
 	return this.rawTx.Exec(query, args...)
 }
 
+// ExecContext 使用上下文执行语句，上下文取消或超时后会中止执行
+func (this *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return this.rawTx.ExecContext(ctx, query, args...)
+}
+
 func (this *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	return this.rawTx.QueryContext(ctx, query, args...)
 }
@@ -50,12 +55,27 @@ func (this *Tx) Prepare(query string) (*Stmt, error) {
 	return this.db.stmtManager.Prepare(this.rawTx, query)
 }
 
+// PrepareContext 使用上下文Prepare语句
+func (this *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	return this.db.stmtManager.PrepareContext(ctx, this.rawTx, query)
+}
+
 func (this *Tx) PrepareOnce(query string) (*Stmt, bool, error) {
 	return this.db.stmtManager.PrepareOnce(this.rawTx, query, this.id)
 }
 
+// PrepareOnceContext 使用上下文Prepare可重用的语句
+func (this *Tx) PrepareOnceContext(ctx context.Context, query string) (*Stmt, bool, error) {
+	return this.db.stmtManager.PrepareOnceContext(ctx, this.rawTx, query, this.id)
+}
+
 func (this *Tx) FindOnes(query string, args ...any) (ones []maps.Map, columnNames []string, err error) {
-	rawRows, err := this.rawTx.Query(query, args...)
+	return this.FindOnesContext(context.Background(), query, args...)
+}
+
+// FindOnesContext 使用上下文查询一组数据
+func (this *Tx) FindOnesContext(ctx context.Context, query string, args ...any) (ones []maps.Map, columnNames []string, err error) {
+	rawRows, err := this.rawTx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -75,7 +95,12 @@ func (this *Tx) FindOnes(query string, args ...any) (ones []maps.Map, columnName
 }
 
 func (this *Tx) FindOne(query string, args ...any) (one maps.Map, err error) {
-	rawRows, err := this.rawTx.Query(query, args...)
+	return this.FindOneContext(context.Background(), query, args...)
+}
+
+// FindOneContext 使用上下文查询一行数据
+func (this *Tx) FindOneContext(ctx context.Context, query string, args ...any) (one maps.Map, err error) {
+	rawRows, err := this.rawTx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +113,12 @@ func (this *Tx) FindOne(query string, args ...any) (one maps.Map, err error) {
 }
 
 func (this *Tx) FindCol(colIndex int, query string, args ...any) (colValue any, err error) {
-	rawRows, err := this.rawTx.Query(query, args...)
+	return this.FindColContext(context.Background(), colIndex, query, args...)
+}
+
+// FindColContext 使用上下文查询某一列的值
+func (this *Tx) FindColContext(ctx context.Context, colIndex int, query string, args ...any) (colValue any, err error) {
+	rawRows, err := this.rawTx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1,12 +1,19 @@
 package TeaGo
 
 import (
-	"time"
-	"net/http"
-	"github.com/iwind/TeaGo/logs"
+	"compress/gzip"
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
 	"io"
-	"os"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type LogWriter interface {
@@ -16,11 +23,22 @@ type LogWriter interface {
 	Close()
 }
 
+// LogFlusher 可以将缓冲中的日志立即写出的LogWriter，服务停止时会被调用
+type LogFlusher interface {
+	Flush()
+}
+
 type DefaultLogWriter struct {
+	Formatter AccessLogFormatter // 访问日志格式，默认为带颜色的多行格式
+
 	queue chan string
 }
 
 func (this *DefaultLogWriter) Init() {
+	if this.Formatter == nil {
+		this.Formatter = &DefaultAccessLogFormatter{Colored: true}
+	}
+
 	this.queue = make(chan string, 10000)
 	go func() {
 		for {
@@ -31,59 +49,286 @@ func (this *DefaultLogWriter) Init() {
 }
 
 func (this *DefaultLogWriter) Print(t time.Time, response *responseWriter, request *http.Request) {
-	var tag = "ok"
-	if response.status >= 400 {
-		tag = "error"
-	}
-
-	this.queue <- logs.Sprintf("\n  <"+tag+">Request:\"%s %s %s\"</"+tag+">\n  RemoteAddr:%s\n  Status:%d\n  Bytes:%d\n  Referer:\"%s\"\n  UserAgent:\"%s\"\n  Cost:%.3fms",
-		request.Method, request.RequestURI, request.Proto, request.RemoteAddr, response.status, response.bytes,
-		request.Referer(), request.UserAgent(), float32(time.Since(t).Nanoseconds())/1000000)
+	this.queue <- this.Formatter.Format(newAccessLog(t, response, request))
 }
 
 func (this *DefaultLogWriter) Write(logMessage string) {
 	this.queue <- logMessage
 }
 
+func (this *DefaultLogWriter) Flush() {
+	for {
+		select {
+		case msg := <-this.queue:
+			log.Println(msg)
+		default:
+			return
+		}
+	}
+}
+
 func (this *DefaultLogWriter) Close() {
 
 }
 
+// FileLogWriter 将日志写入文件，支持按尺寸或者按天轮转
 type FileLogWriter struct {
-	File       string
-	fileWriter io.Writer
+	File      string             // 日志文件，相对路径以 Tea.Root 为基础，默认为 logs/server.log
+	Formatter AccessLogFormatter // 访问日志格式，默认为不带颜色的多行格式
+
+	MaxSize  int64 // 单个文件最大尺寸（字节），超过后轮转，0表示不限制
+	Daily    bool  // 是否每天轮转
+	MaxFiles int   // 保留的轮转文件数量，0表示不限制
+	Compress bool  // 是否使用gzip压缩轮转后的文件
+
+	fileWriter *os.File
+	size       int64
+	openedAt   time.Time
+	locker     sync.Mutex
+
+	rotateLocker sync.Mutex // 保证压缩和清理轮转文件的任务依次执行
 }
 
 func (this *FileLogWriter) Init() {
 	if len(this.File) == 0 {
 		this.File = "logs/server.log"
 	}
-	file, err := os.OpenFile(this.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	if !filepath.IsAbs(this.File) {
+		this.File = Tea.Root + Tea.DS + filepath.FromSlash(this.File)
+	}
+	if this.Formatter == nil {
+		this.Formatter = &DefaultAccessLogFormatter{}
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	err := this.open()
 	if err != nil {
-		log.Println("can not write file to 'logs/access.log':" + err.Error())
-		return
+		log.Println("can not write file to '" + this.File + "': " + err.Error())
 	}
-	this.fileWriter = file
 }
 
 func (this *FileLogWriter) Print(t time.Time, response *responseWriter, request *http.Request) {
-	logs.Printf("\n  Request:\"%s %s %s\"\n  RemoteAddr:%s\n  Status:%d\n  Bytes:%d\n  Referer:\"%s\"\n  UserAgent:\"%s\"\n  Cost:%.3fms",
-		request.Method, request.RequestURI, request.Proto, request.RemoteAddr, response.status, response.bytes,
-		request.Referer(), request.UserAgent(), float32(time.Since(t).Nanoseconds())/1000000)
+	this.Write(this.Formatter.Format(newAccessLog(t, response, request)))
 }
 
 func (this *FileLogWriter) Write(logMessage string) {
+	if !strings.HasSuffix(logMessage, "\n") {
+		logMessage += "\n"
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
 	if this.fileWriter == nil {
 		return
 	}
-	_, err := this.fileWriter.Write([]byte(logMessage))
+
+	// 轮转
+	if (this.MaxSize > 0 && this.size > 0 && this.size+int64(len(logMessage)) > this.MaxSize) ||
+		(this.Daily && !sameDay(this.openedAt, time.Now())) {
+		err := this.rotate()
+		if err != nil {
+			log.Println("Error: rotate log file: " + err.Error())
+		}
+		if this.fileWriter == nil {
+			return
+		}
+	}
+
+	n, err := this.fileWriter.WriteString(logMessage)
+	this.size += int64(n)
 	if err != nil {
 		log.Println("Error:", err.Error())
 	}
 }
 
+func (this *FileLogWriter) Flush() {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.fileWriter != nil {
+		_ = this.fileWriter.Sync()
+	}
+}
+
 func (this *FileLogWriter) Close() {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.fileWriter != nil {
+		_ = this.fileWriter.Close()
+		this.fileWriter = nil
+	}
+}
+
+// 打开日志文件
+func (this *FileLogWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(this.File), 0777)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(this.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	this.fileWriter = file
+	this.size = 0
+	this.openedAt = time.Now()
+
+	stat, err := file.Stat()
+	if err == nil {
+		this.size = stat.Size()
+		if this.size > 0 {
+			// 按天轮转时，从文件的修改时间判断内容属于哪一天
+			this.openedAt = stat.ModTime()
+		}
+	}
+	return nil
+}
+
+// 轮转日志文件：将当前文件改名，然后重新打开
+func (this *FileLogWriter) rotate() error {
 	if this.fileWriter != nil {
-		this.fileWriter.(*os.File).Close()
+		_ = this.fileWriter.Close()
+		this.fileWriter = nil
+	}
+
+	var suffix string
+	if this.Daily {
+		suffix = this.openedAt.Format("20060102")
+	} else {
+		suffix = time.Now().Format("20060102150405")
+	}
+	var rotatedFile = this.File + "." + suffix
+	for i := 1; fileExists(rotatedFile) || fileExists(rotatedFile+".gz"); i++ {
+		rotatedFile = this.File + "." + suffix + "." + strconv.Itoa(i)
+	}
+
+	err := os.Rename(this.File, rotatedFile)
+	if err != nil && !os.IsNotExist(err) {
+		// 改名失败时继续写入原文件
+		_ = this.open()
+		return err
+	}
+
+	err = this.open()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		this.rotateLocker.Lock()
+		defer this.rotateLocker.Unlock()
+
+		// 文件可能已经被之前的任务清理
+		if this.Compress && fileExists(rotatedFile) {
+			err := gzipFile(rotatedFile)
+			if err != nil {
+				log.Println("Error: compress log file: " + err.Error())
+			}
+		}
+		this.removeOldFiles()
+	}()
+
+	return nil
+}
+
+// 删除超出数量的轮转文件
+func (this *FileLogWriter) removeOldFiles() {
+	if this.MaxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(this.File + ".*")
+	if err != nil {
+		return
+	}
+
+	type rotatedFile struct {
+		path       string
+		modifiedAt time.Time
+	}
+	var rotatedFiles = []rotatedFile{}
+	for _, match := range matches {
+		// 正在压缩的临时文件
+		if strings.HasSuffix(match, ".gz.tmp") {
+			continue
+		}
+		stat, err := os.Stat(match)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		rotatedFiles = append(rotatedFiles, rotatedFile{
+			path:       match,
+			modifiedAt: stat.ModTime(),
+		})
+	}
+	if len(rotatedFiles) <= this.MaxFiles {
+		return
+	}
+
+	sort.Slice(rotatedFiles, func(i, j int) bool {
+		return rotatedFiles[i].modifiedAt.After(rotatedFiles[j].modifiedAt)
+	})
+	for _, file := range rotatedFiles[this.MaxFiles:] {
+		_ = os.Remove(file.path)
+	}
+}
+
+// 使用gzip压缩文件，压缩后删除原文件
+func gzipFile(path string) error {
+	reader, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var tmpPath = path + ".gz.tmp"
+	writer, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	_, err = io.Copy(gzipWriter, reader)
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	// 保留原文件的修改时间，以便按时间清理
+	stat, err := reader.Stat()
+	if err == nil {
+		_ = os.Chtimes(tmpPath, stat.ModTime(), stat.ModTime())
+	}
+
+	err = os.Rename(tmpPath, path+".gz")
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.New("rename '" + tmpPath + "': " + err.Error())
+	}
+	_ = reader.Close()
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sameDay(t1 time.Time, t2 time.Time) bool {
+	y1, m1, d1 := t1.Date()
+	y2, m2, d2 := t2.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
 }
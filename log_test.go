@@ -0,0 +1,117 @@
+package TeaGo
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogFormatter(t *testing.T) {
+	var request = httptest.NewRequest("GET", "/hello?name=lu", nil)
+	request.RemoteAddr = "192.168.1.100:50000"
+	request.Header.Set("User-Agent", "curl/8.0")
+	request.Header.Set("X-Request-Id", "abc")
+	request.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+
+	var response = newResponseWriter(httptest.NewRecorder())
+	response.status = 404
+	response.bytes = 19
+
+	var accessLog = newAccessLog(time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC), response, request)
+	for format, expected := range map[string]string{
+		"common":   `192.168.1.100 - - [10/Oct/2000:13:55:36 +0000] "GET /hello?name=lu HTTP/1.1" 404 19`,
+		"combined": `192.168.1.100 - - [10/Oct/2000:13:55:36 +0000] "GET /hello?name=lu HTTP/1.1" 404 19 "" "curl/8.0"`,
+		"${requestId} ${upstreamIP} ${status} ${header.User-Agent} ${unknown}": "abc 10.0.0.1 404 curl/8.0 ${unknown}",
+	} {
+		result := NewAccessLogFormatter(format).Format(accessLog)
+		if result != expected {
+			t.Fatal("'" + format + "': expected '" + expected + "', but got '" + result + "'")
+		}
+	}
+
+	var jsonLine = NewAccessLogFormatter("json").Format(accessLog)
+	if !strings.Contains(jsonLine, `"requestId":"abc"`) || !strings.Contains(jsonLine, `"status":404`) {
+		t.Fatal("unexpected json:", jsonLine)
+	}
+	t.Log(jsonLine)
+}
+
+func TestFileLogWriter_Rotate(t *testing.T) {
+	var file = t.TempDir() + "/access.log"
+	var writer = &FileLogWriter{
+		File:     file,
+		MaxSize:  100,
+		MaxFiles: 2,
+		Compress: true,
+	}
+	writer.Init()
+	defer writer.Close()
+
+	for i := 0; i < 5; i++ {
+		writer.Write(strings.Repeat("a", 60))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 等待压缩和清理
+	var matches []string
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		matches, _ = filepath.Glob(file + ".*")
+		if len(matches) == 2 && strings.HasSuffix(matches[0], ".gz") && strings.HasSuffix(matches[1], ".gz") {
+			break
+		}
+	}
+	if len(matches) != 2 {
+		t.Fatal("expected 2 rotated files, but got", matches)
+	}
+	for _, match := range matches {
+		if !strings.HasSuffix(match, ".gz") {
+			t.Fatal("rotated file should be compressed:", match)
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 61 {
+		t.Fatal("unexpected size of current file:", len(data))
+	}
+}
+
+func TestFileLogWriter_RotateConcurrently(t *testing.T) {
+	var file = t.TempDir() + "/access.log"
+	var writer = &FileLogWriter{
+		File:     file,
+		MaxSize:  100,
+		MaxFiles: 2,
+		Compress: true,
+	}
+	writer.Init()
+	defer writer.Close()
+
+	// 连续轮转，压缩和清理任务会同时排队
+	for i := 0; i < 20; i++ {
+		writer.Write(strings.Repeat("a", 60))
+	}
+
+	var matches []string
+	for i := 0; i < 100; i++ {
+		time.Sleep(20 * time.Millisecond)
+		matches, _ = filepath.Glob(file + ".*")
+		if len(matches) == 2 && strings.HasSuffix(matches[0], ".gz") && strings.HasSuffix(matches[1], ".gz") {
+			break
+		}
+	}
+	if len(matches) != 2 {
+		t.Fatal("expected 2 rotated files, but got", matches)
+	}
+	for _, match := range matches {
+		if !strings.HasSuffix(match, ".gz") {
+			t.Fatal("rotated file should be compressed:", match)
+		}
+	}
+}
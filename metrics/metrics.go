@@ -0,0 +1,377 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefaultBuckets 默认的直方图区间，单位为秒
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metric 指标
+type Metric interface {
+	Name() string
+	Help() string
+	Type() string
+
+	// 输出所有样本
+	write(builder *strings.Builder)
+}
+
+// Sample 样本，用于 GaugeFunc 等在输出时采集的指标
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// 指标的公共部分
+type metricDesc struct {
+	name       string
+	help       string
+	labelNames []string
+}
+
+func (this *metricDesc) Name() string {
+	return this.name
+}
+
+func (this *metricDesc) Help() string {
+	return this.help
+}
+
+func (this *metricDesc) labels() []string {
+	return this.labelNames
+}
+
+// 生成标签值对应的键
+func (this *metricDesc) key(labelValues []string) (string, error) {
+	if len(labelValues) != len(this.labelNames) {
+		return "", errors.New("metrics: '" + this.name + "' expects " + itoa(len(this.labelNames)) + " label values, but got " + itoa(len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff"), nil
+}
+
+// Counter 计数器，只能增加
+type Counter struct {
+	metricDesc
+
+	values map[string]*counterValue
+	locker sync.RWMutex
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounter 创建计数器
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	return &Counter{
+		metricDesc: metricDesc{name: name, help: help, labelNames: labelNames},
+		values:     map[string]*counterValue{},
+	}
+}
+
+func (this *Counter) Type() string {
+	return TypeCounter
+}
+
+// Inc 增加1
+func (this *Counter) Inc(labelValues ...string) {
+	this.Add(1, labelValues...)
+}
+
+// Add 增加某个值，值不能为负数
+func (this *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	key, err := this.key(labelValues)
+	if err != nil {
+		return
+	}
+
+	this.locker.Lock()
+	value, ok := this.values[key]
+	if !ok {
+		value = &counterValue{labelValues: append([]string{}, labelValues...)}
+		this.values[key] = value
+	}
+	value.value += delta
+	this.locker.Unlock()
+}
+
+// Value 读取当前值
+func (this *Counter) Value(labelValues ...string) float64 {
+	key, err := this.key(labelValues)
+	if err != nil {
+		return 0
+	}
+
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	value, ok := this.values[key]
+	if !ok {
+		return 0
+	}
+	return value.value
+}
+
+func (this *Counter) write(builder *strings.Builder) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	for _, key := range sortedCounterKeys(this.values) {
+		value := this.values[key]
+		writeSample(builder, this.name, this.labelNames, value.labelValues, "", "", value.value)
+	}
+}
+
+// Gauge 可增可减的数值
+type Gauge struct {
+	metricDesc
+
+	values map[string]*counterValue
+	locker sync.RWMutex
+}
+
+// NewGauge 创建数值指标
+func NewGauge(name string, help string, labelNames ...string) *Gauge {
+	return &Gauge{
+		metricDesc: metricDesc{name: name, help: help, labelNames: labelNames},
+		values:     map[string]*counterValue{},
+	}
+}
+
+func (this *Gauge) Type() string {
+	return TypeGauge
+}
+
+// Set 设置值
+func (this *Gauge) Set(value float64, labelValues ...string) {
+	this.update(labelValues, func(v *counterValue) {
+		v.value = value
+	})
+}
+
+// Add 增加值，可以为负数
+func (this *Gauge) Add(delta float64, labelValues ...string) {
+	this.update(labelValues, func(v *counterValue) {
+		v.value += delta
+	})
+}
+
+// Inc 增加1
+func (this *Gauge) Inc(labelValues ...string) {
+	this.Add(1, labelValues...)
+}
+
+// Dec 减少1
+func (this *Gauge) Dec(labelValues ...string) {
+	this.Add(-1, labelValues...)
+}
+
+// Value 读取当前值
+func (this *Gauge) Value(labelValues ...string) float64 {
+	key, err := this.key(labelValues)
+	if err != nil {
+		return 0
+	}
+
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	value, ok := this.values[key]
+	if !ok {
+		return 0
+	}
+	return value.value
+}
+
+func (this *Gauge) update(labelValues []string, f func(v *counterValue)) {
+	key, err := this.key(labelValues)
+	if err != nil {
+		return
+	}
+
+	this.locker.Lock()
+	value, ok := this.values[key]
+	if !ok {
+		value = &counterValue{labelValues: append([]string{}, labelValues...)}
+		this.values[key] = value
+	}
+	f(value)
+	this.locker.Unlock()
+}
+
+func (this *Gauge) write(builder *strings.Builder) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	for _, key := range sortedCounterKeys(this.values) {
+		value := this.values[key]
+		writeSample(builder, this.name, this.labelNames, value.labelValues, "", "", value.value)
+	}
+}
+
+// GaugeFunc 在输出时通过函数采集的指标
+type GaugeFunc struct {
+	metricDesc
+
+	metricType string
+	collect    func() []Sample
+}
+
+// NewGaugeFunc 创建在输出时采集的数值指标
+func NewGaugeFunc(name string, help string, labelNames []string, collect func() []Sample) *GaugeFunc {
+	return &GaugeFunc{
+		metricDesc: metricDesc{name: name, help: help, labelNames: labelNames},
+		metricType: TypeGauge,
+		collect:    collect,
+	}
+}
+
+// NewCounterFunc 创建在输出时采集的计数器指标，函数返回的值应该只增不减
+func NewCounterFunc(name string, help string, labelNames []string, collect func() []Sample) *GaugeFunc {
+	return &GaugeFunc{
+		metricDesc: metricDesc{name: name, help: help, labelNames: labelNames},
+		metricType: TypeCounter,
+		collect:    collect,
+	}
+}
+
+func (this *GaugeFunc) Type() string {
+	return this.metricType
+}
+
+func (this *GaugeFunc) write(builder *strings.Builder) {
+	for _, sample := range this.collect() {
+		if len(sample.LabelValues) != len(this.labelNames) {
+			continue
+		}
+		writeSample(builder, this.name, this.labelNames, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+// Histogram 直方图
+type Histogram struct {
+	metricDesc
+
+	buckets []float64
+	values  map[string]*histogramValue
+	locker  sync.RWMutex
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64 // 每个区间的数量（不累计）
+	count       uint64
+	sum         float64
+}
+
+// NewHistogram 创建直方图，buckets为空时使用 DefaultBuckets
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &Histogram{
+		metricDesc: metricDesc{name: name, help: help, labelNames: labelNames},
+		buckets:    buckets,
+		values:     map[string]*histogramValue{},
+	}
+}
+
+func (this *Histogram) Type() string {
+	return TypeHistogram
+}
+
+// Observe 记录一个值
+func (this *Histogram) Observe(value float64, labelValues ...string) {
+	key, err := this.key(labelValues)
+	if err != nil {
+		return
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	histogram, ok := this.values[key]
+	if !ok {
+		histogram = &histogramValue{
+			labelValues: append([]string{}, labelValues...),
+			counts:      make([]uint64, len(this.buckets)),
+		}
+		this.values[key] = histogram
+	}
+
+	index := sort.SearchFloat64s(this.buckets, value)
+	if index < len(this.buckets) {
+		histogram.counts[index]++
+	}
+	histogram.count++
+	histogram.sum += value
+}
+
+// Count 读取记录的数量
+func (this *Histogram) Count(labelValues ...string) uint64 {
+	key, err := this.key(labelValues)
+	if err != nil {
+		return 0
+	}
+
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	histogram, ok := this.values[key]
+	if !ok {
+		return 0
+	}
+	return histogram.count
+}
+
+func (this *Histogram) write(builder *strings.Builder) {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	for _, key := range sortedHistogramKeys(this.values) {
+		histogram := this.values[key]
+
+		var cumulative uint64 = 0
+		for index, bound := range this.buckets {
+			cumulative += histogram.counts[index]
+			writeSample(builder, this.name+"_bucket", this.labelNames, histogram.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(builder, this.name+"_bucket", this.labelNames, histogram.labelValues, "le", formatFloat(math.Inf(1)), float64(histogram.count))
+		writeSample(builder, this.name+"_sum", this.labelNames, histogram.labelValues, "", "", histogram.sum)
+		writeSample(builder, this.name+"_count", this.labelNames, histogram.labelValues, "", "", float64(histogram.count))
+	}
+}
+
+func sortedCounterKeys(m map[string]*counterValue) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHistogramKeys(m map[string]*histogramValue) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Text(t *testing.T) {
+	var registry = NewRegistry()
+
+	var counter = NewCounter("app_jobs_total", "Jobs processed.", "queue")
+	counter.Inc("email")
+	counter.Add(2, "email")
+	counter.Inc("sms")
+	counter.Inc() // 标签数量不对，忽略
+
+	var gauge = NewGauge("app_workers", "Running workers.")
+	gauge.Set(3)
+	gauge.Dec()
+
+	var histogram = NewHistogram("app_latency_seconds", "", []float64{0.1, 1}, "path")
+	histogram.Observe(0.05, "/a\"b")
+	histogram.Observe(0.5, "/a\"b")
+	histogram.Observe(3, "/a\"b")
+
+	var gaugeFunc = NewGaugeFunc("app_items", "", []string{"name"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"x"}, Value: 10}}
+	})
+
+	registry.MustRegister(counter, gauge, histogram, gaugeFunc)
+
+	err := registry.Register(NewGauge("app_workers", ""))
+	if err == nil {
+		t.Fatal("duplicate metric should be reported")
+	}
+	err = registry.Register(NewGauge("app-invalid", ""))
+	if err == nil {
+		t.Fatal("invalid name should be reported")
+	}
+	err = registry.Register(NewGauge("app_invalid_label", "", "le"))
+	if err == nil {
+		t.Fatal("invalid label name should be reported")
+	}
+
+	var text = registry.Text()
+	t.Log("\n" + text)
+
+	for _, line := range []string{
+		"# HELP app_jobs_total Jobs processed.",
+		"# TYPE app_jobs_total counter",
+		`app_jobs_total{queue="email"} 3`,
+		`app_jobs_total{queue="sms"} 1`,
+		"app_workers 2",
+		"# TYPE app_latency_seconds histogram",
+		`app_latency_seconds_bucket{path="/a\"b",le="0.1"} 1`,
+		`app_latency_seconds_bucket{path="/a\"b",le="1"} 2`,
+		`app_latency_seconds_bucket{path="/a\"b",le="+Inf"} 3`,
+		`app_latency_seconds_sum{path="/a\"b"} 3.55`,
+		`app_latency_seconds_count{path="/a\"b"} 3`,
+		`app_items{name="x"} 10`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Fatal("'" + line + "' not found")
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var metricNameReg = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+var labelNameReg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// DefaultRegistry 默认的指标注册表
+var DefaultRegistry = NewRegistry()
+
+// Registry 指标注册表
+type Registry struct {
+	metrics map[string]Metric // name => Metric
+	locker  sync.RWMutex
+}
+
+// NewRegistry 创建注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: map[string]Metric{},
+	}
+}
+
+// Register 注册指标，名称不能重复
+func (this *Registry) Register(metric Metric) error {
+	if !metricNameReg.MatchString(metric.Name()) {
+		return errors.New("metrics: invalid metric name '" + metric.Name() + "'")
+	}
+	if desc, ok := metric.(interface{ labels() []string }); ok {
+		for _, labelName := range desc.labels() {
+			if !labelNameReg.MatchString(labelName) || strings.HasPrefix(labelName, "__") || labelName == "le" {
+				return errors.New("metrics: invalid label name '" + labelName + "' of '" + metric.Name() + "'")
+			}
+		}
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	_, ok := this.metrics[metric.Name()]
+	if ok {
+		return errors.New("metrics: metric '" + metric.Name() + "' is already registered")
+	}
+	this.metrics[metric.Name()] = metric
+	return nil
+}
+
+// MustRegister 注册指标，出错时panic
+func (this *Registry) MustRegister(metrics ...Metric) {
+	for _, metric := range metrics {
+		err := this.Register(metric)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister 取消注册
+func (this *Registry) Unregister(name string) {
+	this.locker.Lock()
+	delete(this.metrics, name)
+	this.locker.Unlock()
+}
+
+// Lookup 查找已注册的指标
+func (this *Registry) Lookup(name string) Metric {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return this.metrics[name]
+}
+
+// Text 以Prometheus文本格式输出所有指标
+func (this *Registry) Text() string {
+	this.locker.RLock()
+	var names = make([]string, 0, len(this.metrics))
+	for name := range this.metrics {
+		names = append(names, name)
+	}
+	var metrics = make([]Metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, this.metrics[name])
+	}
+	this.locker.RUnlock()
+
+	var builder = &strings.Builder{}
+	for _, metric := range metrics {
+		if len(metric.Help()) > 0 {
+			builder.WriteString("# HELP " + metric.Name() + " " + escapeHelp(metric.Help()) + "\n")
+		}
+		builder.WriteString("# TYPE " + metric.Name() + " " + metric.Type() + "\n")
+		metric.write(builder)
+	}
+	return builder.String()
+}
+
+// Handler 输出指标的处理器
+func (this *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = writer.Write([]byte(this.Text()))
+	})
+}
+
+// Register 在默认注册表中注册指标
+func Register(metric Metric) error {
+	return DefaultRegistry.Register(metric)
+}
+
+// MustRegister 在默认注册表中注册指标，出错时panic
+func MustRegister(metrics ...Metric) {
+	DefaultRegistry.MustRegister(metrics...)
+}
+
+// Unregister 从默认注册表中取消注册
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// 输出一个样本
+func writeSample(builder *strings.Builder, name string, labelNames []string, labelValues []string, extraLabelName string, extraLabelValue string, value float64) {
+	builder.WriteString(name)
+	if len(labelNames) > 0 || len(extraLabelName) > 0 {
+		builder.WriteString("{")
+		for index, labelName := range labelNames {
+			if index > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(labelName + "=\"" + escapeLabelValue(labelValues[index]) + "\"")
+		}
+		if len(extraLabelName) > 0 {
+			if len(labelNames) > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(extraLabelName + "=\"" + escapeLabelValue(extraLabelValue) + "\"")
+		}
+		builder.WriteString("}")
+	}
+	builder.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelValueReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+var helpReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,231 @@
+package TeaGo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 读取PROXY协议头的超时时间
+const proxyProtocolHeaderTimeout = 10 * time.Second
+
+// PROXY协议v2的签名
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// 解析PROXY协议（v1和v2）的监听
+// 连接的 RemoteAddr() 和 LocalAddr() 返回协议头中的地址
+//
+// 协议头中的地址是可以伪造的，所以应该用 allowedNetworks 限制代理服务器的地址，
+// 或者保证此端口只能被代理服务器访问
+type proxyProtocolListener struct {
+	net.Listener
+
+	allowedNetworks []*net.IPNet // 允许发送协议头的来源，为空表示不限制
+}
+
+func (this *proxyProtocolListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	for {
+		var err error
+		conn, err = this.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if this.isAllowed(conn.RemoteAddr()) {
+			break
+		}
+
+		// 拒绝不在允许列表中的来源
+		_ = conn.Close()
+	}
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// 判断来源是否可以发送协议头
+// Unix Socket等非TCP连接不做限制
+func (this *proxyProtocolListener) isAllowed(addr net.Addr) bool {
+	if len(this.allowedNetworks) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	for _, network := range this.allowedNetworks {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// 带有PROXY协议头的连接，在第一次读取或者获取地址时解析协议头
+type proxyProtocolConn struct {
+	net.Conn
+
+	reader     *bufio.Reader
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (this *proxyProtocolConn) Read(b []byte) (int, error) {
+	this.once.Do(this.readHeader)
+	if this.err != nil {
+		return 0, this.err
+	}
+	return this.reader.Read(b)
+}
+
+func (this *proxyProtocolConn) RemoteAddr() net.Addr {
+	this.once.Do(this.readHeader)
+	if this.remoteAddr != nil {
+		return this.remoteAddr
+	}
+	return this.Conn.RemoteAddr()
+}
+
+func (this *proxyProtocolConn) LocalAddr() net.Addr {
+	this.once.Do(this.readHeader)
+	if this.localAddr != nil {
+		return this.localAddr
+	}
+	return this.Conn.LocalAddr()
+}
+
+// 读取协议头
+// http.Server在处理连接时首先会调用 RemoteAddr()，此时还没有设置读超时，所以这里可以放心地重置
+func (this *proxyProtocolConn) readHeader() {
+	_ = this.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+	defer func() {
+		_ = this.Conn.SetReadDeadline(time.Time{})
+	}()
+
+	signature, err := this.reader.Peek(5)
+	if err != nil {
+		this.err = errors.New("proxy protocol: " + err.Error())
+		return
+	}
+	if string(signature) == "PROXY" {
+		this.err = this.readHeaderV1()
+	} else {
+		signature, err = this.reader.Peek(len(proxyProtocolV2Signature))
+		if err == nil && bytes.Equal(signature, proxyProtocolV2Signature) {
+			this.err = this.readHeaderV2()
+		} else {
+			this.err = errors.New("proxy protocol: header not found")
+		}
+	}
+
+	if this.err != nil {
+		_ = this.Conn.Close()
+	}
+}
+
+// 读取v1协议头，比如 PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func (this *proxyProtocolConn) readHeaderV1() error {
+	var line = []byte{}
+	for {
+		b, err := this.reader.ReadByte()
+		if err != nil {
+			return errors.New("proxy protocol: " + err.Error())
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+
+		// v1协议头最长107个字节
+		if len(line) >= 107 {
+			return errors.New("proxy protocol: v1 header too long")
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("proxy protocol: invalid v1 header")
+	}
+	var pieces = strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(pieces) < 2 {
+		return errors.New("proxy protocol: invalid v1 header")
+	}
+
+	switch pieces[1] {
+	case "UNKNOWN":
+		return nil
+	case "TCP4", "TCP6":
+		if len(pieces) != 6 {
+			return errors.New("proxy protocol: invalid v1 header")
+		}
+		srcIP := net.ParseIP(pieces[2])
+		dstIP := net.ParseIP(pieces[3])
+		srcPort, err1 := strconv.Atoi(pieces[4])
+		dstPort, err2 := strconv.Atoi(pieces[5])
+		if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil || srcPort < 0 || srcPort > 65535 || dstPort < 0 || dstPort > 65535 {
+			return errors.New("proxy protocol: invalid v1 address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+		this.localAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+		return nil
+	}
+	return errors.New("proxy protocol: unknown v1 protocol '" + pieces[1] + "'")
+}
+
+// 读取v2协议头
+func (this *proxyProtocolConn) readHeaderV2() error {
+	var header = make([]byte, 16)
+	_, err := io.ReadFull(this.reader, header)
+	if err != nil {
+		return errors.New("proxy protocol: " + err.Error())
+	}
+
+	var version = header[12] >> 4
+	var command = header[12] & 0x0F
+	var family = header[13] >> 4
+	var length = int(binary.BigEndian.Uint16(header[14:16]))
+	if version != 2 {
+		return errors.New("proxy protocol: invalid v2 version")
+	}
+
+	var payload = make([]byte, length)
+	_, err = io.ReadFull(this.reader, payload)
+	if err != nil {
+		return errors.New("proxy protocol: " + err.Error())
+	}
+
+	// LOCAL命令，使用连接本身的地址
+	if command == 0x0 {
+		return nil
+	}
+	if command != 0x1 {
+		return errors.New("proxy protocol: unknown v2 command")
+	}
+
+	switch family {
+	case 0x1: // AF_INET
+		if length < 12 {
+			return errors.New("proxy protocol: invalid v2 address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		this.localAddr = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case 0x2: // AF_INET6
+		if length < 36 {
+			return errors.New("proxy protocol: invalid v2 address")
+		}
+		this.remoteAddr = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		this.localAddr = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	}
+
+	// 其他地址类型（比如AF_UNIX）使用连接本身的地址
+	return nil
+}
@@ -40,10 +40,42 @@ func (this *responseWriter) Write(b []byte) (int, error) {
 	return length, err
 }
 
+func (this *responseWriter) Flush() {
+	flusher, ok := this.responseWriter.(http.Flusher)
+	if ok {
+		this.done = true
+		flusher.Flush()
+	}
+}
+
+func (this *responseWriter) Unwrap() http.ResponseWriter {
+	return this.responseWriter
+}
+
 func (this *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	h, ok := this.responseWriter.(http.Hijacker)
 	if ok {
-		return h.Hijack()
+		conn, readWriter, err := h.Hijack()
+		if err == nil {
+			// 比如升级为WebSocket，连接已经由调用者接管
+			this.done = true
+			this.status = http.StatusSwitchingProtocols
+		}
+		return conn, readWriter, err
 	}
 	return nil, nil, errors.New("http.Hijacker not implemented by underlying http.ResponseWriter")
 }
+
+// 判断是否已经有输出，会依次查找被包装的Writer
+func isResponseWritten(writer http.ResponseWriter) bool {
+	for {
+		switch w := writer.(type) {
+		case *responseWriter:
+			return w.done
+		case interface{ Unwrap() http.ResponseWriter }:
+			writer = w.Unwrap()
+		default:
+			return false
+		}
+	}
+}
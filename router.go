@@ -0,0 +1,482 @@
+package TeaGo
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/types"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 路由节点类型，同一级中按照以下顺序匹配：静态 > 带正则的参数 > 参数 > 通配符
+// 同一级中多个带正则的参数必须可以通过参数前后的固定文字区分（比如 :name.html 和 :name.json），否则作为歧义报告
+const (
+	routeNodeStatic   = 1 // /user
+	routeNodeParam    = 2 // /:id 、 /:id(\d+) 、 /:name.html
+	routeNodeWildcard = 3 // /*path
+)
+
+var routeWildcardReg = regexp.MustCompile(`^\*\w+$`)
+
+// ServerRoutePattern 路由配置
+// Deprecated: 路由已经使用路由树匹配，此类型仅为兼容保留
+type ServerRoutePattern = serverRoute
+
+// 单个路由定义
+type serverRoute struct {
+	host    string // 虚拟主机
+	module  string
+	pattern string
+	method  string
+	name    string
+	names   []string
+	spec    *actions.ActionSpec // 为nil时表示路由对应的是函数
+	runFunc func(writer http.ResponseWriter, request *http.Request)
+}
+
+// 路由树
+type routeTree struct {
+	root *routeNode
+}
+
+// 路由树节点，每个节点对应路径中的一段
+type routeNode struct {
+	kind    int
+	segment string // 原始的路径片段
+
+	key    string         // 用来判断两个参数节点是否相同的标识
+	names  []string       // 参数名
+	reg    *regexp.Regexp // 参数正则，为nil时表示匹配任意非空片段
+	groups []int          // 每个参数在正则中对应的分组
+	prefix string         // 参数之前的固定文字
+	suffix string         // 参数之后的固定文字
+
+	staticChildren map[string]*routeNode
+	paramChildren  []*routeNode
+	wildcardChild  *routeNode
+
+	routes map[string]*serverRoute // method => route
+}
+
+func newRouteTree() *routeTree {
+	return &routeTree{
+		root: newRouteNode(routeNodeStatic, ""),
+	}
+}
+
+func newRouteNode(kind int, segment string) *routeNode {
+	return &routeNode{
+		kind:           kind,
+		segment:        segment,
+		staticChildren: map[string]*routeNode{},
+		routes:         map[string]*serverRoute{},
+	}
+}
+
+// 添加路由，如果和已有的路由冲突则返回错误
+func (this *routeTree) add(route *serverRoute) error {
+	segments := splitRoutePath(route.pattern)
+
+	var node = this.root
+	var names = []string{}
+	for index, segment := range segments {
+		child, err := this.parseSegment(segment)
+		if err != nil {
+			return errors.New("route '" + route.pattern + "': " + err.Error())
+		}
+
+		switch child.kind {
+		case routeNodeStatic:
+			existNode, ok := node.staticChildren[segment]
+			if ok {
+				child = existNode
+			} else {
+				node.staticChildren[segment] = child
+			}
+		case routeNodeParam:
+			var found = false
+			for _, existNode := range node.paramChildren {
+				if existNode.key != child.key {
+					continue
+				}
+				if strings.Join(existNode.names, ",") != strings.Join(child.names, ",") {
+					return errors.New("route '" + route.pattern + "': parameter '" + segment + "' is ambiguous with '" + existNode.segment + "'")
+				}
+				child = existNode
+				found = true
+				break
+			}
+			if !found {
+				// 同一级中带正则的参数无法通过固定文字区分时，匹配结果取决于定义顺序，因此作为歧义报告
+				if child.reg != nil {
+					for _, existNode := range node.paramChildren {
+						if existNode.reg != nil && !existNode.distinguishable(child) {
+							return errors.New("route '" + route.pattern + "': parameter '" + segment + "' is ambiguous with '" + existNode.segment + "', merge them into one pattern or use different static text")
+						}
+					}
+				}
+				node.paramChildren = append(node.paramChildren, child)
+
+				// 带正则的参数优先
+				sort.SliceStable(node.paramChildren, func(i, j int) bool {
+					return node.paramChildren[i].reg != nil && node.paramChildren[j].reg == nil
+				})
+			}
+		case routeNodeWildcard:
+			if index != len(segments)-1 {
+				return errors.New("route '" + route.pattern + "': wildcard '" + segment + "' must be the last segment")
+			}
+			if node.wildcardChild != nil {
+				if node.wildcardChild.names[0] != child.names[0] {
+					return errors.New("route '" + route.pattern + "': wildcard '" + segment + "' is ambiguous with '" + node.wildcardChild.segment + "'")
+				}
+				child = node.wildcardChild
+			} else {
+				node.wildcardChild = child
+			}
+		}
+
+		names = append(names, child.names...)
+		node = child
+	}
+
+	route.names = names
+
+	if existRoute, ok := node.routes[route.method]; ok {
+		return errors.New("route '" + route.method + " " + route.pattern + "' is already registered by '" + existRoute.method + " " + existRoute.pattern + "'")
+	}
+	node.routes[route.method] = route
+	return nil
+}
+
+// 查找路径对应的节点，并返回参数值
+func (this *routeTree) lookup(path string) (node *routeNode, values url.Values) {
+	values = url.Values{}
+	node = this.root.match(strings.Split(strings.TrimPrefix(path, "/"), "/"), values)
+	return
+}
+
+// 分析路径片段
+func (this *routeTree) parseSegment(segment string) (*routeNode, error) {
+	// 通配符
+	if strings.HasPrefix(segment, "*") {
+		if !routeWildcardReg.MatchString(segment) {
+			return nil, errors.New("invalid wildcard '" + segment + "'")
+		}
+		node := newRouteNode(routeNodeWildcard, segment)
+		node.names = []string{segment[1:]}
+		node.key = "*"
+		return node, nil
+	}
+
+	params, err := parseRouteParams(segment)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 {
+		return newRouteNode(routeNodeStatic, segment), nil
+	}
+
+	node := newRouteNode(routeNodeParam, segment)
+
+	// 只有一个不带正则的参数
+	if len(params) == 1 && params[0].start == 0 && params[0].end == len(segment) && len(params[0].expr) == 0 {
+		node.names = []string{params[0].name}
+		node.key = ":"
+		return node, nil
+	}
+
+	// 每个参数使用一个命名分组，正则中可以再包含其他分组
+	var expr = ""
+	var lastIndex = 0
+	for index, param := range params {
+		expr += regexp.QuoteMeta(segment[lastIndex:param.start])
+		node.names = append(node.names, param.name)
+		var paramExpr = param.expr
+		if len(paramExpr) == 0 {
+			paramExpr = "[^/]+"
+		}
+		expr += "(?P<p" + strconv.Itoa(index) + ">" + paramExpr + ")"
+		lastIndex = param.end
+	}
+	expr += regexp.QuoteMeta(segment[lastIndex:])
+
+	reg, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, err
+	}
+	for index := range params {
+		node.groups = append(node.groups, reg.SubexpIndex("p"+strconv.Itoa(index)))
+	}
+	node.reg = reg
+	node.key = expr
+	node.prefix = segment[:params[0].start]
+	node.suffix = segment[params[len(params)-1].end:]
+	return node, nil
+}
+
+// 判断两个带正则的参数节点能否通过参数前后的固定文字区分
+func (this *routeNode) distinguishable(other *routeNode) bool {
+	if !strings.HasPrefix(this.prefix, other.prefix) && !strings.HasPrefix(other.prefix, this.prefix) {
+		return true
+	}
+	if !strings.HasSuffix(this.suffix, other.suffix) && !strings.HasSuffix(other.suffix, this.suffix) {
+		return true
+	}
+	return false
+}
+
+// 匹配路径片段
+func (this *routeNode) match(segments []string, values url.Values) *routeNode {
+	if len(segments) == 0 {
+		if len(this.routes) > 0 {
+			return this
+		}
+		return nil
+	}
+
+	segment := segments[0]
+
+	// 静态
+	child, ok := this.staticChildren[segment]
+	if ok {
+		result := child.match(segments[1:], values)
+		if result != nil {
+			return result
+		}
+	}
+
+	// 参数
+	if len(segment) > 0 {
+		for _, child := range this.paramChildren {
+			var paramValues []string
+			if child.reg == nil {
+				paramValues = []string{segment}
+			} else {
+				matches := child.reg.FindStringSubmatch(segment)
+				if len(matches) == 0 {
+					continue
+				}
+				for _, group := range child.groups {
+					paramValues = append(paramValues, matches[group])
+				}
+			}
+
+			result := child.match(segments[1:], values)
+			if result != nil {
+				for index, name := range child.names {
+					values.Add(name, paramValues[index])
+				}
+				return result
+			}
+		}
+	}
+
+	// 通配符
+	if this.wildcardChild != nil && len(this.wildcardChild.routes) > 0 {
+		values.Add(this.wildcardChild.names[0], strings.Join(segments, "/"))
+		return this.wildcardChild
+	}
+
+	return nil
+}
+
+// 查找某个请求方法对应的路由
+func (this *routeNode) route(method string) *serverRoute {
+	route, ok := this.routes[method]
+	if ok {
+		return route
+	}
+	route, ok = this.routes["*"]
+	if ok {
+		return route
+	}
+	return nil
+}
+
+// 节点支持的所有请求方法，用于405响应的 Allow，不包括表示所有方法的 *
+func (this *routeNode) allowMethods() []string {
+	result := []string{}
+	for method := range this.routes {
+		if method == "*" {
+			continue
+		}
+		result = append(result, method)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// 分割路径，括号中的 / 不作为分隔符
+func splitRoutePath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+
+	var segments = []string{}
+	var depth = 0
+	var lastIndex = 0
+	for index, c := range path {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[lastIndex:index])
+				lastIndex = index + 1
+			}
+		}
+	}
+	segments = append(segments, path[lastIndex:])
+	return segments
+}
+
+// 路径片段中的参数
+type routeParam struct {
+	start int    // 在片段中的开始位置
+	end   int    // 在片段中的结束位置
+	name  string // 参数名
+	expr  string // 括号中的正则，不包含最外层的括号，为空表示没有正则
+}
+
+// 分析路径片段中的参数，比如 :id 、 :id(\d+) 、 :id((a|b)\d+) ，正则中的括号可以嵌套
+func parseRouteParams(segment string) (params []routeParam, err error) {
+	var isWordChar = func(c byte) bool {
+		return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	for index := 0; index < len(segment); index++ {
+		if segment[index] != ':' {
+			continue
+		}
+		var nameEnd = index + 1
+		for nameEnd < len(segment) && isWordChar(segment[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == index+1 {
+			continue
+		}
+
+		var param = routeParam{
+			start: index,
+			end:   nameEnd,
+			name:  segment[index+1 : nameEnd],
+		}
+
+		// 正则
+		if nameEnd < len(segment) && segment[nameEnd] == '(' {
+			var depth = 0
+			var inClass = false
+			var exprEnd = -1
+		Loop:
+			for i := nameEnd; i < len(segment); i++ {
+				switch c := segment[i]; {
+				case c == '\\':
+					i++
+				case inClass:
+					if c == ']' {
+						inClass = false
+					}
+				case c == '[':
+					inClass = true
+				case c == '(':
+					depth++
+				case c == ')':
+					depth--
+					if depth == 0 {
+						exprEnd = i
+						break Loop
+					}
+				}
+			}
+			if exprEnd < 0 {
+				return nil, errors.New("parameter '" + segment + "' has unclosed parenthesis")
+			}
+			param.expr = segment[nameEnd+1 : exprEnd]
+			param.end = exprEnd + 1
+		}
+
+		params = append(params, param)
+		index = param.end - 1
+	}
+	return
+}
+
+// 使用参数生成路由对应的路径，没有用到的参数会放在查询字符串中
+func buildRoutePath(pattern string, params map[string]interface{}) (string, error) {
+	var usedNames = map[string]bool{}
+	var pieces = []string{}
+	for _, segment := range splitRoutePath(pattern) {
+		// 通配符
+		if routeWildcardReg.MatchString(segment) {
+			name := segment[1:]
+			value, ok := params[name]
+			if !ok {
+				return "", errors.New("missing param '" + name + "'")
+			}
+			usedNames[name] = true
+
+			var subPieces = []string{}
+			for _, piece := range strings.Split(strings.TrimPrefix(types.String(value), "/"), "/") {
+				subPieces = append(subPieces, url.PathEscape(piece))
+			}
+			pieces = append(pieces, strings.Join(subPieces, "/"))
+			continue
+		}
+
+		segmentParams, err := parseRouteParams(segment)
+		if err != nil {
+			return "", err
+		}
+		var piece = ""
+		var lastIndex = 0
+		for _, param := range segmentParams {
+			piece += segment[lastIndex:param.start]
+			lastIndex = param.end
+
+			value, ok := params[param.name]
+			if !ok {
+				return "", errors.New("missing param '" + param.name + "'")
+			}
+			usedNames[param.name] = true
+
+			valueString := types.String(value)
+			if len(param.expr) > 0 {
+				reg, err := regexp.Compile("^(?:" + param.expr + ")$")
+				if err != nil {
+					return "", err
+				}
+				if !reg.MatchString(valueString) {
+					return "", errors.New("invalid param '" + param.name + "': '" + valueString + "' does not match '(" + param.expr + ")'")
+				}
+			} else if len(valueString) == 0 {
+				return "", errors.New("invalid param '" + param.name + "': should not be empty")
+			}
+			piece += url.PathEscape(valueString)
+		}
+		segment = piece + segment[lastIndex:]
+		pieces = append(pieces, segment)
+	}
+
+	var path = "/" + strings.Join(pieces, "/")
+
+	// 查询参数
+	var query = url.Values{}
+	for name, value := range params {
+		if usedNames[name] {
+			continue
+		}
+		query.Add(name, types.String(value))
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	return path, nil
+}
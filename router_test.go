@@ -0,0 +1,197 @@
+package TeaGo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteTree_Lookup(t *testing.T) {
+	tree := newRouteTree()
+	for _, pattern := range []string{
+		"/",
+		"/user",
+		"/user/new",
+		"/user/:id(\\d+)",
+		"/user/:name",
+		"/user/:id(\\d+)/posts",
+		"/file/:name.html",
+		"/static/*path",
+		"/tags/:tag((a|b)\\d+)",
+	} {
+		err := tree.add(&serverRoute{pattern: pattern, method: "GET"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, expected := range map[string]string{
+		"/":                  "/",
+		"/user":              "/user",
+		"/user/new":          "/user/new",
+		"/user/123":          "/user/:id(\\d+)",
+		"/user/lu":           "/user/:name",
+		"/user/123/posts":    "/user/:id(\\d+)/posts",
+		"/file/index.html":   "/file/:name.html",
+		"/static/js/app.js":  "/static/*path",
+		"/tags/a12":          "/tags/:tag((a|b)\\d+)",
+		"/tags/c12":          "",
+		"/user/":             "",
+		"/user/lu/posts":     "",
+		"/file/index.htm":    "",
+		"/not/found/at/all/": "",
+	} {
+		node, values := tree.lookup(path)
+		if node == nil {
+			if len(expected) > 0 {
+				t.Fatal("'" + path + "' should match '" + expected + "'")
+			}
+			continue
+		}
+		route := node.route("GET")
+		if route.pattern != expected {
+			t.Fatal("'" + path + "' should match '" + expected + "', but got '" + route.pattern + "'")
+		}
+		t.Log(path, "=>", route.pattern, values.Encode())
+	}
+
+	_, values := tree.lookup("/static/js/app.js")
+	if values.Get("path") != "js/app.js" {
+		t.Fatal("wildcard value should be 'js/app.js'")
+	}
+
+	_, values = tree.lookup("/tags/b3")
+	if values.Get("tag") != "b3" {
+		t.Fatal("nested groups should be kept in parameter regexp")
+	}
+	path, err := buildRoutePath("/tags/:tag((a|b)\\d+)", map[string]interface{}{"tag": "a1"})
+	if err != nil || path != "/tags/a1" {
+		t.Fatal("invalid path:", path, err)
+	}
+}
+
+func TestRouteTree_Conflict(t *testing.T) {
+	tree := newRouteTree()
+	if err := tree.add(&serverRoute{pattern: "/user/:id", method: "GET"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复
+	err := tree.add(&serverRoute{pattern: "/user/:id", method: "GET"})
+	if err == nil {
+		t.Fatal("duplicate route should be reported")
+	}
+	t.Log(err)
+
+	// 歧义
+	err = tree.add(&serverRoute{pattern: "/user/:name", method: "POST"})
+	if err == nil {
+		t.Fatal("ambiguous route should be reported")
+	}
+	t.Log(err)
+
+	// 通配符必须在最后
+	err = tree.add(&serverRoute{pattern: "/files/*path/info", method: "GET"})
+	if err == nil {
+		t.Fatal("wildcard in middle should be reported")
+	}
+	t.Log(err)
+
+	// 无法区分的正则参数
+	if err := tree.add(&serverRoute{pattern: "/posts/:id(\\d+)", method: "GET"}); err != nil {
+		t.Fatal(err)
+	}
+	err = tree.add(&serverRoute{pattern: "/posts/:name(\\w+)", method: "GET"})
+	if err == nil {
+		t.Fatal("overlapping regexp params should be reported")
+	}
+	t.Log(err)
+	if err := tree.add(&serverRoute{pattern: "/pages/:name.html", method: "GET"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.add(&serverRoute{pattern: "/pages/:name.json", method: "GET"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不同的方法
+	if err := tree.add(&serverRoute{pattern: "/user/:id", method: "POST"}); err != nil {
+		t.Fatal(err)
+	}
+
+	node, _ := tree.lookup("/user/1")
+	if node.route("PUT") != nil {
+		t.Fatal("PUT should not be allowed")
+	}
+	t.Log(node.allowMethods())
+
+	// * 不出现在 Allow 中
+	if err := tree.add(&serverRoute{pattern: "/user/:id", method: "*"}); err != nil {
+		t.Fatal(err)
+	}
+	node, _ = tree.lookup("/user/1")
+	if node.route("PUT") == nil || strings.Join(node.allowMethods(), ", ") != "GET, POST" {
+		t.Fatal("unexpected allow methods:", node.allowMethods())
+	}
+}
+
+func TestServer_URL(t *testing.T) {
+	server := NewServer(false)
+	server.
+		Module("admin").
+		Prefix("/users").
+		Get("/:id(\\d+)", func() {}).Name("user.view").
+		Get("/files/*path", func() {}).Name("user.files").
+		EndAll()
+
+	for _, c := range []struct {
+		name     string
+		params   map[string]interface{}
+		expected string
+	}{
+		{"user.view", map[string]interface{}{"id": 1}, "/@admin/users/1"},
+		{"user.view", map[string]interface{}{"id": 1, "tab": "info"}, "/@admin/users/1?tab=info"},
+		{"user.files", map[string]interface{}{"path": "a b/c.txt"}, "/@admin/users/files/a%20b/c.txt"},
+	} {
+		u, err := server.URL(c.name, c.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != c.expected {
+			t.Fatal("expected '" + c.expected + "', but got '" + u + "'")
+		}
+	}
+
+	// 缺少参数
+	_, err := server.URL("user.view", nil)
+	if err == nil {
+		t.Fatal("missing param should be reported")
+	}
+	t.Log(err)
+
+	// 参数不匹配
+	_, err = server.URL("user.view", map[string]interface{}{"id": "abc"})
+	if err == nil {
+		t.Fatal("invalid param should be reported")
+	}
+	t.Log(err)
+
+	// 不存在的路由
+	_, err = server.URL("not.found", nil)
+	if err == nil {
+		t.Fatal("route not found should be reported")
+	}
+}
+
+func TestServer_RefuseToStart(t *testing.T) {
+	server := NewServer(false)
+	server.
+		Get("/user/:id", func() {}).
+		Get("/user/:name", func() {})
+	if len(server.RouteErrors()) != 1 {
+		t.Fatal("ambiguous route should be reported")
+	}
+
+	server.StartOn("127.0.0.1:0")
+	if len(server.listeners) > 0 {
+		t.Fatal("server should not start with route errors")
+	}
+}
@@ -1,26 +1,34 @@
 package TeaGo
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/files"
 	"github.com/iwind/TeaGo/lists"
 	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/metrics"
 	"github.com/iwind/TeaGo/processes"
 	"github.com/iwind/TeaGo/types"
 	"github.com/iwind/TeaGo/utils/string"
+	"io/fs"
 	"log"
 	"mime"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"reflect"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -66,51 +74,86 @@ func BeforeStop(fn func(server *Server)) {
 type Server struct {
 	singleInstance bool
 
-	directRoutes  map[string]func(writer http.ResponseWriter, request *http.Request)
-	patternRoutes []ServerRoutePattern
-	routerLocker  sync.Mutex
-	staticDirs    []ServerStaticDir
-
-	sessionManager    interface{}
-	sessionCookieName string
+	defaultHost  *serverHost             // 默认主机，没有匹配到其他主机的请求由此主机处理
+	hosts        []*serverHost           // 通过 Host() 定义的主机
+	routeErrors  []error                 // 注册路由时发生的错误
+	container    *actions.Container      // 依赖注入容器
+	namedRoutes  map[string]*serverRoute // name => route
+	lastRoute    *serverRoute            // 最近一次定义的路由
+	routerLocker sync.Mutex
 
+	lastHost    *serverHost   // 当前的主机
 	lastModule  string        //当前的模块
 	lastPrefix  string        //当前的URL前缀
 	lastHelpers []interface{} // 当前的Helper列表
 	lastData    actions.Data  // 当前的变量列表
+	lastTimeout time.Duration // 当前的动作超时时间
+
+	lastStreamUpload   bool                       // 当前是否使用流式上传
+	lastUploadProgress actions.UploadProgressFunc // 当前的上传进度回调
+
+	lastMiddlewares   []scopedMiddleware                     // 当前的中间件列表
+	middlewareScopes  []string                               // 当前打开的 Host()、Module() 和 Prefix() 作用域，最后一个为最内层
+	globalMiddlewares []func(next http.Handler) http.Handler // 通过 UseGlobal() 添加的中间件，作用于所有请求
 
 	config    *ServerConfig
 	logWriter LogWriter
 	accessLog bool // 是否记录访问日志
 
+	metricsPath string // 输出指标的路径
+
+	openAPIPath    string // 输出OpenAPI文档的路径
+	openAPITitle   string
+	openAPIVersion string
+
+	healthPath        string        // 存活检查路径
+	readyPath         string        // 就绪检查路径
+	readyChecks       []readyCheck  // 就绪检查
+	readyCheckTimeout time.Duration // 就绪检查的超时时间
+	started           int32         // 是否已经启动
+	stopping          int32         // 是否正在停止
+
 	httpServers      []*http.Server
+	listeners        map[string]net.Listener // addr => listener
 	httpServerLocker sync.Mutex
 	connState        func(conn net.Conn, state http.ConnState)
 
 	internalErrorLogger *log.Logger
 	readHeaderTimeout   time.Duration
 	readTimeout         time.Duration
+
+	shutdownTimeout time.Duration // 停止服务时等待请求处理完成的最长时间
+	stopOnce        sync.Once
+	stopChan        chan bool // 服务停止后关闭
 }
 
-// ServerRoutePattern 路由配置
-type ServerRoutePattern struct {
-	module  string
-	reg     regexp.Regexp
-	names   []string
-	method  string
-	runFunc func(writer http.ResponseWriter, request *http.Request)
+// 中间件的作用域
+const (
+	middlewareScopeHost   = "host"
+	middlewareScopeModule = "module"
+	middlewareScopePrefix = "prefix"
+)
+
+// 通过 Use() 添加的中间件
+type scopedMiddleware struct {
+	scope      string // 添加时所在的最内层作用域，为空表示不在任何作用域中
+	middleware func(next http.Handler) http.Handler
 }
 
 // ServerStaticDir 静态资源目录
 type ServerStaticDir struct {
-	prefix string
-	dir    string
+	prefix      string
+	dir         string
+	middlewares []func(next http.Handler) http.Handler
+	options     staticOptions
 }
 
 // NewServer 构建一个新的Server
 func NewServer(singleInstance ...bool) *Server {
 	var server = &Server{
-		accessLog: true,
+		accessLog:       true,
+		shutdownTimeout: 30 * time.Second,
+		stopChan:        make(chan bool),
 	}
 
 	if len(singleInstance) == 0 {
@@ -121,28 +164,38 @@ func NewServer(singleInstance ...bool) *Server {
 
 	server.init()
 
+	lastServerLocker.Lock()
+	lastServer = server
+	lastServerLocker.Unlock()
+
 	return server
 }
 
 // 初始化
 func (this *Server) init() {
-	this.directRoutes = make(map[string]func(writer http.ResponseWriter, request *http.Request))
-	this.patternRoutes = []ServerRoutePattern{}
-	this.staticDirs = []ServerStaticDir{}
+	this.defaultHost = newServerHost("")
+	this.lastHost = this.defaultHost
+	this.namedRoutes = map[string]*serverRoute{}
+	this.listeners = map[string]net.Listener{}
+	this.container = actions.NewContainer()
 
 	// 配置
 	this.config = &ServerConfig{}
 	this.config.Load()
 
-	if this.singleInstance {
+	// 执行 :openapi 之类的命令时不影响正在运行的服务
+	if this.singleInstance && !isCommandArgs() {
 		// 执行参数
 		this.execArgs()
 
-		// 检查PID
-		this.checkPid()
+		// 平滑重启时由新进程在就绪后再记录PID
+		if !isUpgradeProcess() {
+			// 检查PID
+			this.checkPid()
 
-		// 记录PID
-		this.writePid()
+			// 记录PID
+			this.writePid()
+		}
 	}
 }
 
@@ -157,6 +210,17 @@ func (this *Server) Start() {
 
 // StartOn 在某个地址上启动服务
 func (this *Server) StartOn(address string) {
+	// 执行命令行中的命令，比如 :openapi
+	if this.runCommandArgs() {
+		return
+	}
+
+	// 注册路由时有错误（比如重复或有歧义的路由）时不启动服务
+	if len(this.routeErrors) > 0 {
+		logs.Error(errors.New("server: refuse to start because of " + strconv.Itoa(len(this.routeErrors)) + " route error(s), see RouteErrors()"))
+		return
+	}
+
 	var serverMux = http.NewServeMux()
 
 	// Functions
@@ -171,51 +235,46 @@ func (this *Server) StartOn(address string) {
 		}
 	})
 
-	// 静态资源目录
-	for _, staticDir := range this.staticDirs {
-		var staticDirCopy = staticDir
-		var prefix = staticDirCopy.prefix
-		if len(prefix) > 0 {
-			if !strings.HasPrefix(prefix, "/") {
-				prefix = "/" + prefix
-			}
-			if !strings.HasSuffix(prefix, "/") {
-				prefix += "/"
+	// 指标
+	if len(this.metricsPath) > 0 {
+		var metricsHandler = this.applyMiddlewares(metrics.DefaultRegistry.Handler(), this.globalMiddlewares)
+		serverMux.HandleFunc(this.metricsPath, func(writer http.ResponseWriter, request *http.Request) {
+			writer = newResponseWriter(writer)
+
+			// 输出日志
+			if this.accessLog {
+				defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
 			}
 
-			serverMux.HandleFunc(prefix, func(writer http.ResponseWriter, request *http.Request) {
-				writer = newResponseWriter(writer)
+			metricsHandler.ServeHTTP(writer, request)
+		})
+	}
 
-				// 输出日志
-				if this.accessLog {
-					defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
-				}
+	// OpenAPI文档
+	if len(this.openAPIPath) > 0 {
+		var openAPIHandler = this.applyMiddlewares(http.HandlerFunc(this.handleOpenAPI), this.globalMiddlewares)
+		serverMux.HandleFunc(this.openAPIPath, func(writer http.ResponseWriter, request *http.Request) {
+			writer = newResponseWriter(writer)
 
-				_, isText := this.outputMimeType(writer, request.URL.Path)
-				if isText {
-					newWriter, err := newGzipWriter(writer, 5)
-					if err == nil {
-						writer = newWriter
-						defer func() {
-							_ = newWriter.Close()
-						}()
-					}
-				}
+			// 输出日志
+			if this.accessLog {
+				defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
+			}
 
-				http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.FileServer(http.Dir(staticDirCopy.dir+"/"))).ServeHTTP(writer, request)
-			})
-		}
+			openAPIHandler.ServeHTTP(writer, request)
+		})
 	}
 
-	// 加载和动作一致的静态资源
-	serverMux.HandleFunc("/_/", func(writer http.ResponseWriter, request *http.Request) {
-		writer = newResponseWriter(writer)
-
-		// 输出日志
-		if this.accessLog {
-			defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
-		}
+	// 健康检查
+	if len(this.healthPath) > 0 {
+		serverMux.HandleFunc(this.healthPath, this.handleHealth)
+	}
+	if len(this.readyPath) > 0 {
+		serverMux.HandleFunc(this.readyPath, this.handleReady)
+	}
 
+	// 加载和动作一致的静态资源
+	var viewResourceHandler = this.applyMiddlewares(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		ext := strings.ToLower(filepath.Ext(request.URL.Path))
 		if stringutil.Contains([]string{".html", ""}, ext) || strings.HasPrefix(filepath.Base(request.URL.Path), ".") { // 禁止访问html文件、目录、隐藏文件（.xxx）
 			http.Error(writer, "No permission to view page", http.StatusForbidden)
@@ -223,28 +282,9 @@ func (this *Server) StartOn(address string) {
 		}
 		request.URL.Path = strings.TrimPrefix(request.URL.Path, "/_/")
 
-		_, isText := this.outputMimeType(writer, request.URL.Path)
-		if isText {
-			newWriter, err := newGzipWriter(writer, 5)
-			if err == nil {
-				writer = newWriter
-				defer func() {
-					_ = newWriter.Close()
-				}()
-			}
-		}
-
-		http.FileServer(http.Dir(Tea.ViewsDir())).ServeHTTP(writer, request)
-	})
-
-	// 请求处理函数
-	var moduleReg, err = stringutil.RegexpCompile("^/+@([\\w-]+)(/.*)$")
-	if err != nil {
-		panic(err)
-		return
-	}
-
-	serverMux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		this.serveStaticFile(writer, request, Tea.ViewsFS(), "@views", request.URL.Path, staticOptions{})
+	}), this.globalMiddlewares)
+	serverMux.HandleFunc("/_/", func(writer http.ResponseWriter, request *http.Request) {
 		writer = newResponseWriter(writer)
 
 		// 输出日志
@@ -252,105 +292,91 @@ func (this *Server) StartOn(address string) {
 			defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
 		}
 
-		var requestPath = request.URL.Path
-
-		// 模块
-		parsedResult := moduleReg.FindAllStringSubmatch(requestPath, -1)
-		var module = ""
-		if len(parsedResult) > 0 {
-			module = parsedResult[0][1]
-			requestPath = parsedResult[0][2]
-		}
-
-		// 路由key
-		var key string
-		if len(module) > 0 {
-			key = module + "/" + requestPath + "__MELOY__" + request.Method
-		} else {
-			key = requestPath + "__MELOY__" + request.Method
-		}
-
-		runFunc, found := this.directRoutes[key]
-		if found {
-			runFunc(writer, request)
-			return
-		}
-
-		// 支持 *
-		key = requestPath + "__MELOY__*"
-		runFunc, found = this.directRoutes[key]
-		if found {
-			runFunc(writer, request)
-			return
-		}
-
-		// 查找pattern
-		if len(this.patternRoutes) > 0 {
-			for _, route := range this.patternRoutes {
-				if route.method != "*" && route.method != request.Method {
-					continue
-				}
-
-				if route.reg.MatchString(requestPath) {
-					matches := route.reg.FindStringSubmatch(request.URL.Path)
+		// 指标
+		defer this.observeRequest(time.Now(), writer, request, metricsRouteViews, "")
 
-					values := url.Values{}
-					for index, name := range route.names {
-						values.Add(name, matches[index+1])
-					}
+		viewResourceHandler.ServeHTTP(writer, request)
+	})
 
-					if len(values) > 0 {
-						if len(request.URL.RawQuery) == 0 {
-							request.URL.RawQuery = values.Encode()
-						} else {
-							request.URL.RawQuery += "&" + values.Encode()
-						}
-					}
+	// 请求处理函数
+	var rootHandler = this.buildRootHandler()
+	serverMux.HandleFunc("/", rootHandler)
+
+	// 静态资源目录，同一个前缀在不同主机中可以对应不同的目录
+	var staticPrefixes = []string{}
+	for _, host := range this.allHosts() {
+		host.staticHandlers = map[string]http.Handler{}
+		for _, staticDir := range host.staticDirs {
+			var staticDirCopy = staticDir
+			var prefix = staticDirCopy.prefix
+			if len(prefix) == 0 {
+				continue
+			}
+			if !strings.HasPrefix(prefix, "/") {
+				prefix = "/" + prefix
+			}
+			if !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			if _, ok := host.staticHandlers[prefix]; ok {
+				logs.Error(errors.New("static: prefix '" + prefix + "' is already defined"))
+				continue
+			}
 
-					route.runFunc(writer, request)
-					return
-				}
+			var staticFS = os.DirFS(staticDirCopy.dir)
+			host.staticHandlers[prefix] = this.applyMiddlewares(http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				this.serveStaticFile(writer, request, staticFS, staticDirCopy.dir, request.URL.Path, staticDirCopy.options)
+			})), append(append([]func(next http.Handler) http.Handler{}, this.globalMiddlewares...), staticDirCopy.middlewares...))
+			if !lists.ContainsString(staticPrefixes, prefix) {
+				staticPrefixes = append(staticPrefixes, prefix)
 			}
 		}
-
-		// 试图读取静态文件
-		publicFilePath := Tea.PublicFile(requestPath)
-		stat, err := os.Stat(publicFilePath)
-		if err == nil && !stat.IsDir() {
-			_, isText := this.outputMimeType(writer, requestPath)
-
-			// 试着压缩
-			if isText {
-				newWriter, err := newGzipWriter(writer, 5)
-				if err == nil {
-					writer = newWriter
-					defer func() {
-						_ = newWriter.Close()
-					}()
-				}
+	}
+	for _, prefix := range staticPrefixes {
+		var prefixCopy = prefix
+		serverMux.HandleFunc(prefixCopy, func(writer http.ResponseWriter, request *http.Request) {
+			staticHandler, ok := this.matchHost(request.Host).staticHandlers[prefixCopy]
+			if !ok {
+				// 当前主机没有此静态目录
+				rootHandler(writer, request)
+				return
 			}
 
-			http.FileServer(http.Dir(Tea.PublicDir())).ServeHTTP(writer, request)
-			return
-		}
+			writer = newResponseWriter(writer)
 
-		// 处理404的情况
-		this.config.processError(request, writer, http.StatusNotFound, "404 page not found")
-		//http.Error(writer, "404 page not found", http.StatusNotFound)
-		return
+			// 输出日志
+			if this.accessLog {
+				defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
+			}
 
-	})
+			// 指标
+			defer this.observeRequest(time.Now(), writer, request, metricsRouteStatic, "")
+
+			staticHandler.ServeHTTP(writer, request)
+		})
+	}
 
 	// 如果没有指定地址，则从配置中加载
 	if len(address) == 0 {
 		// http
 		if this.config.Http.On {
+			var httpHandler http.Handler = serverMux
+			if this.config.Http.RedirectToHTTPS && this.config.Https.On {
+				httpHandler = this.redirectToHTTPSHandler()
+			}
+
 			for _, addr := range this.config.Http.Listen {
 				logs.Println("start http server on", addr)
 
+				listener, err := this.listen(addr)
+				if err != nil {
+					logs.Error(err)
+					continue
+				}
+
 				server := &http.Server{
 					Addr:              addr,
-					Handler:           serverMux,
+					Handler:           httpHandler,
 					IdleTimeout:       2 * time.Minute,
 					ErrorLog:          this.internalErrorLogger,
 					ReadHeaderTimeout: this.readHeaderTimeout,
@@ -366,8 +392,8 @@ func (this *Server) StartOn(address string) {
 				this.httpServerLocker.Unlock()
 
 				go func() {
-					err := server.ListenAndServe()
-					if err != nil {
+					err := server.Serve(listener)
+					if err != nil && err != http.ErrServerClosed {
 						logs.Error(err)
 					}
 				}()
@@ -375,10 +401,24 @@ func (this *Server) StartOn(address string) {
 		}
 
 		// https
+		var tlsConfig *tls.Config
 		if this.config.Https.On {
+			var err error
+			tlsConfig, err = this.buildTLSConfig()
+			if err != nil {
+				logs.Error(errors.New("tls: " + err.Error()))
+			}
+		}
+		if tlsConfig != nil {
 			for _, addr := range this.config.Https.Listen {
 				logs.Println("start ssl server on", addr)
 
+				listener, err := this.listen(addr)
+				if err != nil {
+					logs.Error(errors.New("tls: " + err.Error()))
+					continue
+				}
+
 				server := &http.Server{
 					Addr:              addr,
 					Handler:           serverMux,
@@ -386,6 +426,7 @@ func (this *Server) StartOn(address string) {
 					ErrorLog:          this.internalErrorLogger,
 					ReadHeaderTimeout: this.readHeaderTimeout,
 					ReadTimeout:       this.readTimeout,
+					TLSConfig:         tlsConfig,
 				}
 
 				if this.connState != nil {
@@ -397,18 +438,9 @@ func (this *Server) StartOn(address string) {
 				this.httpServerLocker.Unlock()
 
 				go func() {
-					certFile := this.config.Https.Cert
-					if !filepath.IsAbs(certFile) {
-						certFile = Tea.Root + Tea.DS + certFile
-					}
-
-					keyFile := this.config.Https.Key
-					if !filepath.IsAbs(keyFile) {
-						keyFile = Tea.Root + Tea.DS + keyFile
-					}
-
-					err := server.ListenAndServeTLS(certFile, keyFile)
-					if err != nil {
+					// 证书由 TLSConfig.GetCertificate 提供
+					err := server.ServeTLS(listener, "", "")
+					if err != nil && err != http.ErrServerClosed {
 						logs.Error(errors.New("tls: " + err.Error()))
 					}
 				}()
@@ -419,13 +451,27 @@ func (this *Server) StartOn(address string) {
 	// 默认地址
 	if len(address) > 0 {
 		logs.Println("start server on", address)
-		go func() {
-			err := http.ListenAndServe(address, serverMux)
-			if err != nil {
-				logs.Errorf(err.Error())
-				time.Sleep(100 * time.Millisecond)
+
+		listener, err := this.listen(address)
+		if err != nil {
+			logs.Errorf(err.Error())
+		} else {
+			server := &http.Server{
+				Addr:    address,
+				Handler: serverMux,
 			}
-		}()
+
+			this.httpServerLocker.Lock()
+			this.httpServers = append(this.httpServers, server)
+			this.httpServerLocker.Unlock()
+
+			go func() {
+				err := server.Serve(listener)
+				if err != nil && err != http.ErrServerClosed {
+					logs.Errorf(err.Error())
+				}
+			}()
+		}
 	}
 
 	// 日志
@@ -436,28 +482,94 @@ func (this *Server) StartOn(address string) {
 	// 启动任务管理器
 	//tasks.Start(runtime.NumCPU() * 4)
 
-	// 等待
-	select {}
-}
+	// 平滑重启时通知上一个进程
+	this.notifyReady()
 
-func (this *Server) Stop() {
-	// stop servers
-	this.httpServerLocker.Lock()
-	for _, server := range this.httpServers {
-		_ = server.Close()
+	atomic.StoreInt32(&this.started, 1)
+
+	// 等待停止信号
+	var signalChan = make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
+
+	var upgradeChan = make(chan os.Signal, 1)
+	this.notifyUpgrade(upgradeChan)
+	defer signal.Stop(upgradeChan)
+
+	for {
+		select {
+		case sig := <-signalChan:
+			logs.Println("received signal '" + sig.String() + "', shutting down ...")
+			this.Stop()
+			return
+		case <-upgradeChan:
+			logs.Println("upgrading ...")
+			err := this.upgrade()
+			if err != nil {
+				logs.Error(errors.New("upgrade: " + err.Error()))
+				continue
+			}
+			this.Stop()
+			return
+		case <-this.stopChan:
+			return
+		}
 	}
-	this.httpServerLocker.Unlock()
+}
 
-	// call stop Functions
-	beforeStopOnce.Do(func() {
-		locker := sync.Mutex{}
-		if len(beforeStopFunctions) > 0 {
-			for _, fn := range beforeStopFunctions {
-				locker.Lock()
-				fn(this)
-				locker.Unlock()
+// Stop 停止服务
+// 先等待正在处理的请求结束（最长等待时间由 ShutdownTimeout() 设置），再依次执行 BeforeStop() 中的函数、写出日志、关闭数据库连接
+func (this *Server) Stop() {
+	this.stopOnce.Do(func() {
+		// 不再接受就绪检查
+		atomic.StoreInt32(&this.stopping, 1)
+
+		// stop servers
+		this.httpServerLocker.Lock()
+		var servers = append([]*http.Server{}, this.httpServers...)
+		this.httpServerLocker.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), this.shutdownTimeout)
+		var wg = sync.WaitGroup{}
+		for _, server := range servers {
+			wg.Add(1)
+			go func(server *http.Server) {
+				defer wg.Done()
+
+				err := server.Shutdown(ctx)
+				if err != nil {
+					logs.Error(errors.New("shutdown '" + server.Addr + "': " + err.Error()))
+					_ = server.Close()
+				}
+			}(server)
+		}
+		wg.Wait()
+		cancel()
+
+		// call stop Functions
+		beforeStopOnce.Do(func() {
+			locker := sync.Mutex{}
+			if len(beforeStopFunctions) > 0 {
+				for _, fn := range beforeStopFunctions {
+					locker.Lock()
+					fn(this)
+					locker.Unlock()
+				}
 			}
+		})
+
+		// flush logs
+		if flusher, ok := this.logWriter.(LogFlusher); ok {
+			flusher.Flush()
 		}
+
+		// close databases
+		err := dbs.CloseAll()
+		if err != nil {
+			logs.Error(errors.New("close databases: " + err.Error()))
+		}
+
+		close(this.stopChan)
 	})
 }
 
@@ -480,48 +592,209 @@ func (this *Server) router(pattern string, method string, actionPtr interface{})
 
 	method = strings.ToUpper(method)
 
-	// 是否包含匹配参数 :paramName(pattern)
-	reg, err := regexp.Compile(`:(\w+)(\s*(\([^)]+\))?)`)
+	tree, ok := this.lastHost.routeTrees[this.lastModule]
+	if !ok {
+		tree = newRouteTree()
+		this.lastHost.routeTrees[this.lastModule] = tree
+	}
+	var runFunc, spec = this.buildHandle(actionPtr)
+	if len(this.lastMiddlewares) > 0 {
+		runFunc = this.applyMiddlewares(http.HandlerFunc(runFunc), this.currentMiddlewares()).ServeHTTP
+	}
+	var route = &serverRoute{
+		host:    this.lastHost.pattern,
+		module:  this.lastModule,
+		pattern: pattern,
+		method:  method,
+		spec:    spec,
+		runFunc: runFunc,
+	}
+	err := tree.add(route)
 	if err != nil {
-		logs.Errorf("%s", err.Error())
-		return
+		err = errors.New("router: " + err.Error())
+		logs.Error(err)
+		this.routeErrors = append(this.routeErrors, err)
 	}
 
-	matches := reg.FindAllStringSubmatch(pattern, 10)
-	names := []string{}
-	for _, match := range matches {
-		names = append(names, match[1])
-		if len(match[3]) > 0 {
-			pattern = strings.Replace(pattern, match[0], match[3], 10)
-		} else {
-			pattern = strings.Replace(pattern, match[0], "([^/]+)", 10)
+	// 检查需要注入的服务，以及参数中的 validate 标签
+	if spec != nil {
+		for _, err := range append(this.container.Check(spec), spec.ValidateErrors()...) {
+			err = errors.New("router: '" + pattern + "': " + err.Error())
+			logs.Error(err)
+			this.routeErrors = append(this.routeErrors, err)
 		}
 	}
+	this.lastRoute = route
+}
 
-	reg, err = regexp.Compile("^" + pattern + "$")
-	if err == nil && len(names) > 0 {
-		var routePattern = ServerRoutePattern{
-			module:  this.lastModule,
-			reg:     *reg,
-			names:   names,
-			method:  method,
-			runFunc: this.buildHandle(actionPtr),
-		}
-		this.patternRoutes = append(this.patternRoutes, routePattern)
-		return
+// Name 为最近一次定义的路由设置名称，以便用 URL() 生成地址
+func (this *Server) Name(name string) *Server {
+	this.routerLocker.Lock()
+	defer this.routerLocker.Unlock()
+
+	if this.lastRoute == nil {
+		logs.Error(errors.New("router: no route to name '" + name + "'"))
+		return this
 	}
 
-	// 正常的路由
-	var key string
-	if len(this.lastModule) > 0 {
-		key = this.lastModule + "/" + pattern + "__MELOY__" + method
-	} else {
-		key = pattern + "__MELOY__" + method
+	existRoute, ok := this.namedRoutes[name]
+	if ok && (existRoute.pattern != this.lastRoute.pattern || existRoute.module != this.lastRoute.module || existRoute.host != this.lastRoute.host) {
+		err := errors.New("router: route name '" + name + "' is already used by '" + existRoute.pattern + "'")
+		logs.Error(err)
+		this.routeErrors = append(this.routeErrors, err)
+		return this
 	}
-	this.directRoutes[key] = this.buildHandle(actionPtr)
+
+	this.lastRoute.name = name
+	this.namedRoutes[name] = this.lastRoute
+	return this
 }
 
-func (this *Server) buildHandle(actionPtr interface{}) func(writer http.ResponseWriter, request *http.Request) {
+// URL 根据路由名称和参数生成URL，路由中没有用到的参数会放在查询字符串中
+func (this *Server) URL(name string, params map[string]interface{}) (string, error) {
+	this.routerLocker.Lock()
+	route, ok := this.namedRoutes[name]
+	this.routerLocker.Unlock()
+	if !ok {
+		return "", errors.New("route '" + name + "' not found")
+	}
+
+	path, err := buildRoutePath(route.pattern, params)
+	if err != nil {
+		return "", errors.New("route '" + name + "': " + err.Error())
+	}
+
+	if len(route.module) > 0 {
+		path = "/@" + route.module + path
+	}
+	return path, nil
+}
+
+// RouteErrors 取得注册路由时发生的错误，比如重复或有歧义的路由，有错误时 StartOn() 不会启动服务
+func (this *Server) RouteErrors() []error {
+	return this.routeErrors
+}
+
+// 请求匹配到的路由，用来记录指标
+type requestRoute struct {
+	route  string
+	module string
+}
+
+type requestRouteKey struct{}
+
+// 构造根处理函数，查找路由、返回405和读取静态文件等都在 UseGlobal() 添加的全局中间件中执行，
+// 以便中间件可以处理路由中的panic、OPTIONS预检请求等
+func (this *Server) buildRootHandler() http.HandlerFunc {
+	var moduleReg, err = stringutil.RegexpCompile("^/+@([\\w-]+)(/.*)$")
+	if err != nil {
+		panic(err)
+	}
+
+	// 静态文件和404
+	var publicHandler = http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// 试图读取静态文件
+		var requestPath = request.URL.Path
+		var publicFS = Tea.PublicFS()
+		var publicFileName = strings.TrimPrefix(path.Clean("/"+requestPath), "/")
+		if len(publicFileName) > 0 {
+			stat, err := fs.Stat(publicFS, publicFileName)
+			if err == nil && !stat.IsDir() {
+				this.serveStaticFile(writer, request, publicFS, "@public", requestPath, staticOptions{})
+				return
+			}
+		}
+
+		// 处理404的情况
+		var module = ""
+		parsedResult := moduleReg.FindStringSubmatch(requestPath)
+		if len(parsedResult) > 0 {
+			module = parsedResult[1]
+		}
+		this.config.processError(request, writer, this.matchHost(request.Host).pattern, module, http.StatusNotFound, "404 page not found", nil)
+	})
+
+	var dispatchHandler = this.applyMiddlewares(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var requestPath = request.URL.Path
+
+		// 模块
+		parsedResult := moduleReg.FindAllStringSubmatch(requestPath, -1)
+		var module = ""
+		if len(parsedResult) > 0 {
+			module = parsedResult[0][1]
+			requestPath = parsedResult[0][2]
+		}
+
+		// 查找路由
+		var host = this.matchHost(request.Host)
+		tree, ok := host.routeTrees[module]
+		if ok {
+			node, values := tree.lookup(requestPath)
+			if node != nil {
+				route := node.route(request.Method)
+				if route == nil {
+					writer.Header().Set("Allow", strings.Join(node.allowMethods(), ", "))
+					this.config.processError(request, writer, host.pattern, module, http.StatusMethodNotAllowed, "405 method not allowed", nil)
+					return
+				}
+
+				// 没有匹配到路由时不记录模块，防止客户端随意生成指标标签
+				matchedRoute, ok := request.Context().Value(requestRouteKey{}).(*requestRoute)
+				if ok {
+					matchedRoute.route = route.pattern
+					matchedRoute.module = module
+				}
+
+				if len(values) > 0 {
+					if len(request.URL.RawQuery) == 0 {
+						request.URL.RawQuery = values.Encode()
+					} else {
+						request.URL.RawQuery += "&" + values.Encode()
+					}
+				}
+
+				// 压缩，升级协议（比如WebSocket）的请求不压缩
+				var encoding = actions.NegotiateEncoding(request.Header.Get("Accept-Encoding"), compressEncodings)
+				if len(encoding) > 0 && len(request.Header.Get("Upgrade")) == 0 {
+					var compressWriter = newCompressWriter(writer, request, encoding)
+					defer func() {
+						_ = compressWriter.Close()
+					}()
+					writer = compressWriter
+				}
+
+				route.runFunc(writer, request)
+				return
+			}
+		}
+
+		publicHandler.ServeHTTP(writer, request)
+	}), this.globalMiddlewares)
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer = newResponseWriter(writer)
+
+		// 输出日志
+		if this.accessLog {
+			defer this.logWriter.Print(time.Now(), writer.(*responseWriter), request)
+		}
+
+		// 指标
+		if len(this.metricsPath) > 0 {
+			var matchedRoute = &requestRoute{route: metricsRoutePublic}
+			request = request.WithContext(context.WithValue(request.Context(), requestRouteKey{}, matchedRoute))
+			var responseWriter = writer
+			defer func(startTime time.Time) {
+				this.observeRequest(startTime, responseWriter, request, matchedRoute.route, matchedRoute.module)
+			}(time.Now())
+		}
+
+		dispatchHandler.ServeHTTP(writer, request)
+	}
+}
+
+// 构造路由处理函数，如果是Action则同时返回Action定义
+func (this *Server) buildHandle(actionPtr interface{}) (handle func(writer http.ResponseWriter, request *http.Request), spec *actions.ActionSpec) {
 	// 是否为函数
 	if reflect.TypeOf(actionPtr).Kind() == reflect.Func {
 		{
@@ -529,7 +802,7 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			if ok {
 				return func(writer http.ResponseWriter, request *http.Request) {
 					f(request, writer)
-				}
+				}, nil
 			}
 		}
 
@@ -538,7 +811,7 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			if ok {
 				return func(writer http.ResponseWriter, request *http.Request) {
 					f(writer, request)
-				}
+				}, nil
 			}
 		}
 
@@ -547,7 +820,7 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			if ok {
 				return func(writer http.ResponseWriter, request *http.Request) {
 					f(request)
-				}
+				}, nil
 			}
 		}
 
@@ -556,7 +829,7 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			if ok {
 				return func(writer http.ResponseWriter, request *http.Request) {
 					f(writer)
-				}
+				}, nil
 			}
 		}
 
@@ -565,13 +838,13 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			if ok {
 				return func(writer http.ResponseWriter, request *http.Request) {
 					f()
-				}
+				}, nil
 			}
 		}
 
 		panic("invalid handle function")
 
-		return nil
+		return nil, nil
 	}
 
 	actionWrapper, ok := actionPtr.(actions.ActionWrapper)
@@ -579,11 +852,27 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 		logs.Errorf("actionPtr should be pointer")
 		return func(writer http.ResponseWriter, request *http.Request) {
 
-		}
+		}, nil
 	}
 
-	spec := actions.NewActionSpec(actionPtr.(actions.ActionWrapper))
+	spec = actions.NewActionSpec(actionPtr.(actions.ActionWrapper))
 	spec.Module = this.lastModule
+	spec.Host = this.lastHost.pattern
+	spec.URLBuilder = this.URL
+	spec.Timeout = this.lastTimeout
+	spec.Container = this.container
+	spec.StreamUpload = this.lastStreamUpload || this.config.Upload.Stream
+	spec.UploadProgress = this.lastUploadProgress
+
+	var module = this.lastModule
+	var host = this.lastHost
+	spec.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, code int, err error) {
+		// 已经有输出时不再输出错误页面
+		if isResponseWritten(writer) {
+			return
+		}
+		this.config.processError(request, writer, host.pattern, module, code, strconv.Itoa(code)+" "+http.StatusText(code), err)
+	}
 
 	var helpers = append([]interface{}{}, this.lastHelpers...)
 	var data = actions.Data{}
@@ -600,8 +889,8 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 			params[key] = values
 		}
 
-		// POST参数
-		if request.Method == "POST" {
+		// POST参数，流式上传的文件由Action读取
+		if request.Method == "POST" && !(spec.StreamUpload && isMultipartRequest(request)) {
 			var maxSize = int64(this.config.MaxSize())
 			if maxSize <= 0 {
 				maxSize = 32 << 20
@@ -620,33 +909,40 @@ func (this *Server) buildHandle(actionPtr interface{}) func(writer http.Response
 
 		var actionObject = actionWrapper.Object()
 		actionObject.SetMaxSize(this.config.MaxSize())
-		actionObject.SetSessionManager(this.sessionManager)
-		actionObject.SetSessionCookieName(this.sessionCookieName)
+		sessionManager, sessionCookieName := this.hostSession(host)
+		actionObject.SetSessionManager(sessionManager)
+		actionObject.SetSessionCookieName(sessionCookieName)
 
 		actions.RunAction(actionPtr, spec, request, writer, params, helpers, data)
-	}
+	}, spec
 }
 
 // Module 设置模块定义开始
 func (this *Server) Module(module string) *Server {
+	this.EndModule()
 	this.lastModule = module
+	this.beginMiddlewareScope(middlewareScopeModule)
 	return this
 }
 
-// EndModule 设置模块定义结束
+// EndModule 设置模块定义结束，同时移除在模块中定义的中间件
 func (this *Server) EndModule() *Server {
+	this.endMiddlewareScope(middlewareScopeModule)
 	this.lastModule = ""
 	return this
 }
 
 // Prefix 设置URL前缀
 func (this *Server) Prefix(prefix string) *Server {
+	this.EndPrefix()
 	this.lastPrefix = prefix
+	this.beginMiddlewareScope(middlewareScopePrefix)
 	return this
 }
 
-// EndPrefix 结束前缀定义
+// EndPrefix 结束前缀定义，同时移除在前缀中定义的中间件
 func (this *Server) EndPrefix() *Server {
+	this.endMiddlewareScope(middlewareScopePrefix)
 	this.lastPrefix = ""
 	return this
 }
@@ -666,6 +962,75 @@ func (this *Server) EndData() *Server {
 	return this
 }
 
+// Timeout 设置此后定义的路由中动作的超时时间，直到调用 EndTimeout() 或 EndAll()
+// 超时后通过 ActionObject.Ctx() 取得的上下文会被取消，使用此上下文的数据库查询等操作会被中止
+func (this *Server) Timeout(timeout time.Duration) *Server {
+	this.lastTimeout = timeout
+	return this
+}
+
+// EndTimeout 结束超时时间定义
+func (this *Server) EndTimeout() *Server {
+	this.lastTimeout = 0
+	return this
+}
+
+// StreamUpload 此后定义的路由中上传的文件直接写入到 Tea.TmpDir() 中，而不是先缓存在内存中，直到调用 EndStreamUpload() 或 EndAll()
+// 请求的总尺寸受配置中的 upload.maxSize 限制（没有设置时为32M），
+// 可以在 *actions.File、actions.File 或 []*actions.File 参数中使用 maxSize、maxFiles 和 mime 标签限制上传的文件，没有对应参数的文件会被丢弃，
+// 临时文件会在 After() 之后被删除，需要保留时使用 File.MoveTo() 移动
+func (this *Server) StreamUpload() *Server {
+	this.lastStreamUpload = true
+	return this
+}
+
+// UploadProgress 设置此后定义的路由中流式上传的进度回调
+func (this *Server) UploadProgress(progressFunc actions.UploadProgressFunc) *Server {
+	this.lastUploadProgress = progressFunc
+	return this
+}
+
+// EndStreamUpload 结束流式上传定义
+func (this *Server) EndStreamUpload() *Server {
+	this.lastStreamUpload = false
+	this.lastUploadProgress = nil
+	return this
+}
+
+// 判断是否为 multipart/form-data 请求，不区分大小写
+func isMultipartRequest(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// Singleton 注册单例服务，Action中带有 inject:"" 标签的字段或Run()参数会被自动注入
+// as 用来同时注册为某些接口类型，比如 server.Singleton(store, (*Store)(nil))
+// 需要在定义路由之前注册，以便在定义路由时检查缺少的服务
+func (this *Server) Singleton(value interface{}, as ...interface{}) *Server {
+	err := this.container.Singleton(value, as...)
+	if err != nil {
+		logs.Error(err)
+		this.routeErrors = append(this.routeErrors, err)
+	}
+	return this
+}
+
+// Factory 注册请求范围的服务，工厂函数在每个请求中最多执行一次，
+// 生成的值如果有 Close() 方法，会在 After() 之后被关闭
+func (this *Server) Factory(factory interface{}, as ...interface{}) *Server {
+	err := this.container.Factory(factory, as...)
+	if err != nil {
+		logs.Error(err)
+		this.routeErrors = append(this.routeErrors, err)
+	}
+	return this
+}
+
+// Container 取得依赖注入容器
+func (this *Server) Container() *actions.Container {
+	return this.container
+}
+
 // Helper 定义助手
 func (this *Server) Helper(helper interface{}) *Server {
 	if helper == nil {
@@ -699,12 +1064,84 @@ func (this *Server) EndHelpers() *Server {
 	return this
 }
 
+// Use 添加中间件，只对此后定义的路由和静态目录有效，和 Helper() 一样按照定义的顺序生效
+// 在 Host()、Module() 或 Prefix() 中添加的中间件在对应的 EndXxx() 时移除，其他的中间件在 EndMiddlewares() 或 EndAll() 时移除
+func (this *Server) Use(middleware func(next http.Handler) http.Handler) *Server {
+	if middleware == nil {
+		logs.Error(errors.New("you try to add a nil middleware"))
+		return this
+	}
+
+	var scope = ""
+	if len(this.middlewareScopes) > 0 {
+		scope = this.middlewareScopes[len(this.middlewareScopes)-1]
+	}
+	this.lastMiddlewares = append(this.lastMiddlewares, scopedMiddleware{
+		scope:      scope,
+		middleware: middleware,
+	})
+	return this
+}
+
+// UseGlobal 添加全局中间件，作用于所有请求，包括查找路由、405和404页面、/_/ 和 public 目录下的文件，
+// 因此可以用来捕获路由中的panic、处理OPTIONS预检请求等；全局中间件不受定义的顺序和 EndAll() 影响
+func (this *Server) UseGlobal(middleware func(next http.Handler) http.Handler) *Server {
+	if middleware == nil {
+		logs.Error(errors.New("you try to add a nil middleware"))
+		return this
+	}
+	this.globalMiddlewares = append(this.globalMiddlewares, middleware)
+	return this
+}
+
+// 开始 Host()、Module() 或 Prefix() 作用域
+func (this *Server) beginMiddlewareScope(scope string) {
+	this.middlewareScopes = append(this.middlewareScopes, scope)
+}
+
+// 结束作用域，同时移除在此作用域中添加的中间件，其他仍然打开的作用域中的中间件保持不变
+func (this *Server) endMiddlewareScope(scope string) {
+	for i := len(this.middlewareScopes) - 1; i >= 0; i-- {
+		if this.middlewareScopes[i] == scope {
+			this.middlewareScopes = append(this.middlewareScopes[:i], this.middlewareScopes[i+1:]...)
+			break
+		}
+	}
+
+	var middlewares = []scopedMiddleware{}
+	for _, middleware := range this.lastMiddlewares {
+		if middleware.scope != scope {
+			middlewares = append(middlewares, middleware)
+		}
+	}
+	this.lastMiddlewares = middlewares
+}
+
+// 当前定义的路由和静态目录使用的中间件
+func (this *Server) currentMiddlewares() []func(next http.Handler) http.Handler {
+	var middlewares = []func(next http.Handler) http.Handler{}
+	for _, middleware := range this.lastMiddlewares {
+		middlewares = append(middlewares, middleware.middleware)
+	}
+	return middlewares
+}
+
+// EndMiddlewares 结束中间件定义
+func (this *Server) EndMiddlewares() *Server {
+	this.lastMiddlewares = nil
+	return this
+}
+
 // EndAll 结束所有定义
 func (this *Server) EndAll() *Server {
+	this.EndHost()
 	this.EndPrefix()
 	this.EndModule()
 	this.EndHelpers()
 	this.EndData()
+	this.EndTimeout()
+	this.EndStreamUpload()
+	this.EndMiddlewares()
 	return this
 }
 
@@ -783,23 +1220,46 @@ func (this *Server) All(path string, actionPtr interface{}) *Server {
 
 // Static 添加静态目录
 func (this *Server) Static(prefix string, dir string) *Server {
-	this.staticDirs = append(this.staticDirs, ServerStaticDir{
-		prefix: prefix,
-		dir:    dir,
+	this.lastHost.staticDirs = append(this.lastHost.staticDirs, ServerStaticDir{
+		prefix:      prefix,
+		dir:         dir,
+		middlewares: this.currentMiddlewares(),
 	})
 	return this
 }
 
+// StaticCacheControl 设置上一个静态资源目录输出的Cache-Control，比如 public, max-age=86400
+func (this *Server) StaticCacheControl(cacheControl string) *Server {
+	var staticDirs = this.lastHost.staticDirs
+	if len(staticDirs) == 0 {
+		logs.Error(errors.New("static: StaticCacheControl() should be called after Static()"))
+		return this
+	}
+	staticDirs[len(staticDirs)-1].options.cacheControl = cacheControl
+	return this
+}
+
+// StaticETag 设置上一个静态资源目录是否输出ETag并支持If-None-Match
+func (this *Server) StaticETag(on bool) *Server {
+	var staticDirs = this.lastHost.staticDirs
+	if len(staticDirs) == 0 {
+		logs.Error(errors.New("static: StaticETag() should be called after Static()"))
+		return this
+	}
+	staticDirs[len(staticDirs)-1].options.etag = on
+	return this
+}
+
 // ConnState 连接状态
 func (this *Server) ConnState(connState func(conn net.Conn, state http.ConnState)) *Server {
 	this.connState = connState
 	return this
 }
 
-// Session 设置SESSION管理器
+// Session 设置SESSION管理器，在 Host() 中调用时只对当前主机有效
 func (this *Server) Session(sessionManager interface{}, cookieName string) *Server {
-	this.sessionManager = sessionManager
-	this.sessionCookieName = cookieName
+	this.lastHost.sessionManager = sessionManager
+	this.lastHost.sessionCookieName = cookieName
 	return this
 }
 
@@ -835,6 +1295,20 @@ func (this *Server) ReadTimeout(timeout time.Duration) *Server {
 	return this
 }
 
+// ShutdownTimeout 设置停止服务时等待请求处理完成的最长时间
+func (this *Server) ShutdownTimeout(timeout time.Duration) *Server {
+	this.shutdownTimeout = timeout
+	return this
+}
+
+// 使用中间件包装处理器，先添加的中间件在最外层
+func (this *Server) applyMiddlewares(handler http.Handler, middlewares []func(next http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
 func (this *Server) outputMimeType(writer http.ResponseWriter, path string) (mimeType string, isText bool) {
 	ext := filepath.Ext(path)
 	if len(ext) == 0 {
@@ -892,13 +1366,24 @@ func (this *Server) execArgs() {
 
 		this.writeNewPid(0)
 
-		_ = process.Kill()
-
-		log.Println("kill pid", pid)
+		// 优先使用 SIGTERM 让服务优雅地停止，不支持时（比如Windows）直接结束进程
+		err = process.Signal(syscall.SIGTERM)
+		if err != nil {
+			_ = process.Kill()
+			log.Println("kill pid", pid)
+		} else {
+			log.Println("stop pid", pid)
+		}
 
 		return
 	} else if arg == "restart" { // 重启
 		pid := this.findPid()
+
+		// 优先平滑重启
+		if pid > 0 && this.signalRestart(pid) {
+			os.Exit(0)
+		}
+
 		if pid > 0 {
 			process, err := os.FindProcess(pid)
 			if err == nil {
@@ -956,31 +1441,55 @@ func (this *Server) checkPid() {
 
 // 写入当前程序的PID，以便后续的管理
 func (this *Server) writePid() {
-	pid := os.Getpid()
-	pidDir := files.NewFile(Tea.Root + "/bin")
-	if !pidDir.IsDir() {
-		err := pidDir.Mkdir()
-		if err == nil {
-			pidFile := pidDir.Child("pid")
-			_ = pidFile.WriteFormat("%d", pid)
-		}
-	} else {
-		pidFile := pidDir.Child("pid")
-		_ = pidFile.WriteFormat("%d", pid)
-	}
+	this.writeNewPid(os.Getpid())
 }
 
 // 写入新的PID
+// 先写入临时文件再改名，防止其他进程读到不完整的内容
 func (this *Server) writeNewPid(pid int) {
 	pidDir := files.NewFile(Tea.Root + "/bin")
 	if !pidDir.IsDir() {
 		err := pidDir.Mkdir()
-		if err == nil {
-			pidFile := pidDir.Child("pid")
-			_ = pidFile.WriteFormat("%d", pid)
+		if err != nil {
+			return
 		}
-	} else {
-		pidFile := pidDir.Child("pid")
-		_ = pidFile.WriteFormat("%d", pid)
 	}
+
+	tmpFile := pidDir.Child("pid." + strconv.Itoa(os.Getpid()) + ".tmp")
+	err := tmpFile.WriteFormat("%d", pid)
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmpFile.Path(), pidDir.Child("pid").Path())
+	if err != nil {
+		_ = tmpFile.Delete()
+	}
+}
+
+// 监听某个地址，优先使用从上一个进程继承的监听
+func (this *Server) listen(addr string) (net.Listener, error) {
+	parsedAddr, err := parseListenAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := takeInheritedListener(addr)
+	if listener == nil {
+		listener, err = parsedAddr.listen()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	this.httpServerLocker.Lock()
+	this.listeners[addr] = listener
+	this.httpServerLocker.Unlock()
+
+	if parsedAddr.proxyProtocol {
+		return &proxyProtocolListener{
+			Listener:        listener,
+			allowedNetworks: parsedAddr.proxyProtocolFrom,
+		}, nil
+	}
+	return listener, nil
 }
@@ -0,0 +1,203 @@
+package TeaGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/iwind/TeaGo/dbs"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReadyCheckFunc 就绪检查函数，返回错误表示未就绪
+type ReadyCheckFunc func(ctx context.Context) error
+
+type readyCheck struct {
+	name  string
+	check ReadyCheckFunc
+}
+
+// 单个检查的结果
+type readyCheckResult struct {
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Error  string  `json:"error,omitempty"`
+	Cost   float64 `json:"cost"` // 毫秒
+}
+
+// Health 设置存活（liveness）和就绪（readiness）检查的路径，比如 /healthz 和 /readyz，路径为空表示不开启
+// 服务启动并且调用了 dbs.NotifyReady() 之后才会就绪，此时会依次检查配置中的数据库和通过 ReadyCheck() 添加的检查
+// 服务开始停止时立即变为未就绪
+// 这两个路径不经过中间件，也不记录访问日志
+func (this *Server) Health(healthPath string, readyPath string) *Server {
+	this.healthPath = healthPath
+	this.readyPath = readyPath
+	return this
+}
+
+// ReadyCheck 添加一个就绪检查
+func (this *Server) ReadyCheck(name string, check ReadyCheckFunc) *Server {
+	this.readyChecks = append(this.readyChecks, readyCheck{
+		name:  name,
+		check: check,
+	})
+	return this
+}
+
+// ReadyCheckTimeout 设置每个就绪检查的超时时间，默认为5秒
+func (this *Server) ReadyCheckTimeout(timeout time.Duration) *Server {
+	this.readyCheckTimeout = timeout
+	return this
+}
+
+// 处理存活检查
+func (this *Server) handleHealth(writer http.ResponseWriter, request *http.Request) {
+	this.writeHealthJSON(writer, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// 处理就绪检查
+func (this *Server) handleReady(writer http.ResponseWriter, request *http.Request) {
+	var results = this.runReadyChecks(request.Context())
+
+	var status = "ready"
+	var code = http.StatusOK
+	for _, result := range results {
+		if result.Status != "ok" {
+			status = "not ready"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	this.writeHealthJSON(writer, code, map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// 执行所有的就绪检查
+func (this *Server) runReadyChecks(ctx context.Context) []*readyCheckResult {
+	// 服务状态
+	var serverResult = &readyCheckResult{
+		Name:   "server",
+		Status: "ok",
+	}
+	if atomic.LoadInt32(&this.stopping) == 1 {
+		serverResult.Status = "error"
+		serverResult.Error = "server is shutting down"
+		return []*readyCheckResult{serverResult}
+	}
+	if atomic.LoadInt32(&this.started) == 0 {
+		serverResult.Status = "error"
+		serverResult.Error = "server is starting"
+		return []*readyCheckResult{serverResult}
+	}
+
+	var dbsResult = &readyCheckResult{
+		Name:   "dbs",
+		Status: "ok",
+	}
+	if !dbs.IsReady() {
+		dbsResult.Status = "error"
+		dbsResult.Error = "waiting for dbs.NotifyReady()"
+		return []*readyCheckResult{serverResult, dbsResult}
+	}
+
+	// 数据库
+	var checks = []readyCheck{}
+	var dbIds = []string{}
+	for dbId := range dbs.GlobalConfig().DBs {
+		dbIds = append(dbIds, dbId)
+	}
+	sort.Strings(dbIds)
+	for _, dbId := range dbIds {
+		var dbIdCopy = dbId
+		checks = append(checks, readyCheck{
+			name: "db:" + dbIdCopy,
+			check: func(ctx context.Context) error {
+				db, err := dbs.Instance(dbIdCopy)
+				if err != nil {
+					return err
+				}
+				return db.Ping(ctx)
+			},
+		})
+	}
+	checks = append(checks, this.readyChecks...)
+
+	var timeout = this.readyCheckTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var results = make([]*readyCheckResult, len(checks))
+	var wg = sync.WaitGroup{}
+	for index, check := range checks {
+		wg.Add(1)
+		go func(index int, check readyCheck) {
+			defer wg.Done()
+			results[index] = runReadyCheck(ctx, check, timeout)
+		}(index, check)
+	}
+	wg.Wait()
+
+	return append([]*readyCheckResult{serverResult, dbsResult}, results...)
+}
+
+// 执行单个检查，超时后不再等待检查函数返回
+func runReadyCheck(ctx context.Context, check readyCheck, timeout time.Duration) *readyCheckResult {
+	var result = &readyCheckResult{
+		Name:   check.name,
+		Status: "ok",
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var startTime = time.Now()
+	var errChan = make(chan error, 1)
+	go func() {
+		defer func() {
+			r := recover()
+			if r != nil {
+				errChan <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errChan <- check.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+		if err == context.DeadlineExceeded {
+			err = errors.New("timeout after " + timeout.String())
+		}
+	}
+	result.Cost = float64(time.Since(startTime).Microseconds()) / 1000
+
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (this *Server) writeHealthJSON(writer http.ResponseWriter, code int, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(code)
+	_, _ = writer.Write(body)
+}
@@ -0,0 +1,85 @@
+package TeaGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/iwind/TeaGo/dbs"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_Health(t *testing.T) {
+	server := NewServer(false)
+	server.Health("/healthz", "/readyz").
+		ReadyCheckTimeout(100*time.Millisecond).
+		ReadyCheck("ok", func(ctx context.Context) error {
+			return nil
+		}).
+		ReadyCheck("slow", func(ctx context.Context) error {
+			time.Sleep(1 * time.Second)
+			return nil
+		}).
+		ReadyCheck("failed", func(ctx context.Context) error {
+			return errors.New("connection refused")
+		})
+
+	var ready = func() (int, map[string]interface{}) {
+		var recorder = httptest.NewRecorder()
+		server.handleReady(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var result = map[string]interface{}{}
+		err := json.Unmarshal(recorder.Body.Bytes(), &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(recorder.Code, recorder.Body.String())
+		return recorder.Code, result
+	}
+
+	// 存活
+	var recorder = httptest.NewRecorder()
+	server.handleHealth(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatal("unexpected code:", recorder.Code)
+	}
+
+	// 未启动
+	code, _ := ready()
+	if code != http.StatusServiceUnavailable {
+		t.Fatal("should not be ready before starting")
+	}
+
+	// 未调用NotifyReady
+	atomic.StoreInt32(&server.started, 1)
+	if !dbs.IsReady() {
+		code, _ = ready()
+		if code != http.StatusServiceUnavailable {
+			t.Fatal("should not be ready before dbs.NotifyReady()")
+		}
+		dbs.NotifyReady()
+	}
+
+	code, result := ready()
+	if code != http.StatusServiceUnavailable {
+		t.Fatal("should not be ready with failed checks")
+	}
+	var errorsMap = map[string]string{}
+	for _, check := range result["checks"].([]interface{}) {
+		var checkMap = check.(map[string]interface{})
+		errorString, _ := checkMap["error"].(string)
+		errorsMap[checkMap["name"].(string)] = errorString
+	}
+	if errorsMap["ok"] != "" || errorsMap["slow"] != "timeout after 100ms" || errorsMap["failed"] != "connection refused" {
+		t.Fatal("unexpected checks:", errorsMap)
+	}
+
+	// 停止中
+	atomic.StoreInt32(&server.stopping, 1)
+	code, result = ready()
+	if code != http.StatusServiceUnavailable || len(result["checks"].([]interface{})) != 1 {
+		t.Fatal("should not be ready when shutting down")
+	}
+}
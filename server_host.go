@@ -0,0 +1,126 @@
+package TeaGo
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/logs"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 虚拟主机
+type serverHost struct {
+	pattern string // 域名，比如 admin.example.com 或 *.example.com，默认主机为空
+
+	routeTrees map[string]*routeTree // module => tree
+	staticDirs []ServerStaticDir
+
+	sessionManager    interface{}
+	sessionCookieName string
+
+	staticHandlers map[string]http.Handler // prefix => handler，启动时构造
+}
+
+func newServerHost(pattern string) *serverHost {
+	return &serverHost{
+		pattern:    pattern,
+		routeTrees: map[string]*routeTree{},
+		staticDirs: []ServerStaticDir{},
+	}
+}
+
+// 判断域名是否匹配
+func (this *serverHost) match(hostname string) bool {
+	if this.pattern == hostname {
+		return true
+	}
+	if strings.HasPrefix(this.pattern, "*.") {
+		var suffix = this.pattern[1:]
+		return len(hostname) > len(suffix) && strings.HasSuffix(hostname, suffix)
+	}
+	return false
+}
+
+// Host 设置虚拟主机定义开始
+// 此后定义的路由、静态目录和SESSION设置只对此主机有效，直到调用 EndHost() 或 EndAll()
+// pattern 可以是完整的域名，比如 admin.example.com，也可以使用通配符，比如 *.example.com，完整的域名优先匹配
+// 没有匹配到任何主机的请求由 Host() 之外定义的路由（即默认主机）处理
+// 错误页面可以在配置文件的 errors 中以域名为键单独设置，比如 errors."admin.example.com"."404"
+func (this *Server) Host(pattern string) *Server {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if len(pattern) == 0 {
+		return this.EndHost()
+	}
+	if strings.Contains(pattern[1:], "*") || (strings.HasPrefix(pattern, "*") && !strings.HasPrefix(pattern, "*.")) {
+		err := errors.New("host: invalid pattern '" + pattern + "', wildcard should be like '*.example.com'")
+		logs.Error(err)
+		this.routeErrors = append(this.routeErrors, err)
+	}
+
+	this.EndHost()
+	this.beginMiddlewareScope(middlewareScopeHost)
+
+	for _, host := range this.hosts {
+		if host.pattern == pattern {
+			this.lastHost = host
+			return this
+		}
+	}
+
+	var host = newServerHost(pattern)
+	this.hosts = append(this.hosts, host)
+	this.lastHost = host
+	return this
+}
+
+// EndHost 结束虚拟主机定义，此后定义的路由属于默认主机，同时移除在此主机中定义的中间件
+func (this *Server) EndHost() *Server {
+	this.endMiddlewareScope(middlewareScopeHost)
+	this.lastHost = this.defaultHost
+	return this
+}
+
+// 所有的主机，默认主机在最后
+func (this *Server) allHosts() []*serverHost {
+	return append(append([]*serverHost{}, this.hosts...), this.defaultHost)
+}
+
+// 根据请求的Host查找主机，找不到时返回默认主机
+func (this *Server) matchHost(requestHost string) *serverHost {
+	if len(this.hosts) == 0 {
+		return this.defaultHost
+	}
+
+	var hostname = requestHost
+	h, _, err := net.SplitHostPort(requestHost)
+	if err == nil {
+		hostname = h
+	}
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+
+	// 完整的域名优先，其次为最长的通配符
+	var wildcardHost *serverHost
+	for _, host := range this.hosts {
+		if !host.match(hostname) {
+			continue
+		}
+		if host.pattern == hostname {
+			return host
+		}
+		if wildcardHost == nil || len(host.pattern) > len(wildcardHost.pattern) {
+			wildcardHost = host
+		}
+	}
+	if wildcardHost != nil {
+		return wildcardHost
+	}
+	return this.defaultHost
+}
+
+// 取得主机使用的SESSION管理器和Cookie名称，主机没有设置时使用默认主机的设置
+func (this *Server) hostSession(host *serverHost) (sessionManager interface{}, cookieName string) {
+	if host.sessionManager != nil {
+		return host.sessionManager, host.sessionCookieName
+	}
+	return this.defaultHost.sessionManager, this.defaultHost.sessionCookieName
+}
@@ -0,0 +1,90 @@
+package TeaGo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Host(t *testing.T) {
+	var calls = []string{}
+	var handler = func(name string) func() {
+		return func() {
+			calls = append(calls, name)
+		}
+	}
+
+	server := NewServer(false)
+	server.
+		Session("default-session", "sid").
+		Get("/", handler("default")).
+		Host("admin.example.com").
+		Session("admin-session", "admin_sid").
+		Get("/", handler("admin")).
+		Static("/assets", "/tmp").
+		EndHost().
+		Host("*.example.com").
+		Get("/", handler("wildcard")).
+		Get("/users", handler("wildcard-users")).
+		EndAll()
+
+	for host, expected := range map[string]string{
+		"admin.example.com":      "admin",
+		"ADMIN.example.com:8080": "admin",
+		"api.example.com":        "wildcard",
+		"a.b.example.com":        "wildcard",
+		"example.com":            "default",
+		"127.0.0.1:8080":         "default",
+	} {
+		calls = []string{}
+		var h = server.matchHost(host)
+		node, _ := h.routeTrees[""].lookup("/")
+		if node == nil {
+			t.Fatal("route not found for '" + host + "'")
+		}
+		node.route(http.MethodGet).runFunc(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if len(calls) != 1 || calls[0] != expected {
+			t.Fatal("'"+host+"': expected '"+expected+"', but got", calls)
+		}
+	}
+
+	// 路由互相独立
+	if node, _ := server.defaultHost.routeTrees[""].lookup("/users"); node != nil {
+		t.Fatal("'/users' should only be defined in '*.example.com'")
+	}
+
+	// 静态目录
+	if len(server.matchHost("admin.example.com").staticDirs) != 1 || len(server.defaultHost.staticDirs) != 0 {
+		t.Fatal("static dirs should belong to host")
+	}
+
+	// SESSION
+	manager, cookieName := server.hostSession(server.matchHost("admin.example.com"))
+	if manager != "admin-session" || cookieName != "admin_sid" {
+		t.Fatal("unexpected admin session:", manager, cookieName)
+	}
+	manager, cookieName = server.hostSession(server.matchHost("api.example.com"))
+	if manager != "default-session" || cookieName != "sid" {
+		t.Fatal("unexpected default session:", manager, cookieName)
+	}
+}
+
+func TestServerConfig_HostErrors(t *testing.T) {
+	var config = &ServerConfig{}
+	config.Errors = map[string]interface{}{
+		"404": map[string]interface{}{"url": "/404.html"},
+		"admin.example.com": map[string]interface{}{
+			"404": map[string]interface{}{"url": "/admin/404.html"},
+		},
+	}
+	for host, expected := range map[string]string{
+		"admin.example.com": "/admin/404.html",
+		"api.example.com":   "/404.html",
+		"":                  "/404.html",
+	} {
+		var errorConfig = config.findErrorConfig(host, "", http.StatusNotFound)
+		if errorConfig == nil || errorConfig.GetString("url") != expected {
+			t.Fatal("'"+host+"': expected '"+expected+"', but got", errorConfig)
+		}
+	}
+}
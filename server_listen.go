@@ -0,0 +1,149 @@
+package TeaGo
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 监听地址，支持以下格式：
+//
+//	host:port                TCP地址
+//	unix:/path/to/app.sock   Unix Socket
+//	systemd:name             systemd通过LISTEN_FDS传入的Socket，name为FileDescriptorName或者从0开始的序号
+//
+// 地址后可以加选项，比如 unix:/path/to/app.sock?mode=0660&proxyProtocol=on
+//
+// 开启proxyProtocol后客户端可以通过协议头伪造来源地址，所以应该用 proxyProtocolFrom 指定代理服务器的IP或者CIDR，
+// 多个用逗号隔开，比如 0.0.0.0:7777?proxyProtocol=on&proxyProtocolFrom=10.0.0.0/8,127.0.0.1，
+// 其他来源的连接会被直接关闭；如果不指定，则需要保证此端口只能被代理服务器访问
+type listenAddr struct {
+	network string // tcp, unix 或 systemd
+	address string
+
+	mode              os.FileMode  // Unix Socket文件权限
+	proxyProtocol     bool         // 是否解析PROXY协议头
+	proxyProtocolFrom []*net.IPNet // 允许发送PROXY协议头的来源
+}
+
+// 分析监听地址
+func parseListenAddr(addr string) (*listenAddr, error) {
+	var result = &listenAddr{
+		network: "tcp",
+		address: addr,
+	}
+
+	// 选项
+	index := strings.LastIndex(addr, "?")
+	if index > -1 {
+		result.address = addr[:index]
+
+		query, err := url.ParseQuery(addr[index+1:])
+		if err != nil {
+			return nil, errors.New("invalid listen options '" + addr + "': " + err.Error())
+		}
+		for key, values := range query {
+			var value = ""
+			if len(values) > 0 {
+				value = values[0]
+			}
+			switch key {
+			case "mode":
+				mode, err := strconv.ParseUint(value, 8, 32)
+				if err != nil {
+					return nil, errors.New("invalid mode '" + value + "' in '" + addr + "'")
+				}
+				result.mode = os.FileMode(mode)
+			case "proxyProtocol":
+				result.proxyProtocol = value == "on" || value == "true" || value == "1"
+			case "proxyProtocolFrom":
+				for _, piece := range strings.Split(value, ",") {
+					piece = strings.TrimSpace(piece)
+					if len(piece) == 0 {
+						continue
+					}
+					network, err := parseIPNet(piece)
+					if err != nil {
+						return nil, errors.New("invalid proxyProtocolFrom '" + piece + "' in '" + addr + "'")
+					}
+					result.proxyProtocolFrom = append(result.proxyProtocolFrom, network)
+				}
+			default:
+				return nil, errors.New("unknown listen option '" + key + "' in '" + addr + "'")
+			}
+		}
+	}
+
+	if strings.HasPrefix(result.address, "unix:") {
+		result.network = "unix"
+		result.address = strings.TrimPrefix(result.address, "unix:")
+	} else if strings.HasPrefix(result.address, "systemd:") {
+		result.network = "systemd"
+		result.address = strings.TrimPrefix(result.address, "systemd:")
+	}
+
+	if len(result.address) == 0 && result.network != "tcp" {
+		return nil, errors.New("invalid listen address '" + addr + "'")
+	}
+
+	return result, nil
+}
+
+// 分析IP或者CIDR
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errors.New("invalid ip '" + s + "'")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// 根据地址创建新的监听
+func (this *listenAddr) listen() (net.Listener, error) {
+	switch this.network {
+	case "unix":
+		// 删除上次没有清理的Socket文件
+		stat, err := os.Stat(this.address)
+		if err == nil && stat.Mode()&os.ModeSocket > 0 {
+			_ = os.Remove(this.address)
+		}
+
+		listener, err := net.Listen("unix", this.address)
+		if err != nil {
+			return nil, err
+		}
+		if this.mode > 0 {
+			err = os.Chmod(this.address, this.mode)
+			if err != nil {
+				_ = listener.Close()
+				return nil, err
+			}
+		}
+		return listener, nil
+	case "systemd":
+		return takeSystemdListener(this.address)
+	}
+	return net.Listen("tcp", this.address)
+}
+
+// 取得TCP监听地址中的端口，其他类型的地址返回空
+func (this *listenAddr) port() string {
+	if this.network != "tcp" {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(this.address)
+	if err != nil {
+		return ""
+	}
+	return port
+}
@@ -0,0 +1,183 @@
+package TeaGo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseListenAddr(t *testing.T) {
+	for addr, expected := range map[string]listenAddr{
+		"0.0.0.0:7777":                        {network: "tcp", address: "0.0.0.0:7777"},
+		"127.0.0.1:7777?proxyProtocol=on":     {network: "tcp", address: "127.0.0.1:7777", proxyProtocol: true},
+		"unix:/tmp/app.sock?mode=0660":        {network: "unix", address: "/tmp/app.sock", mode: 0660},
+		"systemd:web":                         {network: "systemd", address: "web"},
+		"systemd:0?proxyProtocol=true&mode=0": {network: "systemd", address: "0", proxyProtocol: true},
+		"127.0.0.1:7777?proxyProtocol=on&proxyProtocolFrom=10.0.0.0/8,127.0.0.1,::1": {
+			network:       "tcp",
+			address:       "127.0.0.1:7777",
+			proxyProtocol: true,
+			proxyProtocolFrom: []*net.IPNet{
+				{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+				{IP: net.IP{127, 0, 0, 1}, Mask: net.CIDRMask(32, 32)},
+				{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+			},
+		},
+	} {
+		result, err := parseListenAddr(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*result, expected) {
+			t.Fatalf("'%s': expected %+v, but got %+v", addr, expected, *result)
+		}
+	}
+
+	for _, addr := range []string{"unix:", "unix:/tmp/app.sock?mode=abc", "127.0.0.1:7777?proxy=on", "127.0.0.1:7777?proxyProtocolFrom=10.0.0.0/33"} {
+		_, err := parseListenAddr(addr)
+		if err == nil {
+			t.Fatal("'" + addr + "' should be invalid")
+		}
+		t.Log(err)
+	}
+}
+
+func TestListenAddr_Unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	var sockFile = t.TempDir() + "/app.sock"
+	parsedAddr, err := parseListenAddr("unix:" + sockFile + "?mode=0600")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := parsedAddr.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+
+	stat, err := os.Stat(sockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Fatal("unexpected mode:", stat.Mode().Perm())
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server = &http.Server{
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_, _ = writer.Write([]byte(request.RemoteAddr))
+		}),
+	}
+	go func() {
+		_ = server.Serve(&proxyProtocolListener{Listener: listener})
+	}()
+	defer func() {
+		_ = server.Close()
+	}()
+
+	// v2
+	var headerV2 = append([]byte{}, proxyProtocolV2Signature...)
+	headerV2 = append(headerV2, 0x21, 0x11, 0, 12)
+	headerV2 = append(headerV2, 192, 168, 1, 100, 10, 0, 0, 1)
+	headerV2 = binary.BigEndian.AppendUint16(headerV2, 50000)
+	headerV2 = binary.BigEndian.AppendUint16(headerV2, 80)
+
+	for header, expected := range map[string]string{
+		"PROXY TCP4 192.168.1.100 10.0.0.1 50000 80\r\n":  "192.168.1.100:50000",
+		"PROXY TCP6 2001:db8::1 2001:db8::2 50000 80\r\n": "[2001:db8::1]:50000",
+		string(headerV2):     "192.168.1.100:50000",
+		"PROXY UNKNOWN\r\n":  "127.0.0.1:",
+		"GET / HTTP/1.0\r\n": "",
+		"PROXY TCP4 192.168.1.100 10.0.0.1 50000 80 extra\r\n": "",
+	} {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write([]byte(header + "GET / HTTP/1.0\r\nHost: example.com\r\n\r\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			if len(expected) > 0 {
+				t.Fatal(err)
+			}
+			_ = conn.Close()
+			continue
+		}
+		body, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		_ = conn.Close()
+
+		if len(expected) == 0 || !strings.HasPrefix(string(body), expected) {
+			t.Fatal("expected '" + expected + "', but got '" + string(body) + "'")
+		}
+	}
+}
+
+func TestProxyProtocolListener_AllowedNetworks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server = &http.Server{
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_, _ = writer.Write([]byte(request.RemoteAddr))
+		}),
+	}
+	go func() {
+		_ = server.Serve(&proxyProtocolListener{
+			Listener:        listener,
+			allowedNetworks: []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+		})
+	}()
+	defer func() {
+		_ = server.Close()
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, _ = conn.Write([]byte("PROXY TCP4 192.168.1.100 10.0.0.1 50000 80\r\nGET / HTTP/1.0\r\nHost: example.com\r\n\r\n"))
+	response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err == nil {
+		_ = response.Body.Close()
+		t.Fatal("connection from 127.0.0.1 should be rejected")
+	}
+	t.Log(err)
+
+	var proxyListener = &proxyProtocolListener{
+		allowedNetworks: []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+	}
+	if !proxyListener.isAllowed(&net.TCPAddr{IP: net.IP{10, 1, 2, 3}, Port: 80}) {
+		t.Fatal("10.1.2.3 should be allowed")
+	}
+	if !proxyListener.isAllowed(&net.UnixAddr{Name: "/tmp/app.sock", Net: "unix"}) {
+		t.Fatal("unix socket should be allowed")
+	}
+}
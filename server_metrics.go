@@ -0,0 +1,164 @@
+package TeaGo
+
+import (
+	"github.com/iwind/TeaGo/caches"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/metrics"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 静态文件请求使用的路由标签
+const (
+	metricsRouteStatic = "@static"
+	metricsRouteViews  = "@views"
+	metricsRoutePublic = "@public"
+)
+
+// 内置的HTTP指标
+var (
+	metricsRequestsTotal = metrics.NewCounter("teago_http_requests_total",
+		"Total number of HTTP requests.",
+		"route", "module", "method", "status")
+	metricsRequestDuration = metrics.NewHistogram("teago_http_request_duration_seconds",
+		"HTTP request latencies in seconds.",
+		nil,
+		"route", "module", "method", "status")
+	metricsResponseBytes = metrics.NewCounter("teago_http_response_bytes_total",
+		"Total number of bytes written to HTTP responses.",
+		"route", "module", "method", "status")
+)
+
+var metricsOnce = sync.Once{}
+var metricsServers = []*Server{}
+var metricsServersLocker = sync.Mutex{}
+
+// Metrics 在某个路径上输出Prometheus文本格式的指标，比如 /metrics
+// 开启后会统计请求数量、耗时和输出的字节数，并输出数据库连接池、缓存和SESSION的状态
+// 可以通过 metrics.Register() 注册自定义的指标
+func (this *Server) Metrics(path string) *Server {
+	this.metricsPath = path
+
+	metricsServersLocker.Lock()
+	metricsServers = append(metricsServers, this)
+	metricsServersLocker.Unlock()
+
+	metricsOnce.Do(registerBuiltinMetrics)
+	return this
+}
+
+// 记录一个请求的指标
+func (this *Server) observeRequest(startTime time.Time, writer http.ResponseWriter, request *http.Request, route string, module string) {
+	if len(this.metricsPath) == 0 {
+		return
+	}
+
+	var status = http.StatusOK
+	var bytes = 0
+	response, ok := writer.(*responseWriter)
+	if ok {
+		if response.status > 0 {
+			status = response.status
+		}
+		bytes = response.bytes
+	}
+
+	var labelValues = []string{route, module, metricsMethod(request.Method), strconv.Itoa(status)}
+	metricsRequestsTotal.Inc(labelValues...)
+	metricsRequestDuration.Observe(time.Since(startTime).Seconds(), labelValues...)
+	metricsResponseBytes.Add(float64(bytes), labelValues...)
+}
+
+// 指标中的请求方法，非标准的方法统一记录为 OTHER，防止客户端随意生成指标标签
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// 注册内置的指标
+func registerBuiltinMetrics() {
+	metrics.MustRegister(metricsRequestsTotal, metricsRequestDuration, metricsResponseBytes)
+
+	// 数据库
+	var dbStat = func(f func(db *dbs.DB) float64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			var samples = []metrics.Sample{}
+			for _, db := range dbs.Instances() {
+				if db.Raw() == nil {
+					continue
+				}
+				samples = append(samples, metrics.Sample{
+					LabelValues: []string{db.Id()},
+					Value:       f(db),
+				})
+			}
+			return samples
+		}
+	}
+	metrics.MustRegister(
+		metrics.NewGaugeFunc("teago_db_open_connections", "Number of established database connections.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return float64(db.Raw().Stats().OpenConnections)
+		})),
+		metrics.NewGaugeFunc("teago_db_in_use_connections", "Number of database connections currently in use.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return float64(db.Raw().Stats().InUse)
+		})),
+		metrics.NewGaugeFunc("teago_db_idle_connections", "Number of idle database connections.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return float64(db.Raw().Stats().Idle)
+		})),
+		metrics.NewGaugeFunc("teago_db_max_open_connections", "Maximum number of open database connections.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return float64(db.Raw().Stats().MaxOpenConnections)
+		})),
+		metrics.NewCounterFunc("teago_db_wait_count_total", "Total number of database connections waited for.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return float64(db.Raw().Stats().WaitCount)
+		})),
+		metrics.NewCounterFunc("teago_db_wait_duration_seconds_total", "Total time blocked waiting for a new database connection.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			return db.Raw().Stats().WaitDuration.Seconds()
+		})),
+		metrics.NewGaugeFunc("teago_db_stmts", "Number of cached prepared statements.", []string{"db"}, dbStat(func(db *dbs.DB) float64 {
+			if db.StmtManager() == nil {
+				return 0
+			}
+			return float64(db.StmtManager().Len())
+		})),
+	)
+
+	// 缓存
+	metrics.MustRegister(
+		metrics.NewGaugeFunc("teago_cache_factories", "Number of open cache factories.", nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(len(caches.AllFactories()))}}
+		}),
+		metrics.NewGaugeFunc("teago_cache_items", "Number of items in all cache factories.", nil, func() []metrics.Sample {
+			var count = 0
+			for _, factory := range caches.AllFactories() {
+				count += factory.Len()
+			}
+			return []metrics.Sample{{Value: float64(count)}}
+		}),
+	)
+
+	// SESSION
+	metrics.MustRegister(metrics.NewGaugeFunc("teago_sessions", "Number of active sessions.", nil, func() []metrics.Sample {
+		metricsServersLocker.Lock()
+		defer metricsServersLocker.Unlock()
+
+		var count = 0
+		var countedManagers = map[interface{}]bool{}
+		for _, server := range metricsServers {
+			for _, host := range server.allHosts() {
+				counter, ok := host.sessionManager.(interface{ Count() int })
+				if !ok || countedManagers[counter] {
+					continue
+				}
+				countedManagers[counter] = true
+				count += counter.Count()
+			}
+		}
+		return []metrics.Sample{{Value: float64(count)}}
+	}))
+}
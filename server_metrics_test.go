@@ -0,0 +1,39 @@
+package TeaGo
+
+import (
+	"github.com/iwind/TeaGo/metrics"
+	"github.com/iwind/TeaGo/sessions"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	server := NewServer(false)
+	server.Session(sessions.NewMemorySessionManager(), "sid")
+	server.Metrics("/metrics")
+
+	var writer = newResponseWriter(httptest.NewRecorder())
+	writer.WriteHeader(http.StatusCreated)
+	_, _ = writer.Write([]byte("hello"))
+	server.observeRequest(time.Now(), writer, httptest.NewRequest(http.MethodPost, "/users/:id", nil), "/users/:id", "admin")
+	server.observeRequest(time.Now(), writer, httptest.NewRequest("FOO123", "/users/:id", nil), "/users/:id", "admin")
+
+	var text = metrics.DefaultRegistry.Text()
+	t.Log(text)
+	for _, expected := range []string{
+		"# TYPE teago_http_requests_total counter",
+		`teago_http_requests_total{route="/users/:id",module="admin",method="POST",status="201"} 1`,
+		`teago_http_request_duration_seconds_count{route="/users/:id",module="admin",method="POST",status="201"} 1`,
+		`teago_http_response_bytes_total{route="/users/:id",module="admin",method="POST",status="201"} 5`,
+		`teago_http_requests_total{route="/users/:id",module="admin",method="OTHER",status="201"} 1`,
+		"# TYPE teago_sessions gauge",
+		"# TYPE teago_cache_items gauge",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatal("'" + expected + "' not found")
+		}
+	}
+}
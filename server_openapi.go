@@ -0,0 +1,522 @@
+package TeaGo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/iwind/TeaGo/actions"
+	"github.com/iwind/TeaGo/cmd"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OpenAPI文档中支持的请求方法
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// 使用 All() 定义的路由在没有对应的 RunXxx() 方法时在文档中列出的方法
+var openAPIAnyMethods = []string{"get", "post"}
+
+// 最近创建的Server，用来在 :openapi 命令中输出文档
+var lastServer *Server
+var lastServerLocker = sync.Mutex{}
+
+func init() {
+	cmd.Register(new(OpenAPICommand))
+}
+
+// OpenAPI 在某个路径上输出根据路由生成的OpenAPI 3文档，比如 /openapi.json
+// 默认输出JSON格式，路径以 .yaml 或 .yml 结尾、参数中有 format=yaml 或者 Accept 中含有 yaml 时输出YAML格式
+// 文档中包含所有的路由和 Run() 方法中的参数，参数结构体中可以使用 doc 或 description 标签添加描述
+func (this *Server) OpenAPI(path string) *Server {
+	this.openAPIPath = path
+	return this
+}
+
+// OpenAPIInfo 设置OpenAPI文档的标题和版本，默认标题为可执行文件名，版本为 1.0.0
+func (this *Server) OpenAPIInfo(title string, version string) *Server {
+	this.openAPITitle = title
+	this.openAPIVersion = version
+	return this
+}
+
+// OpenAPIDocument 根据已经定义的路由生成OpenAPI 3文档
+func (this *Server) OpenAPIDocument() map[string]interface{} {
+	var title = this.openAPITitle
+	if len(title) == 0 {
+		title = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	}
+	var version = this.openAPIVersion
+	if len(version) == 0 {
+		version = "1.0.0"
+	}
+
+	var paths = map[string]interface{}{}
+	var operationIds = map[string]bool{}
+
+	this.routerLocker.Lock()
+	for _, host := range this.allHosts() {
+		var modules = []string{}
+		for module := range host.routeTrees {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+
+		for _, module := range modules {
+			var routes = []*serverRoute{}
+			host.routeTrees[module].root.walkRoutes(func(route *serverRoute) {
+				routes = append(routes, route)
+			})
+			sort.Slice(routes, func(i, j int) bool {
+				if routes[i].pattern == routes[j].pattern {
+					return routes[i].method < routes[j].method
+				}
+				return routes[i].pattern < routes[j].pattern
+			})
+
+			for _, route := range routes {
+				var path, pathParams = openAPIPath(route.pattern)
+				if len(module) > 0 {
+					path = "/@" + module + path
+				}
+
+				pathItem, ok := paths[path].(map[string]interface{})
+				if !ok {
+					pathItem = map[string]interface{}{}
+				}
+				for _, method := range openAPIRouteMethods(route) {
+					// 不同主机中的相同路由只列出一次
+					if _, ok := pathItem[method]; ok {
+						continue
+					}
+					operation, ok := openAPIOperation(route, method, pathParams)
+					if !ok {
+						continue
+					}
+
+					var operationId = openAPIOperationId(route, method, path)
+					var newOperationId = operationId
+					for index := 2; operationIds[newOperationId]; index++ {
+						newOperationId = operationId + "_" + types.String(index)
+					}
+					operationIds[newOperationId] = true
+					operation["operationId"] = newOperationId
+
+					pathItem[method] = operation
+				}
+				if len(pathItem) > 0 {
+					paths[path] = pathItem
+				}
+			}
+		}
+	}
+	this.routerLocker.Unlock()
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Response": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"code", "message", "data"},
+					"properties": map[string]interface{}{
+						"code":    map[string]interface{}{"type": "integer", "description": "status code, 200 means success"},
+						"message": map[string]interface{}{"type": "string"},
+						"data":    map[string]interface{}{"type": "object", "additionalProperties": true, "nullable": true},
+						"errors": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"$ref": "#/components/schemas/ParamError"},
+						},
+					},
+				},
+				"ParamError": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"param": map[string]interface{}{"type": "string"},
+						"messages": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// 输出OpenAPI文档
+func (this *Server) handleOpenAPI(writer http.ResponseWriter, request *http.Request) {
+	var document = this.OpenAPIDocument()
+
+	var ext = strings.ToLower(filepath.Ext(request.URL.Path))
+	if ext == ".yaml" || ext == ".yml" || request.URL.Query().Get("format") == "yaml" || strings.Contains(request.Header.Get("Accept"), "yaml") {
+		data, err := yaml.Marshal(document)
+		if err != nil {
+			logs.Error(err)
+			http.Error(writer, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		_, _ = writer.Write(data)
+		return
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		logs.Error(err)
+		http.Error(writer, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = writer.Write(data)
+}
+
+// 遍历节点及其子节点中的路由
+func (this *routeNode) walkRoutes(f func(route *serverRoute)) {
+	for _, route := range this.routes {
+		f(route)
+	}
+	for _, child := range this.staticChildren {
+		child.walkRoutes(f)
+	}
+	for _, child := range this.paramChildren {
+		child.walkRoutes(f)
+	}
+	if this.wildcardChild != nil {
+		this.wildcardChild.walkRoutes(f)
+	}
+}
+
+// 将路由转换为OpenAPI路径，比如 /user/:id(\d+) 转换为 /user/{id}，同时返回参数的正则
+func openAPIPath(pattern string) (path string, params map[string]string) {
+	params = map[string]string{}
+	var segments = splitRoutePath(pattern)
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = ""
+			segments[index] = "{" + segment[1:] + "}"
+			continue
+		}
+		segmentParams, err := parseRouteParams(segment)
+		if err != nil {
+			continue
+		}
+		var piece = ""
+		var lastIndex = 0
+		for _, param := range segmentParams {
+			var reg = ""
+			if len(param.expr) > 0 {
+				reg = "^(?:" + param.expr + ")$"
+			}
+			params[param.name] = reg
+			piece += segment[lastIndex:param.start] + "{" + param.name + "}"
+			lastIndex = param.end
+		}
+		segments[index] = piece + segment[lastIndex:]
+	}
+	return "/" + strings.Join(segments, "/"), params
+}
+
+// 路由在文档中列出的请求方法
+func openAPIRouteMethods(route *serverRoute) []string {
+	var method = strings.ToLower(route.method)
+	if method != "*" {
+		for _, m := range openAPIMethods {
+			if m == method {
+				return []string{method}
+			}
+		}
+		return nil
+	}
+
+	if route.spec == nil {
+		return openAPIAnyMethods
+	}
+	var methods = []string{}
+	for _, m := range openAPIMethods {
+		_, ok := route.spec.FuncMap["Run"+strings.ToUpper(m[:1])+m[1:]]
+		if ok {
+			methods = append(methods, m)
+		}
+	}
+	if _, ok := route.spec.FuncMap["Run"]; ok {
+		for _, m := range openAPIAnyMethods {
+			_, ok := route.spec.FuncMap["Run"+strings.ToUpper(m[:1])+m[1:]]
+			if !ok {
+				methods = append(methods, m)
+			}
+		}
+	}
+	return methods
+}
+
+// 生成单个操作
+func openAPIOperation(route *serverRoute, method string, pathParams map[string]string) (operation map[string]interface{}, ok bool) {
+	operation = map[string]interface{}{}
+	if len(route.host) > 0 {
+		operation["x-host"] = route.host
+	}
+
+	var runParams = []actions.OpenAPIParam{}
+	if route.spec != nil {
+		runParams, ok = route.spec.RunParams(method)
+		if !ok {
+			return nil, false
+		}
+
+		operation["tags"] = []string{openAPITag(route.spec)}
+	}
+
+	// 路径参数
+	var parameters = []interface{}{}
+	var pathNames = []string{}
+	for name := range pathParams {
+		pathNames = append(pathNames, name)
+	}
+	sort.Strings(pathNames)
+	for _, name := range pathNames {
+		var parameter = map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		}
+		for _, runParam := range runParams {
+			if runParam.Name == name || runParam.Field == name {
+				parameter["schema"] = runParam.Schema
+				if len(runParam.Description) > 0 {
+					parameter["description"] = runParam.Description
+				}
+				break
+			}
+		}
+		if len(pathParams[name]) > 0 && parameter["schema"].(map[string]interface{})["type"] == "string" {
+			var schema = map[string]interface{}{}
+			for k, v := range parameter["schema"].(map[string]interface{}) {
+				schema[k] = v
+			}
+			schema["pattern"] = pathParams[name]
+			parameter["schema"] = schema
+		}
+		parameters = append(parameters, parameter)
+	}
+
+	// 查询参数和请求体
+	var hasBody = method == "post" || method == "put" || method == "patch"
+	var properties = map[string]interface{}{}
+	var required = []string{}
+	var hasFile = false
+	for _, runParam := range runParams {
+		if _, ok := pathParams[runParam.Name]; ok {
+			continue
+		}
+		if _, ok := pathParams[runParam.Field]; ok {
+			continue
+		}
+
+		if runParam.Cookie || (!hasBody && !runParam.File) {
+			var parameter = map[string]interface{}{
+				"name":   runParam.Name,
+				"in":     "query",
+				"schema": runParam.Schema,
+			}
+			if runParam.Cookie {
+				parameter["in"] = "cookie"
+			} else if runParam.Schema["type"] == "object" {
+				parameter["style"] = "deepObject"
+				parameter["explode"] = true
+			}
+			if runParam.Required {
+				parameter["required"] = true
+			}
+			if len(runParam.Description) > 0 {
+				parameter["description"] = runParam.Description
+			}
+			parameters = append(parameters, parameter)
+			continue
+		}
+		if !hasBody {
+			continue
+		}
+
+		var schema = runParam.Schema
+		if len(runParam.Description) > 0 {
+			schema["description"] = runParam.Description
+		}
+		properties[runParam.Name] = schema
+		if runParam.Required {
+			required = append(required, runParam.Name)
+		}
+		if runParam.File {
+			hasFile = true
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if len(properties) > 0 {
+		var bodySchema = map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			bodySchema["required"] = required
+		}
+		var content = map[string]interface{}{}
+		if hasFile {
+			content["multipart/form-data"] = map[string]interface{}{"schema": bodySchema}
+		} else {
+			if method == "post" {
+				content["application/x-www-form-urlencoded"] = map[string]interface{}{"schema": bodySchema}
+				content["multipart/form-data"] = map[string]interface{}{"schema": bodySchema}
+			}
+			content["application/json"] = map[string]interface{}{"schema": bodySchema}
+			content["application/xml"] = map[string]interface{}{"schema": bodySchema}
+			content["application/yaml"] = map[string]interface{}{"schema": bodySchema}
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"required": len(required) > 0,
+			"content":  content,
+		}
+	}
+
+	// 响应
+	if route.spec == nil {
+		operation["responses"] = map[string]interface{}{
+			"default": map[string]interface{}{"description": "response"},
+		}
+	} else {
+		var responseContent = map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Response"},
+			},
+		}
+		var responses = map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "success",
+				"content":     responseContent,
+			},
+		}
+		if len(runParams) > 0 {
+			responses["400"] = map[string]interface{}{
+				"description": "invalid parameters",
+				"content":     responseContent,
+			}
+		}
+		operation["responses"] = responses
+	}
+	return operation, true
+}
+
+// 操作分组，使用Action所在的包，比如 default/users
+func openAPITag(spec *actions.ActionSpec) string {
+	index := strings.LastIndex(spec.PkgPath, "/actions/")
+	if index >= 0 {
+		return spec.PkgPath[index+len("/actions/"):]
+	}
+	return filepath.Base(spec.PkgPath)
+}
+
+// 操作ID，比如 default.users.IndexAction.get
+func openAPIOperationId(route *serverRoute, method string, path string) string {
+	if route.spec != nil {
+		return strings.Replace(openAPITag(route.spec), "/", ".", -1) + "." + route.spec.Type.Name() + "." + method
+	}
+	var id = strings.Trim(strings.NewReplacer("/", ".", "{", "", "}", "", "@", "").Replace(path), ".")
+	if len(id) == 0 {
+		id = "index"
+	}
+	return id + "." + method
+}
+
+// OpenAPICommand 输出最近创建的Server的OpenAPI文档
+type OpenAPICommand struct {
+	*cmd.Command
+}
+
+func (this *OpenAPICommand) Name() string {
+	return "print openapi document generated from routes"
+}
+
+func (this *OpenAPICommand) Usage() string {
+	return ":openapi [json|yaml] [-output=FILE]"
+}
+
+func (this *OpenAPICommand) Codes() []string {
+	return []string{":openapi"}
+}
+
+func (this *OpenAPICommand) Run() {
+	lastServerLocker.Lock()
+	var server = lastServer
+	lastServerLocker.Unlock()
+	if server == nil {
+		this.ErrorString("no server found")
+		return
+	}
+
+	var format, _ = this.Arg(1)
+	if strings.HasPrefix(format, "-") {
+		format = ""
+	}
+	if format != "" && format != "json" && format != "yaml" {
+		this.ErrorString("invalid format '" + format + "', should be 'json' or 'yaml'")
+		return
+	}
+	output, _ := this.Param("output")
+	if len(format) == 0 && (strings.HasSuffix(output, ".yaml") || strings.HasSuffix(output, ".yml")) {
+		format = "yaml"
+	}
+
+	var document = server.OpenAPIDocument()
+	var data []byte
+	var err error
+	if format == "yaml" {
+		data, err = yaml.Marshal(document)
+	} else {
+		data, err = json.MarshalIndent(document, "", "  ")
+	}
+	if err != nil {
+		this.Error(err)
+		return
+	}
+
+	if len(output) > 0 {
+		err = os.WriteFile(output, data, 0666)
+		if err != nil {
+			this.Error(errors.New("write '" + output + "' failed: " + err.Error()))
+			return
+		}
+		this.Output("<ok>write to '" + output + "'</ok>\n")
+		return
+	}
+	// 直接输出，以免文档中的内容被当做颜色标签处理
+	_, _ = os.Stdout.Write(append(data, '\n'))
+}
+
+// 执行以 : 开头的命令行参数，比如 ./app :openapi yaml，路由定义完成后在启动时执行
+func (this *Server) runCommandArgs() bool {
+	if !isCommandArgs() {
+		return false
+	}
+	if !cmd.Try(os.Args[1:]) {
+		logs.Error(errors.New("command '" + os.Args[1] + "' not found"))
+	}
+	return true
+}
+
+// 命令行参数是否为命令
+func isCommandArgs() bool {
+	return len(os.Args) > 1 && strings.HasPrefix(os.Args[1], ":")
+}
@@ -0,0 +1,142 @@
+package TeaGo
+
+import (
+	"encoding/json"
+	"github.com/iwind/TeaGo/actions"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testOpenAPIAction actions.Action
+
+func (this *testOpenAPIAction) RunGet(params struct {
+	Id      int64  `doc:"user id"`
+	Page    int    `default:"1" validate:"gte=1"`
+	Keyword string `description:"search keyword"`
+	Filter  struct {
+		Status string `validate:"in=on|off"`
+	}
+	Must *actions.Must
+}) {
+	this.Success()
+}
+
+func (this *testOpenAPIAction) RunPost(params struct {
+	Name   string `validate:"required,max=20"`
+	Email  string `validate:"email"`
+	Avatar *actions.File
+}) {
+	this.Success()
+}
+
+func TestServer_OpenAPIDocument(t *testing.T) {
+	server := NewServer(false).
+		OpenAPIInfo("Test API", "2.0").
+		Get("/users/:id(\\d+)", new(testOpenAPIAction)).
+		Post("/users", new(testOpenAPIAction)).
+		All("/ping", func() {}).
+		Module("admin").
+		Get("/stats", new(testOpenAPIAction)).
+		EndAll()
+
+	data, err := json.Marshal(server.OpenAPIDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(data))
+
+	var document = struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name        string                 `json:"name"`
+				In          string                 `json:"in"`
+				Required    bool                   `json:"required"`
+				Description string                 `json:"description"`
+				Schema      map[string]interface{} `json:"schema"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]interface{} `json:"properties"`
+						Required   []string                          `json:"required"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]interface{} `json:"responses"`
+		} `json:"paths"`
+	}{}
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != "3.0.3" || document.Info.Title != "Test API" {
+		t.Fatal("invalid document info")
+	}
+	for _, path := range []string{"/users/{id}", "/users", "/ping", "/@admin/stats"} {
+		if _, ok := document.Paths[path]; !ok {
+			t.Fatal("path '" + path + "' not found")
+		}
+	}
+	if len(document.Paths["/ping"]) != 2 {
+		t.Fatal("'/ping' should have get and post methods")
+	}
+
+	// GET
+	var getOperation = document.Paths["/users/{id}"]["get"]
+	var parameters = map[string]string{}
+	for _, parameter := range getOperation.Parameters {
+		parameters[parameter.Name] = parameter.In
+	}
+	if len(parameters) != 4 || parameters["id"] != "path" || parameters["page"] != "query" || parameters["keyword"] != "query" || parameters["filter"] != "query" {
+		t.Fatal("invalid parameters:", parameters)
+	}
+	var idParameter = getOperation.Parameters[0]
+	if !idParameter.Required || idParameter.Description != "user id" || idParameter.Schema["type"] != "integer" {
+		t.Fatal("invalid path parameter:", idParameter)
+	}
+	var pageParameter = getOperation.Parameters[1]
+	if pageParameter.Schema["default"] != float64(1) || pageParameter.Schema["minimum"] != float64(1) {
+		t.Fatal("invalid query parameter:", pageParameter)
+	}
+	if _, ok := getOperation.Responses["200"]; !ok {
+		t.Fatal("response should be defined")
+	}
+
+	// POST
+	var postOperation = document.Paths["/users"]["post"]
+	content, ok := postOperation.RequestBody.Content["multipart/form-data"]
+	if !ok {
+		t.Fatal("file upload should use multipart/form-data")
+	}
+	if content.Schema.Properties["avatar"]["format"] != "binary" || content.Schema.Properties["email"]["format"] != "email" || content.Schema.Properties["name"]["maxLength"] != float64(20) {
+		t.Fatal("invalid request body:", content.Schema.Properties)
+	}
+	if len(content.Schema.Required) != 1 || content.Schema.Required[0] != "name" {
+		t.Fatal("invalid required fields:", content.Schema.Required)
+	}
+}
+
+func TestServer_OpenAPIHandler(t *testing.T) {
+	server := NewServer(false).
+		OpenAPI("/openapi.json").
+		Get("/users", new(testOpenAPIAction))
+
+	var recorder = httptest.NewRecorder()
+	server.handleOpenAPI(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Header().Get("Content-Type") != "application/json; charset=utf-8" || !strings.Contains(recorder.Body.String(), `"/users"`) {
+		t.Fatal("invalid json document")
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleOpenAPI(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json?format=yaml", nil))
+	t.Log(recorder.Body.String())
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/yaml") || !strings.Contains(recorder.Body.String(), "openapi: 3.0.3") {
+		t.Fatal("invalid yaml document")
+	}
+}
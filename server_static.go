@@ -0,0 +1,200 @@
+package TeaGo
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"github.com/iwind/TeaGo/actions"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 预压缩文件的扩展名
+var precompressedExts = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+}
+
+// 文件ETag缓存
+var staticETagCache = sync.Map{} // fsID:name => *staticETag
+
+type staticETag struct {
+	size       int64
+	modifiedAt time.Time
+	tag        string
+}
+
+// 静态文件输出选项
+type staticOptions struct {
+	cacheControl string // Cache-Control
+	etag         bool   // 是否输出ETag
+}
+
+// 输出静态文件
+// fsID 用来区分不同的文件系统，作为ETag缓存的前缀
+// 会根据 Accept-Encoding 优先使用 .br 和 .gz 预压缩文件，其次对文本文件进行动态压缩
+func (this *Server) serveStaticFile(writer http.ResponseWriter, request *http.Request, fsys fs.FS, fsID string, name string, options staticOptions) {
+	var cleanName = path.Clean("/" + name)
+	var fileName = strings.TrimPrefix(cleanName, "/")
+	if len(fileName) == 0 {
+		fileName = "."
+	}
+
+	// 目录、index.html等交给http.FileServer处理
+	stat, err := fs.Stat(fsys, fileName)
+	if err != nil || !stat.Mode().IsRegular() || strings.HasSuffix(cleanName, "/index.html") {
+		this.serveStaticHeaders(writer, options)
+		http.FileServer(http.FS(fsys)).ServeHTTP(writer, request)
+		return
+	}
+
+	_, isText := this.outputMimeType(writer, cleanName)
+	this.serveStaticHeaders(writer, options)
+
+	// 可用的编码
+	var acceptEncoding = request.Header.Get("Accept-Encoding")
+	var encodings = []string{}
+	var precompressedStats = map[string]fs.FileInfo{}
+	for _, encoding := range []string{"br", "gzip"} {
+		precompressedStat, err := fs.Stat(fsys, fileName+precompressedExts[encoding])
+		if err == nil && precompressedStat.Mode().IsRegular() {
+			precompressedStats[encoding] = precompressedStat
+			encodings = append(encodings, encoding)
+		}
+	}
+	if isText && stat.Size() >= compressMinLength {
+		for _, encoding := range compressEncodings {
+			if _, ok := precompressedStats[encoding]; !ok {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	if len(encodings) > 0 {
+		actions.AddVaryHeader(writer.Header(), "Accept-Encoding")
+	}
+	var encoding = actions.NegotiateEncoding(acceptEncoding, encodings)
+
+	// 预压缩文件
+	if precompressedStat, ok := precompressedStats[encoding]; ok {
+		var precompressedName = fileName + precompressedExts[encoding]
+		fp, err := openSeekableFile(fsys, precompressedName)
+		if err == nil {
+			defer func() {
+				_ = fp.Close()
+			}()
+
+			if options.etag {
+				setStaticETag(writer, fsys, fsID, precompressedName, precompressedStat, "")
+			}
+			if len(writer.Header().Get("Content-Type")) == 0 {
+				writer.Header().Set("Content-Type", "application/octet-stream")
+			}
+			writer.Header().Set("Content-Encoding", encoding)
+			http.ServeContent(writer, request, cleanName, stat.ModTime(), fp)
+			return
+		}
+	}
+
+	fp, err := openSeekableFile(fsys, fileName)
+	if err != nil {
+		http.Error(writer, "404 page not found", http.StatusNotFound)
+		return
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	// 动态压缩
+	if len(encoding) > 0 {
+		if options.etag {
+			setStaticETag(writer, fsys, fsID, fileName, stat, encoding)
+		}
+
+		// 压缩后不支持Range
+		request.Header.Del("Range")
+
+		var compressWriter = newCompressWriter(writer, request, encoding)
+		defer func() {
+			_ = compressWriter.Close()
+		}()
+		http.ServeContent(compressWriter, request, cleanName, stat.ModTime(), fp)
+		return
+	}
+
+	if options.etag {
+		setStaticETag(writer, fsys, fsID, fileName, stat, "")
+	}
+	http.ServeContent(writer, request, cleanName, stat.ModTime(), fp)
+}
+
+type seekableFile interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// 打开文件，文件必须支持Seek
+func openSeekableFile(fsys fs.FS, name string) (seekableFile, error) {
+	fp, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	seeker, ok := fp.(seekableFile)
+	if !ok {
+		_ = fp.Close()
+		return nil, errors.New("file '" + name + "' is not seekable")
+	}
+	return seeker, nil
+}
+
+// 输出静态文件的缓存相关Header
+func (this *Server) serveStaticHeaders(writer http.ResponseWriter, options staticOptions) {
+	if len(options.cacheControl) > 0 {
+		writer.Header().Set("Cache-Control", options.cacheControl)
+	}
+}
+
+// 设置ETag
+func setStaticETag(writer http.ResponseWriter, fsys fs.FS, fsID string, name string, stat fs.FileInfo, encoding string) {
+	var tag = staticFileETag(fsys, fsID, name, stat, encoding)
+	if len(tag) > 0 {
+		writer.Header().Set("ETag", tag)
+	}
+}
+
+// 计算文件的强ETag，文件大小和修改时间不变时使用缓存
+// encoding 不为空时表示动态压缩后的内容，会加在ETag后面以区分
+func staticFileETag(fsys fs.FS, fsID string, name string, stat fs.FileInfo, encoding string) string {
+	var tag = ""
+	var cacheKey = fsID + ":" + name
+	cache, ok := staticETagCache.Load(cacheKey)
+	if ok && cache.(*staticETag).size == stat.Size() && cache.(*staticETag).modifiedAt.Equal(stat.ModTime()) {
+		tag = cache.(*staticETag).tag
+	} else {
+		fp, err := fsys.Open(name)
+		if err != nil {
+			return ""
+		}
+		var hash = md5.New()
+		_, err = io.Copy(hash, fp)
+		_ = fp.Close()
+		if err != nil {
+			return ""
+		}
+		tag = hex.EncodeToString(hash.Sum(nil))
+		staticETagCache.Store(cacheKey, &staticETag{
+			size:       stat.Size(),
+			modifiedAt: stat.ModTime(),
+			tag:        tag,
+		})
+	}
+
+	if len(encoding) > 0 {
+		return "\"" + tag + "-" + encoding + "\""
+	}
+	return "\"" + tag + "\""
+}
@@ -0,0 +1,171 @@
+package TeaGo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/logs"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 记录调用顺序
+type testStopCalls struct {
+	locker sync.Mutex
+	calls  []string
+}
+
+func (this *testStopCalls) add(call string) {
+	this.locker.Lock()
+	this.calls = append(this.calls, call)
+	this.locker.Unlock()
+}
+
+func (this *testStopCalls) list() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string{}, this.calls...)
+}
+
+type testStopLogWriter struct {
+	calls *testStopCalls
+}
+
+func (this *testStopLogWriter) Init() {
+}
+
+func (this *testStopLogWriter) Print(t time.Time, response *responseWriter, request *http.Request) {
+}
+
+func (this *testStopLogWriter) Write(logMessage string) {
+}
+
+func (this *testStopLogWriter) Close() {
+}
+
+func (this *testStopLogWriter) Flush() {
+	this.calls.add("flush")
+}
+
+// 不连接任何数据库的驱动
+type testStopDriver struct {
+}
+
+func (this *testStopDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("no database")
+}
+
+func init() {
+	sql.Register("teago_stop_test", &testStopDriver{})
+}
+
+func TestServer_Stop(t *testing.T) {
+	var calls = &testStopCalls{}
+
+	// 数据库
+	var oldDBs = dbs.GlobalConfig().DBs
+	defer func() {
+		dbs.GlobalConfig().DBs = oldDBs
+	}()
+	dbs.GlobalConfig().DBs = map[string]*dbs.DBConfig{
+		"stop_test": {Driver: "teago_stop_test"},
+	}
+	db, err := dbs.Instance("stop_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BeforeStop() 中的函数只执行一次，所以在测试中重置
+	var oldBeforeStopFunctions = beforeStopFunctions
+	beforeStopFunctions = nil
+	beforeStopOnce = sync.Once{}
+	defer func() {
+		beforeStopFunctions = oldBeforeStopFunctions
+		logs.SetWriter(nil)
+	}()
+	BeforeStop(func(server *Server) {
+		if len(dbs.Instances()) == 0 {
+			t.Error("databases should be closed after BeforeStop()")
+		}
+		calls.add("beforeStop")
+	})
+
+	var requestStarted = make(chan bool)
+	var server = NewServer(false).
+		AccessLog(false).
+		LogWriter(&testStopLogWriter{calls: calls}).
+		ShutdownTimeout(5*time.Second).
+		Get("/slow", func(writer http.ResponseWriter) {
+			close(requestStarted)
+			time.Sleep(300 * time.Millisecond)
+			calls.add("request")
+			_, _ = writer.Write([]byte("done"))
+		})
+
+	var stopped int32
+	go func() {
+		server.StartOn("127.0.0.1:0")
+		atomic.StoreInt32(&stopped, 1)
+	}()
+
+	// 等待服务启动
+	for i := 0; i < 100 && atomic.LoadInt32(&server.started) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.httpServerLocker.Lock()
+	var listener = server.listeners["127.0.0.1:0"]
+	server.httpServerLocker.Unlock()
+	if listener == nil {
+		t.Fatal("server should be started")
+	}
+
+	// 保持一个正在处理的请求
+	var responseChan = make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responseChan <- err.Error()
+			return
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		data, _ := io.ReadAll(resp.Body)
+		responseChan <- string(data)
+	}()
+	<-requestStarted
+
+	server.Stop()
+	calls.add("stop")
+
+	if body := <-responseChan; body != "done" {
+		t.Fatal("in-flight request should be completed, but got:", body)
+	}
+	if err := db.Raw().Ping(); len(dbs.Instances()) > 0 || err == nil || err.Error() != "sql: database is closed" {
+		t.Fatal("databases should be closed:", err)
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&stopped) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&stopped) == 0 {
+		t.Fatal("StartOn() should return after Stop()")
+	}
+
+	// 等待请求结束后，依次执行 BeforeStop()、写出日志、关闭数据库
+	var result = calls.list()
+	t.Log(result)
+	var expected = []string{"request", "beforeStop", "flush", "stop"}
+	if len(result) != len(expected) {
+		t.Fatal("unexpected calls:", result)
+	}
+	for index, call := range expected {
+		if result[index] != call {
+			t.Fatal("unexpected calls:", result)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+//go:build !windows
+// +build !windows
+
+package TeaGo
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// systemd socket activation 传入的文件描述符从3开始
+const systemdListenFdsStart = 3
+
+var systemdListeners []net.Listener // 按顺序排列，已经取出的为nil
+var systemdListenerNames []string
+var systemdLocker = sync.Mutex{}
+var systemdOnce = sync.Once{}
+
+// 读取systemd通过 LISTEN_PID、LISTEN_FDS 和 LISTEN_FDNAMES 传入的Socket
+func loadSystemdListeners() {
+	systemdOnce.Do(func() {
+		var pid = os.Getenv("LISTEN_PID")
+		var fds = os.Getenv("LISTEN_FDS")
+		var names = os.Getenv("LISTEN_FDNAMES")
+
+		// 防止再传递给以后的子进程
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+
+		if pid != strconv.Itoa(os.Getpid()) {
+			return
+		}
+		count, err := strconv.Atoi(fds)
+		if err != nil || count <= 0 {
+			return
+		}
+
+		var nameList = []string{}
+		if len(names) > 0 {
+			nameList = strings.Split(names, ":")
+		}
+
+		for i := 0; i < count; i++ {
+			var fd = systemdListenFdsStart + i
+			syscall.CloseOnExec(fd)
+
+			var name = ""
+			if i < len(nameList) {
+				name = nameList[i]
+			}
+
+			file := os.NewFile(uintptr(fd), "systemd:"+name)
+			listener, err := net.FileListener(file)
+			_ = file.Close()
+			if err != nil {
+				listener = nil
+			}
+			systemdListeners = append(systemdListeners, listener)
+			systemdListenerNames = append(systemdListenerNames, name)
+		}
+	})
+}
+
+// 取出systemd传入的Socket，name可以是FileDescriptorName或者从0开始的序号
+func takeSystemdListener(name string) (net.Listener, error) {
+	loadSystemdListeners()
+
+	systemdLocker.Lock()
+	defer systemdLocker.Unlock()
+
+	var index = -1
+	for i, listenerName := range systemdListenerNames {
+		if listenerName == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		i, err := strconv.Atoi(name)
+		if err == nil && i >= 0 && i < len(systemdListeners) {
+			index = i
+		}
+	}
+	if index < 0 || systemdListeners[index] == nil {
+		return nil, errors.New("systemd: socket '" + name + "' not found")
+	}
+
+	listener := systemdListeners[index]
+	systemdListeners[index] = nil
+	return listener, nil
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package TeaGo
+
+import (
+	"errors"
+	"net"
+)
+
+// Windows下没有systemd
+
+func takeSystemdListener(name string) (net.Listener, error) {
+	return nil, errors.New("systemd: socket activation is not supported on windows")
+}
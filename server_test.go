@@ -0,0 +1,203 @@
+package TeaGo
+
+import (
+	"bytes"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/actions"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_Use(t *testing.T) {
+	var calls = []string{}
+	var middleware = func(name string) func(next http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(writer, request)
+			})
+		}
+	}
+
+	var handler = func(name string) func(writer http.ResponseWriter) {
+		return func(writer http.ResponseWriter) {
+			calls = append(calls, name)
+		}
+	}
+
+	server := NewServer(false)
+	server.
+		AccessLog(false).
+		Get("/before", handler("before")).
+		Use(middleware("a")).
+		Use(middleware("b")).
+		Get("/hello", handler("hello")).
+		Module("admin").
+		Use(middleware("auth")).
+		Prefix("/users").
+		Use(middleware("u")).
+		Get("/list", handler("list")).
+		EndModule().
+		Get("/others", handler("others")).
+		EndPrefix().
+		Get("/world", handler("world")).
+		EndAll().
+		Get("/after", handler("after"))
+
+	var rootHandler = server.buildRootHandler()
+	for _, path := range []string{"/before", "/hello", "/@admin/users/list", "/users/others", "/world", "/after"} {
+		rootHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// 路由只使用定义之前添加的中间件；EndModule() 不会移除仍然打开的 Prefix() 中的中间件；EndAll() 移除所有的中间件
+	if strings.Join(calls, ",") != "before,a,b,hello,a,b,auth,u,list,a,b,u,others,a,b,world,after" {
+		t.Fatal("unexpected calls:", calls)
+	}
+	if len(server.globalMiddlewares) != 0 || len(server.lastMiddlewares) != 0 {
+		t.Fatal("middlewares should be removed after EndAll()")
+	}
+}
+
+func TestServer_UseRouter(t *testing.T) {
+	server := NewServer(false)
+	server.
+		AccessLog(false).
+		UseGlobal(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				defer func() {
+					if r := recover(); r != nil {
+						http.Error(writer, "recovered", http.StatusServiceUnavailable)
+					}
+				}()
+				next.ServeHTTP(writer, request)
+			})
+		}).
+		UseGlobal(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.Method == http.MethodOptions {
+					writer.Header().Set("Access-Control-Allow-Origin", "*")
+					writer.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(writer, request)
+			})
+		}).
+		Post("/panic", func(writer http.ResponseWriter) {
+			panic("router")
+		})
+
+	var rootHandler = server.buildRootHandler()
+
+	// 捕获panic
+	var recorder = httptest.NewRecorder()
+	rootHandler(recorder, httptest.NewRequest(http.MethodPost, "/panic", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatal("panic should be recovered by middleware, but got", recorder.Code)
+	}
+
+	// OPTIONS预检请求在返回405之前处理
+	recorder = httptest.NewRecorder()
+	rootHandler(recorder, httptest.NewRequest(http.MethodOptions, "/panic", nil))
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatal("preflight should be handled by middleware, but got", recorder.Code)
+	}
+}
+
+type testInjectService struct {
+	name string
+}
+
+type testInjectAction actions.Action
+
+func (this *testInjectAction) RunGet(params struct {
+	Service *testInjectService `inject:""`
+}) {
+	this.Data["name"] = params.Service.name
+	this.Success()
+}
+
+func TestServer_Singleton(t *testing.T) {
+	server := NewServer(false)
+	server.Get("/missing", new(testInjectAction))
+	if len(server.RouteErrors()) != 1 || !strings.Contains(server.RouteErrors()[0].Error(), "no binding for '*TeaGo.testInjectService'") {
+		t.Fatal("missing binding should be reported:", server.RouteErrors())
+	}
+
+	server = NewServer(false)
+	server.
+		Singleton(&testInjectService{name: "Lu"}).
+		Get("/hello", new(testInjectAction))
+	if len(server.RouteErrors()) > 0 {
+		t.Fatal(server.RouteErrors())
+	}
+
+	node, _ := server.defaultHost.routeTrees[""].lookup("/hello")
+	var recorder = httptest.NewRecorder()
+	node.route(http.MethodGet).runFunc(recorder, httptest.NewRequest(http.MethodGet, "/hello", nil))
+	t.Log(recorder.Body.String())
+	if !strings.Contains(recorder.Body.String(), `"name":"Lu"`) {
+		t.Fatal("singleton should be injected")
+	}
+}
+
+type testValidateAction actions.Action
+
+func (this *testValidateAction) RunPost(params struct {
+	Name string `validate:"required,unknown"`
+}) {
+	this.Success()
+}
+
+func TestServer_ValidateErrors(t *testing.T) {
+	server := NewServer(false)
+	server.Post("/validate", new(testValidateAction))
+	if len(server.RouteErrors()) != 1 || !strings.Contains(server.RouteErrors()[0].Error(), "unknown validate rule 'unknown'") {
+		t.Fatal("invalid validate tag should be reported:", server.RouteErrors())
+	}
+}
+
+type testStreamUploadAction actions.Action
+
+func (this *testStreamUploadAction) RunPost(params struct {
+	File *actions.File
+}) {
+	if this.Request.MultipartForm != nil {
+		this.Fail("multipart form should not be parsed by server")
+	}
+	this.Data["path"] = params.File.Path
+	this.Success()
+}
+
+func TestServer_StreamUpload(t *testing.T) {
+	Tea.SetTmpDir(t.TempDir())
+	defer Tea.SetTmpDir("")
+
+	var read int64
+	server := NewServer(false)
+	server.
+		StreamUpload().
+		UploadProgress(func(request *http.Request, progress *actions.UploadProgress) {
+			read = progress.Size
+		}).
+		Post("/upload", new(testStreamUploadAction)).
+		EndAll()
+
+	var body = &bytes.Buffer{}
+	var writer = multipart.NewWriter(body)
+	partWriter, _ := writer.CreateFormFile("file", "hello.txt")
+	_, _ = partWriter.Write([]byte("hello"))
+	_ = writer.Close()
+	var request = httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Set("Content-Type", strings.Replace(writer.FormDataContentType(), "multipart/form-data", "Multipart/Form-Data", 1))
+
+	node, _ := server.defaultHost.routeTrees[""].lookup("/upload")
+	var recorder = httptest.NewRecorder()
+	node.route(http.MethodPost).runFunc(recorder, request)
+	t.Log(recorder.Body.String())
+	if !strings.Contains(recorder.Body.String(), `"path":"`+Tea.TmpDir()) || read != 5 {
+		t.Fatal("file should be streamed to tmp dir")
+	}
+}
@@ -0,0 +1,268 @@
+package TeaGo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/logs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 检查证书文件是否有变化的间隔
+const tlsCertCheckInterval = 10 * time.Second
+
+// 证书管理器，在证书文件有变化时自动重新加载
+type tlsCertManager struct {
+	configs []ServerCertConfig
+
+	certs      []*tls.Certificate
+	modifiedAt []int64 // 每个证书文件的修改时间，依次为 cert1, key1, cert2, key2 ...
+	lastCheck  time.Time
+	locker     sync.RWMutex
+}
+
+func newTLSCertManager(configs []ServerCertConfig) (*tlsCertManager, error) {
+	var manager = &tlsCertManager{}
+	for _, config := range configs {
+		manager.configs = append(manager.configs, ServerCertConfig{
+			Cert: tlsAbsPath(config.Cert),
+			Key:  tlsAbsPath(config.Key),
+		})
+	}
+
+	certs, modifiedAt, err := manager.load()
+	if err != nil {
+		return nil, err
+	}
+	manager.certs = certs
+	manager.modifiedAt = modifiedAt
+	manager.lastCheck = time.Now()
+	return manager, nil
+}
+
+// 加载所有证书
+func (this *tlsCertManager) load() (certs []*tls.Certificate, modifiedAt []int64, err error) {
+	for _, config := range this.configs {
+		cert, err := tls.LoadX509KeyPair(config.Cert, config.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, &cert)
+	}
+	modifiedAt = this.stat()
+	return
+}
+
+// 读取所有证书文件的修改时间
+func (this *tlsCertManager) stat() []int64 {
+	var result = []int64{}
+	for _, config := range this.configs {
+		for _, file := range []string{config.Cert, config.Key} {
+			stat, err := os.Stat(file)
+			if err != nil {
+				result = append(result, 0)
+			} else {
+				result = append(result, stat.ModTime().UnixNano())
+			}
+		}
+	}
+	return result
+}
+
+// 检查证书文件是否有变化，有变化则重新加载
+func (this *tlsCertManager) reloadIfChanged() {
+	this.locker.Lock()
+	if time.Since(this.lastCheck) < tlsCertCheckInterval {
+		this.locker.Unlock()
+		return
+	}
+	this.lastCheck = time.Now()
+	var oldModifiedAt = this.modifiedAt
+	this.locker.Unlock()
+
+	var newModifiedAt = this.stat()
+	var changed = false
+	for index, t := range newModifiedAt {
+		if t != oldModifiedAt[index] {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+
+	certs, modifiedAt, err := this.load()
+	if err != nil {
+		// 证书文件可能还没有写完，下次再试
+		logs.Error(errors.New("tls: reload certificates failed: " + err.Error()))
+		return
+	}
+
+	this.locker.Lock()
+	this.certs = certs
+	this.modifiedAt = modifiedAt
+	this.locker.Unlock()
+
+	logs.Println("tls: certificates reloaded")
+}
+
+// GetCertificate 根据SNI选择证书
+func (this *tlsCertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	this.reloadIfChanged()
+
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	if len(this.certs) == 0 {
+		return nil, errors.New("no certificates")
+	}
+
+	for _, cert := range this.certs {
+		if hello.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+
+	// 默认使用第一个证书
+	return this.certs[0], nil
+}
+
+// 根据配置构造TLS配置
+func (this *Server) buildTLSConfig() (*tls.Config, error) {
+	var httpsConfig = this.config.Https
+
+	// 证书
+	var certConfigs = []ServerCertConfig{}
+	if len(httpsConfig.Cert) > 0 || len(httpsConfig.Key) > 0 {
+		certConfigs = append(certConfigs, ServerCertConfig{
+			Cert: httpsConfig.Cert,
+			Key:  httpsConfig.Key,
+		})
+	}
+	certConfigs = append(certConfigs, httpsConfig.Certs...)
+	if len(certConfigs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+
+	certManager, err := newTLSCertManager(certConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig = &tls.Config{
+		GetCertificate: certManager.GetCertificate,
+	}
+
+	// 最低版本
+	if len(httpsConfig.MinVersion) > 0 {
+		version, ok := map[string]uint16{
+			"1.0": tls.VersionTLS10,
+			"1.1": tls.VersionTLS11,
+			"1.2": tls.VersionTLS12,
+			"1.3": tls.VersionTLS13,
+		}[strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(httpsConfig.MinVersion)), "TLS")]
+		if !ok {
+			return nil, errors.New("invalid minVersion '" + httpsConfig.MinVersion + "'")
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	// 加密套件
+	if len(httpsConfig.CipherSuites) > 0 {
+		var suiteMap = map[string]uint16{}
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suiteMap[suite.Name] = suite.ID
+		}
+		for _, name := range httpsConfig.CipherSuites {
+			id, ok := suiteMap[strings.TrimSpace(name)]
+			if !ok {
+				return nil, errors.New("invalid cipher suite '" + name + "'")
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	// 客户端证书
+	var clientAuth = strings.TrimSpace(httpsConfig.ClientAuth)
+	if len(httpsConfig.ClientCA) > 0 {
+		caBytes, err := os.ReadFile(tlsAbsPath(httpsConfig.ClientCA))
+		if err != nil {
+			return nil, err
+		}
+		var pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("no certificates found in clientCA '" + httpsConfig.ClientCA + "'")
+		}
+		tlsConfig.ClientCAs = pool
+
+		if len(clientAuth) == 0 {
+			clientAuth = "requireAndVerify"
+		}
+	}
+	if len(clientAuth) > 0 {
+		authType, ok := map[string]tls.ClientAuthType{
+			"none":             tls.NoClientCert,
+			"request":          tls.RequestClientCert,
+			"require":          tls.RequireAnyClientCert,
+			"verifyIfGiven":    tls.VerifyClientCertIfGiven,
+			"requireAndVerify": tls.RequireAndVerifyClientCert,
+		}[clientAuth]
+		if !ok {
+			return nil, errors.New("invalid clientAuth '" + clientAuth + "'")
+		}
+		if (authType == tls.VerifyClientCertIfGiven || authType == tls.RequireAndVerifyClientCert) && tlsConfig.ClientCAs == nil {
+			return nil, errors.New("clientCA should be set when clientAuth is '" + clientAuth + "'")
+		}
+		tlsConfig.ClientAuth = authType
+	}
+
+	return tlsConfig, nil
+}
+
+// 跳转到HTTPS的处理函数
+func (this *Server) redirectToHTTPSHandler() http.Handler {
+	var port = ""
+	if this.config.Http.RedirectPort > 0 {
+		port = strconv.Itoa(this.config.Http.RedirectPort)
+	} else {
+		for _, addr := range this.config.Https.Listen {
+			parsedAddr, err := parseListenAddr(addr)
+			if err == nil && len(parsedAddr.port()) > 0 {
+				port = parsedAddr.port()
+				break
+			}
+		}
+	}
+	if port == "443" {
+		port = ""
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host := request.Host
+		hostname, _, err := net.SplitHostPort(host)
+		if err == nil {
+			host = hostname
+		}
+		if len(port) > 0 {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+
+		http.Redirect(writer, request, "https://"+host+request.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+func tlsAbsPath(path string) string {
+	if len(path) == 0 || filepath.IsAbs(path) {
+		return path
+	}
+	return Tea.Root + Tea.DS + path
+}
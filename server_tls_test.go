@@ -0,0 +1,107 @@
+package TeaGo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTLSCertManager_Reload(t *testing.T) {
+	var dir = t.TempDir()
+	var certFile = dir + "/a.pem"
+	var keyFile = dir + "/a.key"
+	writeTestCert(t, certFile, keyFile, "a.example.com")
+
+	manager, err := newTLSCertManager([]ServerCertConfig{{Cert: certFile, Key: keyFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testCertName(t, cert) != "a.example.com" {
+		t.Fatal("unexpected certificate:", testCertName(t, cert))
+	}
+
+	// 修改证书文件
+	time.Sleep(10 * time.Millisecond)
+	writeTestCert(t, certFile, keyFile, "b.example.com")
+	manager.lastCheck = time.Time{}
+
+	cert, err = manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "b.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testCertName(t, cert) != "b.example.com" {
+		t.Fatal("certificate should be reloaded")
+	}
+}
+
+func TestServer_RedirectToHTTPS(t *testing.T) {
+	server := NewServer(false)
+	server.config.Https.Listen = []string{"0.0.0.0:8443"}
+
+	for port, location := range map[int]string{
+		0:   "https://example.com:8443/hello?name=lu",
+		443: "https://example.com/hello?name=lu",
+	} {
+		server.config.Http.RedirectPort = port
+
+		recorder := httptest.NewRecorder()
+		server.redirectToHTTPSHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com:8080/hello?name=lu", nil))
+		if recorder.Header().Get("Location") != location {
+			t.Fatal("expected '" + location + "', but got '" + recorder.Header().Get("Location") + "'")
+		}
+	}
+}
+
+func writeTestCert(t *testing.T, certFile string, keyFile string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCertName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
@@ -0,0 +1,236 @@
+//go:build !windows
+// +build !windows
+
+package TeaGo
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/processes"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 平滑重启时用来传递监听的环境变量
+const (
+	envUpgradeListeners = "TEAGO_UPGRADE_LISTENERS" // 监听地址列表，用 ; 分隔，依次对应从3开始的文件描述符
+	envUpgradeReadyFd   = "TEAGO_UPGRADE_READY_FD"  // 新进程就绪后需要写入的文件描述符
+)
+
+// 等待新进程就绪的最长时间
+const upgradeReadyTimeout = 30 * time.Second
+
+var inheritedListeners = map[string]net.Listener{} // addr => listener
+var inheritedReadyFd = 0
+var inheritedLocker = sync.Mutex{}
+var inheritedOnce = sync.Once{}
+
+// 读取从上一个进程继承的监听
+func loadInheritedListeners() {
+	inheritedOnce.Do(func() {
+		var addrs = os.Getenv(envUpgradeListeners)
+		var readyFd = os.Getenv(envUpgradeReadyFd)
+
+		// 防止再传递给以后的子进程
+		_ = os.Unsetenv(envUpgradeListeners)
+		_ = os.Unsetenv(envUpgradeReadyFd)
+
+		if len(readyFd) == 0 {
+			return
+		}
+		inheritedReadyFd, _ = strconv.Atoi(readyFd)
+
+		if len(addrs) == 0 {
+			return
+		}
+		for index, addr := range strings.Split(addrs, ";") {
+			file := os.NewFile(uintptr(3+index), "listener:"+addr)
+			listener, err := net.FileListener(file)
+			_ = file.Close()
+			if err != nil {
+				logs.Error(errors.New("inherit listener '" + addr + "': " + err.Error()))
+				continue
+			}
+			inheritedListeners[addr] = listener
+		}
+	})
+}
+
+// 是否为平滑重启中启动的新进程
+func isUpgradeProcess() bool {
+	loadInheritedListeners()
+	return inheritedReadyFd > 0
+}
+
+// 取出从上一个进程继承的监听，如果没有则返回nil
+func takeInheritedListener(addr string) net.Listener {
+	loadInheritedListeners()
+
+	inheritedLocker.Lock()
+	defer inheritedLocker.Unlock()
+
+	listener, ok := inheritedListeners[addr]
+	if !ok {
+		return nil
+	}
+	delete(inheritedListeners, addr)
+	return listener
+}
+
+// 通知上一个进程当前进程已就绪
+func (this *Server) notifyReady() {
+	if !isUpgradeProcess() {
+		return
+	}
+
+	// 关闭没有用到的监听
+	inheritedLocker.Lock()
+	for addr, listener := range inheritedListeners {
+		logs.Println("close unused inherited listener", addr)
+		_ = listener.Close()
+		delete(inheritedListeners, addr)
+	}
+	inheritedLocker.Unlock()
+
+	if this.singleInstance {
+		this.writePid()
+	}
+
+	file := os.NewFile(uintptr(inheritedReadyFd), "ready")
+	_, err := file.Write([]byte(strconv.Itoa(os.Getpid())))
+	if err != nil {
+		logs.Error(errors.New("notify ready: " + err.Error()))
+	}
+	_ = file.Close()
+	inheritedReadyFd = 0
+}
+
+// 监听平滑重启信号
+func (this *Server) notifyUpgrade(signalChan chan os.Signal) {
+	signal.Notify(signalChan, syscall.SIGUSR2)
+}
+
+// 将监听交给一个新启动的进程，并等待其就绪
+func (this *Server) upgrade() error {
+	var addrs = []string{}
+	var listenerFiles = []*os.File{}
+	defer func() {
+		for _, file := range listenerFiles {
+			_ = file.Close()
+		}
+	}()
+
+	this.httpServerLocker.Lock()
+	for addr, listener := range this.listeners {
+		fileListener, ok := listener.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			continue
+		}
+		// 新进程会继续使用Socket文件，关闭时不能删除
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+
+		file, err := fileListener.File()
+		if err != nil {
+			this.httpServerLocker.Unlock()
+			return err
+		}
+		addrs = append(addrs, addr)
+		listenerFiles = append(listenerFiles, file)
+	}
+	this.httpServerLocker.Unlock()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = readyReader.Close()
+	}()
+
+	cmd, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		_ = readyWriter.Close()
+		return err
+	}
+
+	process := processes.NewProcess(cmd)
+	process.AppendFile(listenerFiles...)
+	process.AppendFile(readyWriter)
+	process.AppendEnv(envUpgradeListeners, strings.Join(addrs, ";"))
+	process.AppendEnv(envUpgradeReadyFd, strconv.Itoa(3+len(listenerFiles)))
+	err = process.Start()
+	_ = readyWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	var readyChan = make(chan error, 1)
+	go func() {
+		_, err := readyReader.Read(make([]byte, 32))
+		if err != nil {
+			readyChan <- errors.New("new process " + strconv.Itoa(process.Pid()) + " exited before ready")
+			return
+		}
+		readyChan <- nil
+	}()
+
+	select {
+	case err := <-readyChan:
+		if err != nil {
+			return err
+		}
+	case <-time.After(upgradeReadyTimeout):
+		newProcess, err := os.FindProcess(process.Pid())
+		if err == nil {
+			_ = newProcess.Kill()
+		}
+		return errors.New("timeout waiting for new process " + strconv.Itoa(process.Pid()) + " to be ready")
+	}
+
+	logs.Println("new process", process.Pid(), "is ready")
+	return nil
+}
+
+// 通知正在运行的进程平滑重启，如果成功则返回true
+func (this *Server) signalRestart(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.SIGUSR2)
+	if err != nil {
+		return false
+	}
+
+	// 等待新进程写入PID
+	var timeout = time.Now().Add(upgradeReadyTimeout + 5*time.Second)
+	for time.Now().Before(timeout) {
+		time.Sleep(100 * time.Millisecond)
+
+		newPid := this.findPid()
+		if newPid > 0 && newPid != pid {
+			log.Println("restarted, new pid", newPid)
+			return true
+		}
+
+		// 旧进程不支持平滑重启时可能已经退出
+		if process.Signal(syscall.Signal(0)) != nil {
+			return false
+		}
+	}
+
+	log.Println("restart timeout, pid", pid)
+	return true
+}
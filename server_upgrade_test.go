@@ -0,0 +1,125 @@
+//go:build !windows
+// +build !windows
+
+package TeaGo
+
+import (
+	"github.com/iwind/TeaGo/Tea"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestServer_InheritListeners(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	listenerFile, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listenerFile.Close()
+	}()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = readyReader.Close()
+	}()
+
+	// 在子进程中读取继承的监听，文件描述符和 upgrade() 中的一致：监听从3开始，最后是就绪通知
+	var cmd = exec.Command(os.Args[0], "-test.run=^TestServer_InheritListenersChild$", "-test.v")
+	cmd.ExtraFiles = []*os.File{listenerFile, readyWriter}
+	cmd.Env = append(os.Environ(),
+		envUpgradeListeners+"=:7777",
+		envUpgradeReadyFd+"=4",
+		"TEAGO_TEST_UPGRADE_ADDR="+listener.Addr().String(),
+	)
+	err = cmd.Start()
+	_ = readyWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(readyReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatal("child process failed:", err)
+	}
+	if string(data) != strconv.Itoa(cmd.Process.Pid) {
+		t.Fatal("child process should notify ready with its pid, but got '" + string(data) + "'")
+	}
+}
+
+// 由 TestServer_InheritListeners() 启动的子进程
+func TestServer_InheritListenersChild(t *testing.T) {
+	var addr = os.Getenv("TEAGO_TEST_UPGRADE_ADDR")
+	if len(addr) == 0 {
+		t.Skip("run by TestServer_InheritListeners()")
+	}
+
+	if !isUpgradeProcess() {
+		t.Fatal("ready fd should be parsed")
+	}
+	if len(os.Getenv(envUpgradeListeners)) > 0 || len(os.Getenv(envUpgradeReadyFd)) > 0 {
+		t.Fatal("environment variables should be removed")
+	}
+	var listener = takeInheritedListener(":7777")
+	if listener == nil || listener.Addr().String() != addr {
+		t.Fatal("listener should be inherited")
+	}
+	if takeInheritedListener(":7777") != nil {
+		t.Fatal("listener should be taken only once")
+	}
+
+	NewServer(false).notifyReady()
+}
+
+func TestServer_WriteNewPid(t *testing.T) {
+	var oldRoot = Tea.Root
+	defer func() {
+		Tea.Root = oldRoot
+	}()
+	Tea.Root = t.TempDir()
+
+	var server = NewServer(false)
+	server.writeNewPid(111)
+	oldStat, err := os.Stat(Tea.Root + "/bin/pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.writeNewPid(222)
+	if server.findPid() != 222 {
+		t.Fatal("pid should be updated")
+	}
+
+	// 通过改名替换，而不是直接改写原来的文件
+	newStat, err := os.Stat(Tea.Root + "/bin/pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(oldStat, newStat) {
+		t.Fatal("pid file should be replaced by rename")
+	}
+	entries, err := os.ReadDir(Tea.Root + "/bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatal("tmp files should not be left")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package TeaGo
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// Windows下不支持传递监听，平滑重启不可用
+
+func isUpgradeProcess() bool {
+	return false
+}
+
+func takeInheritedListener(addr string) net.Listener {
+	return nil
+}
+
+func (this *Server) notifyReady() {
+}
+
+func (this *Server) notifyUpgrade(signalChan chan os.Signal) {
+}
+
+func (this *Server) upgrade() error {
+	return errors.New("upgrade is not supported on windows")
+}
+
+func (this *Server) signalRestart(pid int) bool {
+	return false
+}
@@ -275,3 +275,16 @@ func (this *FileSessionManager) load() {
 		}
 	}()
 }
+
+// Count 取得没有过期的SESSION数量
+func (this *FileSessionManager) Count() int {
+	var count = 0
+	this.sessionMap.Range(func(key, value interface{}) bool {
+		data, ok := value.(*FileSessionData)
+		if ok && !data.isExpired() {
+			count++
+		}
+		return true
+	})
+	return count
+}
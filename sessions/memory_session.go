@@ -89,3 +89,19 @@ func (this *MemorySessionManager) Delete(sid string) bool {
 	delete(this.sessionMap, sid)
 	return true
 }
+
+// Count 取得没有过期的SESSION数量
+func (this *MemorySessionManager) Count() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	var count = 0
+	var now = time.Now().Unix()
+	for _, user := range this.sessionMap {
+		expiredAt, ok := user["expiredAt"].(int64)
+		if ok && expiredAt >= now {
+			count++
+		}
+	}
+	return count
+}